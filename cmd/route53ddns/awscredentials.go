@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// mfaCommandTimeout bounds a single run of the mfa token command, which may wait on a human
+	mfaCommandTimeout = 2 * time.Minute
+	// credentialsTimeout bounds retrieving credentials when they are verified at startup
+	credentialsTimeout = 10 * time.Second
+)
+
+// assumeRole returns a copy of cfg whose credentials come from assuming roleARN with the credentials
+// of cfg, credentials are cached and refreshed before they expire. when mfaSerial is set every
+// assumption asks tokenProvider for a fresh mfa code
+func assumeRole(cfg aws.Config, roleARN, externalID, sessionName, mfaSerial string, tokenProvider func() (string, error)) aws.Config {
+	provider := stscreds.NewAssumeRoleProvider(newSTSClient(cfg), roleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = sessionName
+		if externalID != "" {
+			o.ExternalID = aws.String(externalID)
+		}
+		if mfaSerial != "" {
+			o.SerialNumber = aws.String(mfaSerial)
+			o.TokenProvider = tokenProvider
+		}
+	})
+
+	assumed := cfg.Copy()
+	assumed.Credentials = aws.NewCredentialsCache(provider)
+
+	return assumed
+}
+
+// webIdentityRole returns a copy of cfg whose credentials come from exchanging the oidc token in
+// tokenFile for roleARN, the file is read again on every refresh so rotated tokens are picked up
+func webIdentityRole(cfg aws.Config, roleARN, tokenFile, sessionName string) (aws.Config, error) {
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return cfg, err
+	}
+	if len(strings.TrimSpace(string(token))) == 0 {
+		return cfg, fmt.Errorf("%s: %s", "web identity token file is empty", tokenFile)
+	}
+
+	provider := stscreds.NewWebIdentityRoleProvider(newSTSClient(cfg), roleARN, stscreds.IdentityTokenFile(tokenFile),
+		func(o *stscreds.WebIdentityRoleOptions) {
+			o.RoleSessionName = sessionName
+		})
+
+	assumed := cfg.Copy()
+	assumed.Credentials = aws.NewCredentialsCache(provider)
+
+	return assumed, nil
+}
+
+// mfaTokenProvider returns a provider running command for each mfa code, or prompting on the terminal
+// when no command is given. without a command or a terminal there is nobody to enter the code, as when
+// running in the background or under a service manager
+func mfaTokenProvider(command string) (func() (string, error), error) {
+	if command == "" {
+		if !stdinIsTerminal() {
+			return nil, errors.New("mfa codes can only be prompted for on a terminal, set a token command")
+		}
+		return stscreds.StdinTokenProvider, nil
+	}
+
+	return func() (string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), mfaCommandTimeout)
+		defer cancel()
+
+		out, err := exec.CommandContext(ctx, "/bin/sh", "-c", command).Output()
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", "mfa token command failed", err)
+		}
+
+		token := strings.TrimSpace(string(out))
+		if token == "" {
+			return "", errors.New("mfa token command printed no token")
+		}
+
+		return token, nil
+	}, nil
+}
+
+// stdinIsTerminal reports whether stdin is a terminal, rather than a file, a pipe or /dev/null which
+// background processes and services read from
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+
+	return err != nil || !os.SameFile(info, null)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	cloudwatchlogstypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	// cloudWatchLogsQueueSize bounds how many events are buffered while cloudwatch is unreachable
+	cloudWatchLogsQueueSize = 10000
+	// cloudWatchLogsBatchSize is the most events sent in one call
+	cloudWatchLogsBatchSize = 1000
+	// cloudWatchLogsFlushInterval is how long events are buffered before being sent
+	cloudWatchLogsFlushInterval = 5 * time.Second
+	// cloudWatchLogsMaxBackoff caps the delay between retries of a failed batch
+	cloudWatchLogsMaxBackoff = time.Minute
+	// cloudWatchTimeout bounds a single call to cloudwatch logs
+	cloudWatchTimeout = 10 * time.Second
+)
+
+// cloudWatchLogsWriter ships each write, one formatted log record, to a cloudwatch logs stream in
+// batches from a background goroutine
+type cloudWatchLogsWriter struct {
+	client *cloudwatchlogs.Client
+	group  string
+	stream string
+	events chan cloudwatchlogstypes.InputLogEvent
+	flush  chan chan struct{}
+}
+
+// newCloudWatchLogsWriter creates the log stream when missing and starts shipping events to it
+func newCloudWatchLogsWriter(cfg aws.Config, group, stream string) (*cloudWatchLogsWriter, error) {
+	if group == "" {
+		return nil, errors.New("cloudwatch log group is not set")
+	}
+
+	w := &cloudWatchLogsWriter{
+		client: cloudwatchlogs.NewFromConfig(cfg),
+		group:  group,
+		stream: stream,
+		events: make(chan cloudwatchlogstypes.InputLogEvent, cloudWatchLogsQueueSize),
+		flush:  make(chan chan struct{}),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cloudWatchTimeout)
+	defer cancel()
+
+	_, err := w.client.CreateLogStream(ctx, &cloudwatchlogs.CreateLogStreamInput{
+		LogGroupName:  aws.String(group),
+		LogStreamName: aws.String(stream),
+	})
+	var exists *cloudwatchlogstypes.ResourceAlreadyExistsException
+	if err != nil && !errors.As(err, &exists) {
+		return nil, err
+	}
+
+	go w.run()
+
+	return w, nil
+}
+
+// Write queues p as a log event, events are dropped rather than blocking logging when the queue is full
+func (w *cloudWatchLogsWriter) Write(p []byte) (int, error) {
+	event := cloudwatchlogstypes.InputLogEvent{
+		Message:   aws.String(string(bytes.TrimSuffix(p, []byte("\n")))),
+		Timestamp: aws.Int64(time.Now().UnixMilli()),
+	}
+
+	select {
+	case w.events <- event:
+	default:
+		_, _ = fmt.Fprintln(os.Stderr, "cloudwatch logs queue is full, dropping log event")
+	}
+
+	return len(p), nil
+}
+
+// Flush sends everything queued so far, waiting at most timeout
+func (w *cloudWatchLogsWriter) Flush(timeout time.Duration) {
+	done := make(chan struct{})
+	select {
+	case w.flush <- done:
+	case <-time.After(timeout):
+		return
+	}
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+// run batches queued events and sends them, retrying failed batches with exponential backoff
+func (w *cloudWatchLogsWriter) run() {
+	ticker := time.NewTicker(cloudWatchLogsFlushInterval)
+	defer ticker.Stop()
+
+	var batch []cloudwatchlogstypes.InputLogEvent
+	backoff := time.Second
+	var retryAt time.Time
+
+	send := func() {
+		if len(batch) == 0 || time.Now().Before(retryAt) {
+			return
+		}
+
+		sent, err := w.put(batch)
+		batch = batch[sent:]
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "unable to ship %d log events to cloudwatch, retrying in %s: %v\n", len(batch), backoff, err)
+			retryAt = time.Now().Add(backoff)
+			backoff = min(backoff*2, cloudWatchLogsMaxBackoff)
+
+			// keep the newest events when the backlog outgrows the queue
+			if len(batch) > cloudWatchLogsQueueSize {
+				batch = batch[len(batch)-cloudWatchLogsQueueSize:]
+			}
+			return
+		}
+
+		batch = nil
+		backoff = time.Second
+		retryAt = time.Time{}
+	}
+
+	for {
+		select {
+		case event := <-w.events:
+			batch = append(batch, event)
+			if len(batch) >= cloudWatchLogsBatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case done := <-w.flush:
+			for len(w.events) > 0 {
+				batch = append(batch, <-w.events)
+			}
+			retryAt = time.Time{}
+			send()
+			close(done)
+		}
+	}
+}
+
+// put sends events in chronological batches no larger than the api limit, returning how many
+// events were sent before any error
+func (w *cloudWatchLogsWriter) put(events []cloudwatchlogstypes.InputLogEvent) (int, error) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return *events[i].Timestamp < *events[j].Timestamp
+	})
+
+	for start := 0; start < len(events); start += cloudWatchLogsBatchSize {
+		end := min(start+cloudWatchLogsBatchSize, len(events))
+
+		ctx, cancel := context.WithTimeout(context.Background(), cloudWatchTimeout)
+		_, err := w.client.PutLogEvents(ctx, &cloudwatchlogs.PutLogEventsInput{
+			LogGroupName:  aws.String(w.group),
+			LogStreamName: aws.String(w.stream),
+			LogEvents:     events[start:end],
+		})
+		cancel()
+		if err != nil {
+			return start, err
+		}
+	}
+
+	return len(events), nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/rgravlin/route53ddns/pkg/updater"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// configMu serializes refreshes of the configuration sources, which all replace variables of the
+// environment
+var configMu sync.Mutex
+
+// configOverlay sets variables from a configuration source, variables set in the environment of the
+// process take precedence and are never replaced
+type configOverlay struct {
+	// values holds the value of every variable currently set by the source
+	values map[string]string
+}
+
+// apply sets the variables of values that aren't set in the environment of the process and unsets
+// those the source no longer sets, returning the names of the variables that changed
+func (o *configOverlay) apply(values map[string]string) ([]string, error) {
+	if o.values == nil {
+		o.values = map[string]string{}
+	}
+
+	names := make([]string, 0, len(values)+len(o.values))
+	for name := range values {
+		names = append(names, name)
+	}
+	for name := range o.values {
+		if _, ok := values[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changed []string
+	for _, name := range names {
+		value, ok := values[name]
+		previous, managed := o.values[name]
+		if _, set := os.LookupEnv(name); set && !managed {
+			slog.Debug("configuration is overridden by the environment", "variable", name)
+			continue
+		}
+
+		switch {
+		case !ok:
+			if err := os.Unsetenv(name); err != nil {
+				return nil, err
+			}
+			delete(o.values, name)
+		case !managed || previous != value:
+			if err := os.Setenv(name, value); err != nil {
+				return nil, err
+			}
+			o.values[name] = value
+		default:
+			continue
+		}
+		changed = append(changed, name)
+	}
+
+	return changed, nil
+}
+
+// configVariable returns the configuration variable named by key, with or without the variable
+// prefix, e.g. hostname and record-ttl name CONFIG_R53DDNS_HOSTNAME and CONFIG_R53DDNS_RECORD_TTL
+func configVariable(key string) string {
+	variable := strings.NewReplacer("-", "_", ".", "_").Replace(strings.ToUpper(key))
+	if !strings.HasPrefix(variable, "CONFIG_R53DDNS_") {
+		variable = "CONFIG_R53DDNS_" + variable
+	}
+
+	return variable
+}
+
+// applyConfigChanges reconfigures what can change while running after source changed the variables
+// named changed, any other setting takes effect on the next restart. changed variables referencing
+// secrets manager are resolved first
+func applyConfigChanges(u *updater.Updater, source string, changed []string) {
+	refs := map[string]string{}
+	found := findSecretReferences()
+	for _, name := range changed {
+		delete(secretReferences, name)
+		if ref, ok := found[name]; ok {
+			refs[name] = ref
+			secretReferences[name] = ref
+		}
+	}
+	if len(refs) > 0 {
+		if _, err := resolveSecrets(context.Background(), secretsConfig, refs); err != nil {
+			slog.Warn("unable to resolve secrets of changed configuration", "source", source, "error", err)
+		}
+	}
+
+	notifications, err := notificationsFromEnv()
+	if err != nil {
+		slog.Error("unable to reconfigure notifications", "source", source, "variables", changed, "error", err)
+		return
+	}
+	cfg := updater.Config{Records: recordEntriesFromEnv(), Notifications: notifications}
+	if err := u.Reload(context.Background(), cfg); err != nil {
+		slog.Error("unable to apply changed configuration", "source", source, "variables", changed, "error", err)
+		return
+	}
+	slog.Info("configuration changed, records and notifications reloaded, other settings apply after a restart",
+		"source", source, "variables", changed)
+}
+
+// envInt returns the integer value of an environmental variable, or def when it is not set
+func envInt(name string, def int) int {
+	i, err := parseEnvInt(name, def)
+	if err != nil {
+		fatal("environmental variable is not a valid integer", "variable", name, "error", err)
+	}
+
+	return i
+}
+
+// parseEnvInt is envInt for settings reloaded while running, returning an error instead of exiting
+func parseEnvInt(name string, def int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s %s: %w", "environmental variable is not a valid integer", name, err)
+	}
+
+	return i, nil
+}
+
+// envString returns the value of an environmental variable, or def when it is not set
+func envString(name, def string) string {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	return value
+}
+
+// envList returns the comma separated values of an environmental variable with blanks removed
+func envList(name string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// envBool returns the boolean value of an environmental variable, or def when it is not set
+func envBool(name string, def bool) bool {
+	b, err := parseEnvBool(name, def)
+	if err != nil {
+		fatal("environmental variable is not a valid boolean", "variable", name, "error", err)
+	}
+
+	return b
+}
+
+// parseEnvBool is envBool for settings reloaded while running, returning an error instead of exiting
+func parseEnvBool(name string, def bool) (bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s %s: %w", "environmental variable is not a valid boolean", name, err)
+	}
+
+	return b, nil
+}
+
+// envDuration returns the duration value of an environmental variable, or def when it is not set
+func envDuration(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		fatal("environmental variable is not a valid duration", "variable", name, "error", err)
+	}
+
+	return d
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// daemonChildEnvVar marks the re-executed background copy of the process
+const daemonChildEnvVar = "_CONFIG_R53DDNS_DAEMON_CHILD"
+
+// isDaemonChild reports whether this process is the detached copy started by daemonize
+func isDaemonChild() bool {
+	return os.Getenv(daemonChildEnvVar) == "1"
+}
+
+// daemonize re-executes the current binary detached from the controlling terminal in a new session
+// and exits the foreground process. the background copy writes its own logs, its stderr is discarded
+// since the rotated log file can't be shared with it
+func daemonize() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), daemonChildEnvVar+"=1")
+	cmd.Stdin = devNull
+	cmd.Stdout = devNull
+	cmd.Stderr = devNull
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	slog.Info("started in background", "pid", cmd.Process.Pid)
+	os.Exit(0)
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ddclientConfPath is the ddclient configuration file configuration is read from, none when empty
+var ddclientConfPath string
+
+// ddclientOverlay holds the variables set from the ddclient configuration file
+var ddclientOverlay = &configOverlay{}
+
+// ddclientOptionRegex matches a name=value option at the start of a ddclient line, values may be
+// single or double quoted
+var ddclientOptionRegex = regexp.MustCompile(`^([A-Za-z0-9_-]+)\s*=\s*('[^']*'|"[^"]*"|[^\s,]*)`)
+
+// ddclientWebServices are the address services ddclient names in use=web, limited to those answering
+// with the bare address
+var ddclientWebServices = map[string]string{
+	"ipify-ipv4":         "https://api.ipify.org",
+	"ipify-ipv6":         "https://api6.ipify.org",
+	"he":                 "https://checkip.dns.he.net",
+	"myonlineportal":     "https://myonlineportal.net/checkip",
+	"nsupdate.info-ipv4": "https://ipv4.nsupdate.info/myip",
+	"nsupdate.info-ipv6": "https://ipv6.nsupdate.info/myip",
+	"zoneedit":           "https://dynamic.zoneedit.com/checkip.html",
+}
+
+// ddclientIgnored are directives that configure how ddclient talks to its dyndns service, which have no
+// meaning when records are updated in route53 directly
+var ddclientIgnored = []string{"protocol", "server", "login", "password", "zone", "ssl", "ttl", "wildcard", "mx",
+	"backupmx", "script", "syslog", "mail", "mail-failure", "quiet", "verbose", "debug", "foreground", "retry",
+	"max-interval", "min-interval", "min-error-interval", "timeout", "cache", "postscript", "fw-login",
+	"fw-password", "web-ssl-validate", "fw-ssl-validate", "proxy", "priority", "exec"}
+
+// readDDClientConfig maps the directives of a ddclient configuration file onto the variables they
+// correspond to, the hosts of every protocol become records and the address is read the way use=web
+// reads it. options are treated as global wherever they appear, and directives without a counterpart
+// are logged and ignored
+func readDDClientConfig(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	options := map[string]string{}
+	var hosts []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	var pending string
+	for line := 1; scanner.Scan(); line++ {
+		// a trailing backslash continues the line
+		text := pending + ddclientStripComment(scanner.Text())
+		if strings.HasSuffix(strings.TrimSpace(text), `\`) {
+			pending = strings.TrimSuffix(strings.TrimSpace(text), `\`) + " "
+			continue
+		}
+		pending = ""
+
+		rest := strings.TrimSpace(text)
+		for rest != "" {
+			rest = strings.TrimLeft(rest, ", \t")
+			match := ddclientOptionRegex.FindStringSubmatch(rest)
+			if match == nil {
+				break
+			}
+			options[strings.ToLower(strings.ReplaceAll(match[1], "_", "-"))] = strings.Trim(match[2], `'"`)
+			rest = rest[len(match[0]):]
+		}
+		for _, host := range strings.FieldsFunc(rest, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			if !strings.Contains(host, ".") {
+				return nil, fmt.Errorf("%s %d: %q", "not a valid host on line", line, host)
+			}
+			if !slices.Contains(hosts, host) {
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("%s: %s", "no hosts in ddclient configuration", path)
+	}
+
+	values := map[string]string{FQDNEnvVar: hosts[0]}
+	if len(hosts) > 1 {
+		values[RecordsEnvVar] = strings.Join(hosts[1:], ",")
+	}
+
+	for name, value := range options {
+		switch name {
+		case "daemon":
+			interval, err := ddclientInterval(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s %q: %w", "not a valid daemon interval", value, err)
+			}
+			if interval > 0 {
+				values[ReconcileIntervalEnvVar] = interval.String()
+			}
+		case "use", "usev4":
+			if value != "web" && value != "webv4" {
+				return nil, fmt.Errorf("%s: %s=%s", "only web address detection is supported", name, value)
+			}
+			values[IPSourceEnvVar] = IPSourceURL
+		case "web", "webv4":
+			values[PublicIPURL] = ddclientWebURL(value)
+		case "pid":
+			values[PIDFileEnvVar] = value
+		case "web-skip", "webv4-skip":
+			slog.Warn("ddclient directive is not supported, the address service must answer with the bare address", "directive", name, "path", path)
+		default:
+			if !slices.Contains(ddclientIgnored, name) {
+				slog.Warn("ddclient directive is not supported", "directive", name, "path", path)
+				continue
+			}
+			slog.Debug("ignoring ddclient directive", "directive", name, "path", path)
+		}
+	}
+	if _, ok := values[IPSourceEnvVar]; ok && values[PublicIPURL] == "" {
+		values[PublicIPURL] = ddclientWebServices["ipify-ipv4"]
+	}
+
+	return values, nil
+}
+
+// ddclientStripComment removes a comment from a line, a # inside quotes is kept
+func ddclientStripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '\'' || r == '"'):
+			quote = r
+		case quote == 0 && r == '#':
+			return line[:i]
+		}
+	}
+
+	return line
+}
+
+// ddclientInterval parses a ddclient interval, seconds optionally suffixed with s, m, h or d
+func ddclientInterval(value string) (time.Duration, error) {
+	unit := time.Second
+	switch {
+	case strings.HasSuffix(value, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(value, "h"):
+		unit = time.Hour
+	case strings.HasSuffix(value, "m"):
+		unit = time.Minute
+	}
+	n, err := strconv.Atoi(strings.TrimRight(value, "smhd"))
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(n) * unit, nil
+}
+
+// ddclientWebURL returns the url of a ddclient address service, ddclient allows urls without a scheme
+func ddclientWebURL(value string) string {
+	if service, ok := ddclientWebServices[value]; ok {
+		return service
+	}
+	if !strings.Contains(value, "://") {
+		return "http://" + value
+	}
+
+	return value
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadDDClientConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		conf    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "single host",
+			conf: "protocol=dyndns2\nlogin=me, password='secret'\nhome.example.com\n",
+			want: map[string]string{FQDNEnvVar: "home.example.com"},
+		},
+		{
+			name: "hosts across lines",
+			conf: "daemon=300\nuse=web, web=ipify-ipv6\nhome.example.com,office.example.com \\\n  home.example.com lab.example.com # trailing comment\n",
+			want: map[string]string{
+				FQDNEnvVar:              "home.example.com",
+				RecordsEnvVar:           "office.example.com,lab.example.com",
+				ReconcileIntervalEnvVar: (5 * time.Minute).String(),
+				IPSourceEnvVar:          IPSourceURL,
+				PublicIPURL:             "https://api6.ipify.org",
+			},
+		},
+		{
+			name: "options before a host",
+			conf: "daemon=2h pid=/run/ddclient.pid use=web home.example.com\n",
+			want: map[string]string{
+				FQDNEnvVar:              "home.example.com",
+				ReconcileIntervalEnvVar: (2 * time.Hour).String(),
+				PIDFileEnvVar:           "/run/ddclient.pid",
+				IPSourceEnvVar:          IPSourceURL,
+				PublicIPURL:             "https://api.ipify.org",
+			},
+		},
+		{
+			name: "web url without a scheme",
+			conf: "use=web, web=checkip.example.net/ip\nhome.example.com\n",
+			want: map[string]string{
+				FQDNEnvVar:     "home.example.com",
+				IPSourceEnvVar: IPSourceURL,
+				PublicIPURL:    "http://checkip.example.net/ip",
+			},
+		},
+		{
+			name: "quoted hash",
+			conf: "password='not # a comment'\nhome.example.com\n",
+			want: map[string]string{FQDNEnvVar: "home.example.com"},
+		},
+		{name: "no hosts", conf: "protocol=dyndns2\n# home.example.com\n", wantErr: true},
+		{name: "host without a domain", conf: "localhost\n", wantErr: true},
+		{name: "other address detection", conf: "use=if, if=eth0\nhome.example.com\n", wantErr: true},
+		{name: "bad daemon interval", conf: "daemon=soon\nhome.example.com\n", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ddclient.conf")
+			if err := os.WriteFile(path, []byte(tc.conf), 0o600); err != nil {
+				t.Fatalf("unable to write configuration: %v", err)
+			}
+
+			values, err := readDDClientConfig(path)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("readDDClientConfig() = %v, want an error", values)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readDDClientConfig() error = %v", err)
+			}
+			if !maps.Equal(values, tc.want) {
+				t.Errorf("readDDClientConfig() = %v, want %v", values, tc.want)
+			}
+		})
+	}
+}
+
+func TestDDClientInterval(t *testing.T) {
+	for _, tc := range []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "300", want: 300 * time.Second},
+		{value: "45s", want: 45 * time.Second},
+		{value: "10m", want: 10 * time.Minute},
+		{value: "1d", want: 24 * time.Hour},
+		{value: "", wantErr: true},
+		{value: "5w", wantErr: true},
+	} {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := ddclientInterval(tc.value)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ddclientInterval(%q) error = %v, want error %v", tc.value, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("ddclientInterval(%q) = %v, want %v", tc.value, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// endpointKey returns the key an aws service is named by in the endpoints variable, its sdk service
+// id in lower case without spaces, e.g. route53, sts or cloudwatchlogs
+func endpointKey(serviceID string) string {
+	return strings.ToLower(strings.ReplaceAll(serviceID, " ", ""))
+}
+
+// parseEndpoints parses comma separated service=url pairs, e.g.
+// route53=https://route53-fips.amazonaws.com,sts=https://vpce-0123.sts.eu-west-1.vpce.amazonaws.com
+func parseEndpoints(value string) (map[string]string, error) {
+	endpoints := map[string]string{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		service, endpoint, ok := strings.Cut(entry, "=")
+		if !ok || service == "" {
+			return nil, fmt.Errorf("%s: %q", "not a service=url pair", entry)
+		}
+		if u, err := url.Parse(endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("%s: %q", "not a valid endpoint url", endpoint)
+		}
+		endpoints[endpointKey(service)] = endpoint
+	}
+
+	return endpoints, nil
+}
+
+// awsLoadOptions returns the options every aws configuration is loaded with, followed by extra: the
+// configured endpoint of each service and fips endpoints when enabled. services without a configured
+// endpoint keep the endpoint the sdk resolves
+func awsLoadOptions(extra ...func(*config.LoadOptions) error) ([]func(*config.LoadOptions) error, error) {
+	endpoints, err := parseEndpoints(os.Getenv(AWSEndpointsEnvVar))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", AWSEndpointsEnvVar, err)
+	}
+
+	var options []func(*config.LoadOptions) error
+	if len(endpoints) > 0 {
+		resolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, _ ...any) (aws.Endpoint, error) {
+			endpoint, ok := endpoints[endpointKey(service)]
+			if !ok {
+				return aws.Endpoint{}, &aws.EndpointNotFoundError{}
+			}
+			// s3 prefixes the host with the bucket, every other service is called at the host as given
+			return aws.Endpoint{URL: endpoint, HostnameImmutable: service != "S3", Source: aws.EndpointSourceCustom}, nil
+		})
+		// deprecated, but the only resolver applying to every client created from a configuration
+		options = append(options, config.WithEndpointResolverWithOptions(resolver))
+	}
+	fips, err := parseEnvBool(AWSUseFIPSEnvVar, false)
+	if err != nil {
+		return nil, err
+	}
+	if fips {
+		options = append(options, config.WithUseFIPSEndpoint(aws.FIPSEndpointStateEnabled))
+	}
+
+	return append(options, extra...), nil
+}
+
+// newSTSClient creates an sts client from cfg, in the configured sts region when one is set so
+// credentials come from a regional endpoint close to the daemon
+func newSTSClient(cfg aws.Config) *sts.Client {
+	return sts.NewFromConfig(cfg, func(o *sts.Options) {
+		if region := os.Getenv(STSRegionEnvVar); region != "" {
+			o.Region = region
+		}
+	})
+}
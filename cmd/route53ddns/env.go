@@ -0,0 +1,696 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/rgravlin/route53ddns/pkg/dns"
+	"github.com/rgravlin/route53ddns/pkg/ipsource"
+	"github.com/rgravlin/route53ddns/pkg/updater"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	FQDNEnvVar                    = "CONFIG_R53DDNS_HOSTNAME"
+	RecordsEnvVar                 = "CONFIG_R53DDNS_RECORDS"
+	PublicIPURL                   = "CONFIG_R53DDNS_IPURL"
+	FailureThresholdEnvVar        = "CONFIG_R53DDNS_FAILURE_THRESHOLD"
+	FailureActionEnvVar           = "CONFIG_R53DDNS_FAILURE_ACTION"
+	CycleTimeoutEnvVar            = "CONFIG_R53DDNS_CYCLE_TIMEOUT"
+	QuietWindowsEnvVar            = "CONFIG_R53DDNS_QUIET_WINDOWS"
+	PIDFileEnvVar                 = "CONFIG_R53DDNS_PID_FILE"
+	LogFileEnvVar                 = "CONFIG_R53DDNS_LOG_FILE"
+	CheckIntervalEnvVar           = "CONFIG_R53DDNS_CHECK_INTERVAL"
+	ReconcileIntervalEnvVar       = "CONFIG_R53DDNS_RECONCILE_INTERVAL"
+	ScheduleTimezoneEnvVar        = "CONFIG_R53DDNS_SCHEDULE_TIMEZONE"
+	StateFileEnvVar               = "CONFIG_R53DDNS_STATE_FILE"
+	LogFormatEnvVar               = "CONFIG_R53DDNS_LOG_FORMAT"
+	LogLevelEnvVar                = "CONFIG_R53DDNS_LOG_LEVEL"
+	LogOutputEnvVar               = "CONFIG_R53DDNS_LOG_OUTPUT"
+	QuietSteadyStateEnvVar        = "CONFIG_R53DDNS_QUIET_STEADY_STATE"
+	SyslogAddressEnvVar           = "CONFIG_R53DDNS_SYSLOG_ADDRESS"
+	CloudWatchLogGroupEnvVar      = "CONFIG_R53DDNS_CLOUDWATCH_LOG_GROUP"
+	CloudWatchLogStreamEnvVar     = "CONFIG_R53DDNS_CLOUDWATCH_LOG_STREAM"
+	LogMaxSizeEnvVar              = "CONFIG_R53DDNS_LOG_MAX_SIZE"
+	LogMaxAgeEnvVar               = "CONFIG_R53DDNS_LOG_MAX_AGE"
+	LogMaxBackupsEnvVar           = "CONFIG_R53DDNS_LOG_MAX_BACKUPS"
+	LogCompressEnvVar             = "CONFIG_R53DDNS_LOG_COMPRESS"
+	ListenAddressEnvVar           = "CONFIG_R53DDNS_LISTEN_ADDRESS"
+	StatsdAddressEnvVar           = "CONFIG_R53DDNS_STATSD_ADDRESS"
+	StatsdPrefixEnvVar            = "CONFIG_R53DDNS_STATSD_PREFIX"
+	StatsdFlavorEnvVar            = "CONFIG_R53DDNS_STATSD_FLAVOR"
+	StatsdTagsEnvVar              = "CONFIG_R53DDNS_STATSD_TAGS"
+	OTLPEndpointEnvVar            = "CONFIG_R53DDNS_OTLP_ENDPOINT"
+	XRayEnvVar                    = "CONFIG_R53DDNS_XRAY"
+	ReadyMaxAgeEnvVar             = "CONFIG_R53DDNS_READY_MAX_AGE"
+	PprofEnvVar                   = "CONFIG_R53DDNS_PPROF"
+	JournalFileEnvVar             = "CONFIG_R53DDNS_JOURNAL_FILE"
+	AuditLogEnvVar                = "CONFIG_R53DDNS_AUDIT_LOG"
+	DryRunEnvVar                  = "CONFIG_R53DDNS_DRY_RUN"
+	HealthcheckURLEnvVar          = "CONFIG_R53DDNS_HEALTHCHECK_URL"
+	UptimeKumaURLEnvVar           = "CONFIG_R53DDNS_UPTIME_KUMA_URL"
+	WebhookURLsEnvVar             = "CONFIG_R53DDNS_WEBHOOK_URLS"
+	WebhookPayloadEnvVar          = "CONFIG_R53DDNS_WEBHOOK_PAYLOAD"
+	DiscordWebhookURLEnvVar       = "CONFIG_R53DDNS_DISCORD_WEBHOOK_URL"
+	TelegramBotTokenEnvVar        = "CONFIG_R53DDNS_TELEGRAM_BOT_TOKEN"
+	TelegramChatIDEnvVar          = "CONFIG_R53DDNS_TELEGRAM_CHAT_ID"
+	SMTPAddressEnvVar             = "CONFIG_R53DDNS_SMTP_ADDRESS"
+	SMTPSecurityEnvVar            = "CONFIG_R53DDNS_SMTP_SECURITY"
+	SMTPUsernameEnvVar            = "CONFIG_R53DDNS_SMTP_USERNAME"
+	SMTPPasswordEnvVar            = "CONFIG_R53DDNS_SMTP_PASSWORD"
+	SMTPFromEnvVar                = "CONFIG_R53DDNS_SMTP_FROM"
+	SMTPToEnvVar                  = "CONFIG_R53DDNS_SMTP_TO"
+	SESFromEnvVar                 = "CONFIG_R53DDNS_SES_FROM"
+	SESToEnvVar                   = "CONFIG_R53DDNS_SES_TO"
+	SNSTopicARNEnvVar             = "CONFIG_R53DDNS_SNS_TOPIC_ARN"
+	EventBridgeBusEnvVar          = "CONFIG_R53DDNS_EVENTBRIDGE_BUS"
+	PagerDutyRoutingKeyEnvVar     = "CONFIG_R53DDNS_PAGERDUTY_ROUTING_KEY"
+	NTFYURLEnvVar                 = "CONFIG_R53DDNS_NTFY_URL"
+	NTFYTokenEnvVar               = "CONFIG_R53DDNS_NTFY_TOKEN"
+	NTFYPriorityEnvVar            = "CONFIG_R53DDNS_NTFY_PRIORITY"
+	NTFYTagsEnvVar                = "CONFIG_R53DDNS_NTFY_TAGS"
+	PushoverTokenEnvVar           = "CONFIG_R53DDNS_PUSHOVER_TOKEN"
+	PushoverUserEnvVar            = "CONFIG_R53DDNS_PUSHOVER_USER"
+	PushoverPriorityEnvVar        = "CONFIG_R53DDNS_PUSHOVER_PRIORITY"
+	MQTTBrokerEnvVar              = "CONFIG_R53DDNS_MQTT_BROKER"
+	MQTTTopicEnvVar               = "CONFIG_R53DDNS_MQTT_TOPIC"
+	MQTTQoSEnvVar                 = "CONFIG_R53DDNS_MQTT_QOS"
+	MQTTUsernameEnvVar            = "CONFIG_R53DDNS_MQTT_USERNAME"
+	MQTTPasswordEnvVar            = "CONFIG_R53DDNS_MQTT_PASSWORD"
+	MQTTCAFileEnvVar              = "CONFIG_R53DDNS_MQTT_CA_FILE"
+	GotifyURLEnvVar               = "CONFIG_R53DDNS_GOTIFY_URL"
+	GotifyTokenEnvVar             = "CONFIG_R53DDNS_GOTIFY_TOKEN"
+	GotifyPriorityEnvVar          = "CONFIG_R53DDNS_GOTIFY_PRIORITY"
+	MatrixHomeserverEnvVar        = "CONFIG_R53DDNS_MATRIX_HOMESERVER"
+	MatrixAccessTokenEnvVar       = "CONFIG_R53DDNS_MATRIX_ACCESS_TOKEN"
+	MatrixRoomIDEnvVar            = "CONFIG_R53DDNS_MATRIX_ROOM_ID"
+	NotifyTitleTemplateEnvVar     = "CONFIG_R53DDNS_NOTIFY_TITLE_TEMPLATE"
+	NotifyBodyTemplateEnvVar      = "CONFIG_R53DDNS_NOTIFY_BODY_TEMPLATE"
+	NotifyFailureRepeatEnvVar     = "CONFIG_R53DDNS_NOTIFY_FAILURE_REPEAT"
+	NotifyMinIntervalEnvVar       = "CONFIG_R53DDNS_NOTIFY_MIN_INTERVAL"
+	PreUpdateHookEnvVar           = "CONFIG_R53DDNS_PRE_UPDATE_HOOK"
+	PostUpdateHookEnvVar          = "CONFIG_R53DDNS_POST_UPDATE_HOOK"
+	HookTimeoutEnvVar             = "CONFIG_R53DDNS_HOOK_TIMEOUT"
+	RoleARNEnvVar                 = "CONFIG_R53DDNS_ROLE_ARN"
+	ExternalIDEnvVar              = "CONFIG_R53DDNS_EXTERNAL_ID"
+	RoleSessionNameEnvVar         = "CONFIG_R53DDNS_ROLE_SESSION_NAME"
+	MFASerialEnvVar               = "CONFIG_R53DDNS_MFA_SERIAL"
+	MFATokenCommandEnvVar         = "CONFIG_R53DDNS_MFA_TOKEN_COMMAND"
+	WebIdentityTokenFileEnvVar    = "CONFIG_R53DDNS_WEB_IDENTITY_TOKEN_FILE"
+	AWSRetryModeEnvVar            = "CONFIG_R53DDNS_AWS_RETRY_MODE"
+	AWSMaxAttemptsEnvVar          = "CONFIG_R53DDNS_AWS_MAX_ATTEMPTS"
+	AWSRequestTimeoutEnvVar       = "CONFIG_R53DDNS_AWS_REQUEST_TIMEOUT"
+	AWSEndpointsEnvVar            = "CONFIG_R53DDNS_AWS_ENDPOINTS"
+	AWSUseFIPSEnvVar              = "CONFIG_R53DDNS_AWS_USE_FIPS"
+	STSRegionEnvVar               = "CONFIG_R53DDNS_STS_REGION"
+	VerifyPermissionsEnvVar       = "CONFIG_R53DDNS_VERIFY_PERMISSIONS"
+	SecretsRefreshIntervalEnvVar  = "CONFIG_R53DDNS_SECRETS_REFRESH_INTERVAL"
+	SSMParameterPathsEnvVar       = "CONFIG_R53DDNS_SSM_PARAMETER_PATHS"
+	SSMRefreshIntervalEnvVar      = "CONFIG_R53DDNS_SSM_REFRESH_INTERVAL"
+	ConfigURLEnvVar               = "CONFIG_R53DDNS_CONFIG_URL"
+	ConfigPollIntervalEnvVar      = "CONFIG_R53DDNS_CONFIG_POLL_INTERVAL"
+	IPSourceEnvVar                = "CONFIG_R53DDNS_IP_SOURCE"
+	DeleteOnStopEnvVar            = "CONFIG_R53DDNS_DELETE_ON_STOP"
+	StopIPEnvVar                  = "CONFIG_R53DDNS_STOP_IP"
+	DNSSECCheckIntervalEnvVar     = "CONFIG_R53DDNS_DNSSEC_CHECK_INTERVAL"
+	CloudflareAPITokenEnvVar      = "CONFIG_R53DDNS_CLOUDFLARE_API_TOKEN"
+	CloudflareAPIURLEnvVar        = "CONFIG_R53DDNS_CLOUDFLARE_API_URL"
+	CloudDNSProjectEnvVar         = "CONFIG_R53DDNS_CLOUDDNS_PROJECT"
+	CloudDNSCredentialsFileEnvVar = "CONFIG_R53DDNS_CLOUDDNS_CREDENTIALS_FILE"
+	CloudDNSAPIURLEnvVar          = "CONFIG_R53DDNS_CLOUDDNS_API_URL"
+	RFC2136ServerEnvVar           = "CONFIG_R53DDNS_RFC2136_SERVER"
+	RFC2136TSIGKeyEnvVar          = "CONFIG_R53DDNS_RFC2136_TSIG_KEY"
+	RFC2136TSIGSecretEnvVar       = "CONFIG_R53DDNS_RFC2136_TSIG_SECRET"
+	RFC2136TSIGAlgorithmEnvVar    = "CONFIG_R53DDNS_RFC2136_TSIG_ALGORITHM"
+	FakeZonesEnvVar               = "CONFIG_R53DDNS_FAKE_ZONES"
+	IPSourcePluginEnvVar          = "CONFIG_R53DDNS_IP_SOURCE_PLUGIN"
+	ProviderPluginsEnvVar         = "CONFIG_R53DDNS_PROVIDER_PLUGINS"
+	SchedulerEnvVar               = "CONFIG_R53DDNS_SCHEDULER"
+	ReconcileScheduleEnvVar       = "CONFIG_R53DDNS_RECONCILE_SCHEDULE"
+	DynDNSRecordsEnvVar           = "CONFIG_R53DDNS_DYNDNS_RECORDS"
+	DynDNSUsersEnvVar             = "CONFIG_R53DDNS_DYNDNS_USERS"
+	APITokenEnvVar                = "CONFIG_R53DDNS_API_TOKEN"
+	NSUpdateListenAddressEnvVar   = "CONFIG_R53DDNS_NSUPDATE_LISTEN_ADDRESS"
+	NSUpdateTSIGKeyEnvVar         = "CONFIG_R53DDNS_NSUPDATE_TSIG_KEY"
+	NSUpdateTSIGSecretEnvVar      = "CONFIG_R53DDNS_NSUPDATE_TSIG_SECRET"
+	NSUpdateTSIGAlgorithmEnvVar   = "CONFIG_R53DDNS_NSUPDATE_TSIG_ALGORITHM"
+	NSUpdateZonesEnvVar           = "CONFIG_R53DDNS_NSUPDATE_ZONES"
+	DDClientConfEnvVar            = "CONFIG_R53DDNS_DDCLIENT_CONF"
+	TriggerSecretEnvVar           = "CONFIG_R53DDNS_TRIGGER_SECRET"
+	KubernetesWatchEnvVar         = "CONFIG_R53DDNS_KUBERNETES_WATCH"
+	KubernetesNamespaceEnvVar     = "CONFIG_R53DDNS_KUBERNETES_NAMESPACE"
+	KubernetesAPIURLEnvVar        = "CONFIG_R53DDNS_KUBERNETES_API_URL"
+	KubernetesTokenEnvVar         = "CONFIG_R53DDNS_KUBERNETES_TOKEN"
+	KubernetesOperatorEnvVar      = "CONFIG_R53DDNS_KUBERNETES_OPERATOR"
+	DockerWatchEnvVar             = "CONFIG_R53DDNS_DOCKER_WATCH"
+	DockerHostEnvVar              = "CONFIG_R53DDNS_DOCKER_HOST"
+	TailnetRecordsEnvVar          = "CONFIG_R53DDNS_TAILNET_RECORDS"
+	TailnetInterfaceEnvVar        = "CONFIG_R53DDNS_TAILNET_INTERFACE"
+	ConsulTagEnvVar               = "CONFIG_R53DDNS_CONSUL_TAG"
+	ConsulAddressEnvVar           = "CONFIG_R53DDNS_CONSUL_ADDRESS"
+	ConsulTokenEnvVar             = "CONFIG_R53DDNS_CONSUL_TOKEN"
+	ConsulDomainEnvVar            = "CONFIG_R53DDNS_CONSUL_DOMAIN"
+	ConsulIntervalEnvVar          = "CONFIG_R53DDNS_CONSUL_INTERVAL"
+	MDNSEnvVar                    = "CONFIG_R53DDNS_MDNS"
+	MDNSNamesEnvVar               = "CONFIG_R53DDNS_MDNS_NAMES"
+	MDNSInterfaceEnvVar           = "CONFIG_R53DDNS_MDNS_INTERFACE"
+	ACMEDNSDomainEnvVar           = "CONFIG_R53DDNS_ACMEDNS_DOMAIN"
+	ACMEDNSStoreEnvVar            = "CONFIG_R53DDNS_ACMEDNS_STORE"
+	ACMEDNSAllowRegisterEnvVar    = "CONFIG_R53DDNS_ACMEDNS_ALLOW_REGISTER_FROM"
+	LeaderLockEnvVar              = "CONFIG_R53DDNS_LEADER_LOCK"
+	LeaderIdentityEnvVar          = "CONFIG_R53DDNS_LEADER_IDENTITY"
+	LeaderLeaseDurationEnvVar     = "CONFIG_R53DDNS_LEADER_LEASE_DURATION"
+	OwnerIDEnvVar                 = "CONFIG_R53DDNS_OWNER_ID"
+	CloudWatchNamespaceEnvVar     = "CONFIG_R53DDNS_CLOUDWATCH_NAMESPACE"
+	DefaultRoleSessionName        = "route53ddns"
+	DefaultDaemonPIDFile          = "/var/run/route53ddns.pid"
+	DefaultDaemonLogFile          = "/var/log/route53ddns.log"
+	DefaultLogMaxSize             = 10
+	DefaultLogMaxBackups          = 3
+)
+
+// sources of the address to publish
+const (
+	IPSourceURL    = "url"
+	IPSourceECS    = "ecs"
+	IPSourceEC2    = "ec2"
+	IPSourcePlugin = "plugin"
+)
+
+// builtinProviders are the providers plugins can't be named after
+var builtinProviders = []string{updater.ProviderRoute53, updater.ProviderCloudflare, updater.ProviderCloudDNS,
+	updater.ProviderRFC2136, updater.ProviderFake}
+
+// notifierNames are the notifiers templates and event filters may be set for in
+// CONFIG_R53DDNS_<NOTIFIER>_TITLE_TEMPLATE, _BODY_TEMPLATE and _EVENTS
+var notifierNames = []string{"webhook", "discord", "telegram", "smtp", "ses", "sns", "eventbridge", "pagerduty",
+	"ntfy", "pushover", "mqtt", "gotify", "matrix"}
+
+var (
+	// secretsRefreshInterval is how often secrets are read again, zero reads them at startup only
+	secretsRefreshInterval time.Duration
+	// parametersRefreshInterval is how often ssm parameters are read again, zero reads them at startup only
+	parametersRefreshInterval time.Duration
+	// configPollInterval is how often the configuration object is checked for changes, zero reads it at
+	// startup only
+	configPollInterval time.Duration
+)
+
+// runningInLambda reports whether the process was started by the lambda runtime
+func runningInLambda() bool {
+	return os.Getenv("AWS_LAMBDA_RUNTIME_API") != ""
+}
+
+// loadConfig configures the daemon from the environment, returning the configuration of its updater
+func loadConfig() updater.Config {
+	// initialize structured logging first so configuration errors are reported in the same format
+	logFormat = envString(LogFormatEnvVar, LogFormatJSON)
+	setupLogger(os.Stderr)
+	if logFormat != LogFormatJSON && logFormat != LogFormatText {
+		fatal("environmental variable must be one of json or text", "variable", LogFormatEnvVar)
+	}
+	level, err := parseLogLevel(envString(LogLevelEnvVar, "info"))
+	if err != nil {
+		fatal("environmental variable must be one of debug, info, warn or error", "variable", LogLevelEnvVar)
+	}
+	logLevel.Set(level)
+
+	// migrate from ddclient by reading its hosts and address detection from its configuration file
+	if ddclientConfPath != "" {
+		values, err := readDDClientConfig(ddclientConfPath)
+		if err != nil {
+			fatal("unable to read ddclient configuration", "path", ddclientConfPath, "error", err)
+		}
+		if _, err := ddclientOverlay.apply(values); err != nil {
+			fatal("unable to apply ddclient configuration", "path", ddclientConfPath, "error", err)
+		}
+	}
+
+	// load AWS configuration, the updater logs, audits and measures every call made with it
+	options, err := awsLoadOptions()
+	if err != nil {
+		fatal("unable to configure aws endpoints", "error", err)
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background(), options...)
+	if err != nil {
+		fatal("unable to load aws configuration", "error", err)
+	}
+
+	// load configuration kept in ssm parameters, read with the daemon's own credentials so they may
+	// configure the role to assume
+	parameterPaths = envList(SSMParameterPathsEnvVar)
+	if len(parameterPaths) > 0 {
+		parametersConfig = cfg
+		values, err := readParameters(context.Background(), parametersConfig, parameterPaths)
+		if err != nil {
+			fatal("unable to read configuration parameters", "variable", SSMParameterPathsEnvVar, "error", err)
+		}
+		if _, err := parameterOverlay.apply(values); err != nil {
+			fatal("unable to apply configuration parameters", "variable", SSMParameterPathsEnvVar, "error", err)
+		}
+	}
+	parametersRefreshInterval = envDuration(SSMRefreshIntervalEnvVar, 0)
+	if parametersRefreshInterval < 0 {
+		fatal("environmental variable must not be negative", "variable", SSMRefreshIntervalEnvVar)
+	}
+
+	// load configuration kept in an s3 object the same way
+	if configURL := os.Getenv(ConfigURLEnvVar); configURL != "" {
+		source, err := newS3ConfigSource(cfg, configURL)
+		if err != nil {
+			fatal("environmental variable is not a valid url", "variable", ConfigURLEnvVar, "error", err)
+		}
+		if _, err := source.load(context.Background()); err != nil {
+			fatal("unable to load configuration object", "variable", ConfigURLEnvVar, "error", err)
+		}
+		s3Config = source
+	}
+	configPollInterval = envDuration(ConfigPollIntervalEnvVar, 0)
+	if configPollInterval < 0 {
+		fatal("environmental variable must not be negative", "variable", ConfigPollIntervalEnvVar)
+	}
+
+	// replace variables referencing secrets manager with their secrets, read with the daemon's own
+	// credentials so the role to assume may itself be a secret
+	secretsConfig = cfg
+	secretReferences = findSecretReferences()
+	if len(secretReferences) > 0 {
+		if _, err := resolveSecrets(context.Background(), secretsConfig, secretReferences); err != nil {
+			fatal("unable to resolve secrets", "error", err)
+		}
+	}
+	secretsRefreshInterval = envDuration(SecretsRefreshIntervalEnvVar, 0)
+	if secretsRefreshInterval < 0 {
+		fatal("environmental variable must not be negative", "variable", SecretsRefreshIntervalEnvVar)
+	}
+
+	// assume a tightly scoped role in the dns account, either with a web identity token or with
+	// whatever credentials were found
+	roleARN := os.Getenv(RoleARNEnvVar)
+	if roleARN != "" {
+		if _, err := arn.Parse(roleARN); err != nil {
+			fatal("environmental variable is not a valid arn", "variable", RoleARNEnvVar, "error", err)
+		}
+	}
+	if tokenFile := os.Getenv(WebIdentityTokenFileEnvVar); tokenFile != "" {
+		if roleARN == "" {
+			fatal("environmental variable requires a role to assume", "variable", WebIdentityTokenFileEnvVar, "requires", RoleARNEnvVar)
+		}
+		cfg, err = webIdentityRole(cfg, roleARN, tokenFile, envString(RoleSessionNameEnvVar, DefaultRoleSessionName))
+		if err != nil {
+			fatal("unable to read web identity token", "variable", WebIdentityTokenFileEnvVar, "error", err)
+		}
+
+		// fail at startup rather than on the first cycle when the token or trust policy is wrong
+		ctx, cancel := context.WithTimeout(context.Background(), credentialsTimeout)
+		_, err = cfg.Credentials.Retrieve(ctx)
+		cancel()
+		if err != nil {
+			fatal("unable to assume role with web identity", "role_arn", roleARN, "error", err)
+		}
+	} else if roleARN != "" {
+		var tokenProvider func() (string, error)
+		if os.Getenv(MFASerialEnvVar) != "" {
+			tokenProvider, err = mfaTokenProvider(os.Getenv(MFATokenCommandEnvVar))
+			if err != nil {
+				fatal("unable to read mfa codes", "variable", MFASerialEnvVar, "requires", MFATokenCommandEnvVar, "error", err)
+			}
+		}
+		cfg = assumeRole(cfg, roleARN, os.Getenv(ExternalIDEnvVar), envString(RoleSessionNameEnvVar, DefaultRoleSessionName),
+			os.Getenv(MFASerialEnvVar), tokenProvider)
+	} else if os.Getenv(MFASerialEnvVar) != "" {
+		fatal("environmental variable requires a role to assume", "variable", MFASerialEnvVar, "requires", RoleARNEnvVar)
+	}
+
+	// initialize log output target
+	switch output := envString(LogOutputEnvVar, LogOutputStderr); output {
+	case LogOutputStderr:
+	case LogOutputSyslog:
+		handler, err := newSyslogHandler(os.Getenv(SyslogAddressEnvVar))
+		if err != nil {
+			fatal("unable to connect to syslog", "error", err)
+		}
+		slog.SetDefault(slog.New(handler))
+	case LogOutputJournald:
+		handler, err := newJournaldHandler()
+		if err != nil {
+			fatal("unable to connect to journald", "error", err)
+		}
+		slog.SetDefault(slog.New(handler))
+	case LogOutputCloudWatch:
+		hostname, _ := os.Hostname()
+		writer, err := newCloudWatchLogsWriter(cfg, os.Getenv(CloudWatchLogGroupEnvVar),
+			envString(CloudWatchLogStreamEnvVar, hostname))
+		if err != nil {
+			fatal("unable to connect to cloudwatch logs", "error", err)
+		}
+		setupLogger(io.MultiWriter(os.Stderr, writer))
+		flushLogs = func() {
+			writer.Flush(cloudWatchTimeout)
+		}
+	default:
+		fatal("environmental variable must be one of stderr, syslog, journald or cloudwatch", "variable", LogOutputEnvVar, "value", output)
+	}
+
+	// initialize records, the hostname is the first and more may be listed with their own profiles
+	if os.Getenv(FQDNEnvVar) == "" && os.Getenv(RecordsEnvVar) == "" {
+		fatal("environmental variable is not set", "variable", FQDNEnvVar)
+	}
+	entries := recordEntriesFromEnv()
+	if _, err := updater.ParseRecords(entries); err != nil {
+		fatal("unable to parse records", "variables", []string{FQDNEnvVar, RecordsEnvVar}, "error", err)
+	}
+
+	// initialize where the address comes from, lambda invocations may supply the address instead
+	var source ipsource.Source
+	switch kind := envString(IPSourceEnvVar, IPSourceURL); kind {
+	case IPSourceURL:
+		if ipURL := os.Getenv(PublicIPURL); ipURL != "" {
+			source = ipsource.NewURL(ipURL, nil)
+		} else if !runningInLambda() {
+			fatal("environmental variable is not set", "variable", PublicIPURL)
+		}
+	case IPSourceECS:
+		source = ipsource.NewECS(cfg)
+	case IPSourceEC2:
+		source = ipsource.NewEC2(cfg)
+	case IPSourcePlugin:
+		path := os.Getenv(IPSourcePluginEnvVar)
+		if path == "" {
+			fatal("environmental variable is not set", "variable", IPSourcePluginEnvVar)
+		}
+		source = ipsource.NewPlugin(path)
+	default:
+		fatal("environmental variable must be one of url, ecs, ec2 or plugin", "variable", IPSourceEnvVar, "value", kind)
+	}
+
+	// durations the updater would otherwise replace with its defaults
+	cycleTimeout := envDuration(CycleTimeoutEnvVar, updater.DefaultCycleTimeout)
+	if cycleTimeout <= 0 {
+		fatal("environmental variable must be greater than zero", "variable", CycleTimeoutEnvVar)
+	}
+	reconcileInterval := envDuration(ReconcileIntervalEnvVar, updater.UpdateInterval)
+	if reconcileInterval <= 0 {
+		fatal("environmental variable must be greater than zero", "variable", ReconcileIntervalEnvVar)
+	}
+	hookTimeout := envDuration(HookTimeoutEnvVar, updater.DefaultHookTimeout)
+	if hookTimeout <= 0 {
+		fatal("environmental variable must be greater than zero", "variable", HookTimeoutEnvVar)
+	}
+	consulInterval := envDuration(ConsulIntervalEnvVar, updater.DefaultConsulInterval)
+	if consulInterval <= 0 {
+		fatal("environmental variable must be positive", "variable", ConsulIntervalEnvVar)
+	}
+
+	// initialize the location schedules and quiet windows are evaluated in
+	location, err := time.LoadLocation(envString(ScheduleTimezoneEnvVar, "UTC"))
+	if err != nil {
+		fatal("environmental variable is not a valid time zone", "variable", ScheduleTimezoneEnvVar, "error", err)
+	}
+
+	// initialize the providers records outside route53 may name
+	configuredProviders := map[string]dns.Provider{}
+	if token := os.Getenv(CloudflareAPITokenEnvVar); token != "" {
+		configuredProviders[updater.ProviderCloudflare] = dns.NewCloudflare(token, os.Getenv(CloudflareAPIURLEnvVar), nil)
+	}
+	if project, credentialsFile := os.Getenv(CloudDNSProjectEnvVar), os.Getenv(CloudDNSCredentialsFileEnvVar); project != "" || credentialsFile != "" {
+		client, credentialsProject, err := dns.GoogleClient(context.Background(), credentialsFile)
+		if err != nil {
+			fatal("unable to load google credentials", "variable", CloudDNSCredentialsFileEnvVar, "error", err)
+		}
+		if project == "" {
+			project = credentialsProject
+		}
+		if project == "" {
+			fatal("environmental variable is not set", "variable", CloudDNSProjectEnvVar)
+		}
+		configuredProviders[updater.ProviderCloudDNS] = dns.NewCloudDNS(project, os.Getenv(CloudDNSAPIURLEnvVar), client)
+	}
+	if server := os.Getenv(RFC2136ServerEnvVar); server != "" {
+		provider, err := dns.NewRFC2136(server, os.Getenv(RFC2136TSIGKeyEnvVar), os.Getenv(RFC2136TSIGAlgorithmEnvVar),
+			os.Getenv(RFC2136TSIGSecretEnvVar))
+		if err != nil {
+			fatal("unable to configure dynamic updates", "variable", RFC2136ServerEnvVar, "error", err)
+		}
+		configuredProviders[updater.ProviderRFC2136] = provider
+	}
+	// the fake provider keeps its zones in memory, for demos and trying out a configuration
+	if zones := envList(FakeZonesEnvVar); len(zones) > 0 {
+		configuredProviders[updater.ProviderFake] = dns.NewFake(zones...)
+	}
+	// plugins are listed as name=path, records name the plugin updating them by its name
+	for _, entry := range envList(ProviderPluginsEnvVar) {
+		name, path, _ := strings.Cut(entry, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || strings.TrimSpace(path) == "" {
+			fatal("environmental variable must list plugins as name=path", "variable", ProviderPluginsEnvVar, "value", entry)
+		}
+		if _, ok := configuredProviders[name]; ok || slices.Contains(builtinProviders, name) {
+			fatal("plugin name is already taken by another provider", "variable", ProviderPluginsEnvVar, "name", name)
+		}
+		configuredProviders[name] = dns.NewPlugin(name, strings.TrimSpace(path))
+	}
+
+	// records pushed by dyndns2 clients are served on the listen address to the configured users
+	users, err := parseDynDNSUsers(envList(DynDNSUsersEnvVar))
+	if err != nil {
+		fatal("unable to parse dyndns users", "variable", DynDNSUsersEnvVar, "error", err)
+	}
+
+	notifications, err := notificationsFromEnv()
+	if err != nil {
+		fatal("unable to configure notifications", "error", err)
+	}
+
+	return updater.Config{
+		Records:  entries,
+		IPSource: source,
+		AWS:      cfg,
+		// records with a profile load their configuration with the same endpoints
+		AWSOptions: options,
+		Retry: dns.RetryOptions{
+			Mode:           envString(AWSRetryModeEnvVar, dns.RetryModeStandard),
+			MaxAttempts:    envInt(AWSMaxAttemptsEnvVar, 0),
+			RequestTimeout: envDuration(AWSRequestTimeoutEnvVar, 0),
+		},
+		Providers:           configuredProviders,
+		ReconcileInterval:   reconcileInterval,
+		CheckInterval:       envDuration(CheckIntervalEnvVar, 0),
+		CycleTimeout:        cycleTimeout,
+		ReadyMaxAge:         envDuration(ReadyMaxAgeEnvVar, 0),
+		Location:            location,
+		QuietWindows:        envList(QuietWindowsEnvVar),
+		Scheduler:           envString(SchedulerEnvVar, updater.SchedulerGocron),
+		ReconcileSchedule:   os.Getenv(ReconcileScheduleEnvVar),
+		DryRun:              envBool(DryRunEnvVar, false),
+		VerifyPermissions:   envBool(VerifyPermissionsEnvVar, false),
+		StopIP:              os.Getenv(StopIPEnvVar),
+		DeleteOnStop:        envBool(DeleteOnStopEnvVar, false),
+		FailureThreshold:    envInt(FailureThresholdEnvVar, updater.DefaultFailureThreshold),
+		FailureAction:       envString(FailureActionEnvVar, updater.DefaultFailureAction),
+		StateFile:           os.Getenv(StateFileEnvVar),
+		JournalFile:         os.Getenv(JournalFileEnvVar),
+		AuditLog:            os.Getenv(AuditLogEnvVar),
+		DNSSECCheckInterval: envDuration(DNSSECCheckIntervalEnvVar, updater.DefaultDNSSECCheckInterval),
+		CloudWatchNamespace: os.Getenv(CloudWatchNamespaceEnvVar),
+		QuietSteadyState:    envBool(QuietSteadyStateEnvVar, false),
+		ListenAddress:       os.Getenv(ListenAddressEnvVar),
+		Hooks: updater.HooksConfig{
+			PreUpdate:  os.Getenv(PreUpdateHookEnvVar),
+			PostUpdate: os.Getenv(PostUpdateHookEnvVar),
+			Timeout:    hookTimeout,
+		},
+		Monitors: updater.MonitorsConfig{
+			HealthchecksURL: os.Getenv(HealthcheckURLEnvVar),
+			UptimeKumaURL:   os.Getenv(UptimeKumaURLEnvVar),
+		},
+		Statsd: updater.StatsdConfig{
+			Address: os.Getenv(StatsdAddressEnvVar),
+			Prefix:  envString(StatsdPrefixEnvVar, updater.DefaultStatsdPrefix),
+			Flavor:  envString(StatsdFlavorEnvVar, updater.StatsdFlavorDogStatsd),
+			Tags:    envList(StatsdTagsEnvVar),
+		},
+		Tracing: updater.TracingConfig{
+			Endpoint: os.Getenv(OTLPEndpointEnvVar),
+			XRay:     envBool(XRayEnvVar, false),
+		},
+		Notifications: notifications,
+		DynDNSRecords: envList(DynDNSRecordsEnvVar),
+		DynDNSUsers:   users,
+		APIToken:      os.Getenv(APITokenEnvVar),
+		TriggerSecret: os.Getenv(TriggerSecretEnvVar),
+		// challenge records of acme-dns accounts are created under the domain
+		ACMEDNS: updater.ACMEDNSConfig{
+			Domain:            os.Getenv(ACMEDNSDomainEnvVar),
+			Store:             os.Getenv(ACMEDNSStoreEnvVar),
+			AllowRegisterFrom: envList(ACMEDNSAllowRegisterEnvVar),
+		},
+		// only the replica holding the lock updates the records, the others stand by to take over
+		Leader: updater.LeaderConfig{
+			Lock:          os.Getenv(LeaderLockEnvVar),
+			Identity:      os.Getenv(LeaderIdentityEnvVar),
+			LeaseDuration: envDuration(LeaderLeaseDurationEnvVar, updater.DefaultLeaseDuration),
+		},
+		OwnerID: os.Getenv(OwnerIDEnvVar),
+		// annotated services and ingresses and DDNSRecord resources get records of their own, read from
+		// the api server of the cluster the daemon runs in unless another is configured
+		Kubernetes: updater.KubernetesConfig{
+			Watch:     envList(KubernetesWatchEnvVar),
+			Operator:  envBool(KubernetesOperatorEnvVar, false),
+			Namespace: os.Getenv(KubernetesNamespaceEnvVar),
+			APIURL:    os.Getenv(KubernetesAPIURLEnvVar),
+			Token:     os.Getenv(KubernetesTokenEnvVar),
+		},
+		Docker: updater.DockerConfig{
+			Watch: envBool(DockerWatchEnvVar, false),
+			Host:  envString(DockerHostEnvVar, os.Getenv("DOCKER_HOST")),
+		},
+		Tailnet: updater.TailnetConfig{
+			Records:   envList(TailnetRecordsEnvVar),
+			Interface: os.Getenv(TailnetInterfaceEnvVar),
+		},
+		Consul: updater.ConsulConfig{
+			Tag:      os.Getenv(ConsulTagEnvVar),
+			Address:  envString(ConsulAddressEnvVar, os.Getenv("CONSUL_HTTP_ADDR")),
+			Token:    envString(ConsulTokenEnvVar, os.Getenv("CONSUL_HTTP_TOKEN")),
+			Domain:   os.Getenv(ConsulDomainEnvVar),
+			Interval: consulInterval,
+		},
+		MDNS: updater.MDNSConfig{
+			Enabled:   envBool(MDNSEnvVar, false),
+			Names:     envList(MDNSNamesEnvVar),
+			Interface: os.Getenv(MDNSInterfaceEnvVar),
+		},
+		NSUpdate: updater.NSUpdateConfig{
+			Address:   os.Getenv(NSUpdateListenAddressEnvVar),
+			KeyName:   os.Getenv(NSUpdateTSIGKeyEnvVar),
+			Secret:    os.Getenv(NSUpdateTSIGSecretEnvVar),
+			Algorithm: os.Getenv(NSUpdateTSIGAlgorithmEnvVar),
+			Zones:     envList(NSUpdateZonesEnvVar),
+		},
+	}
+}
+
+// recordEntriesFromEnv returns the records named by the hostname and records variables
+func recordEntriesFromEnv() []string {
+	return append(envList(FQDNEnvVar), envList(RecordsEnvVar)...)
+}
+
+// notificationsFromEnv returns the notification settings of the environment, it is read again when a
+// configuration source changes so it returns an error instead of exiting
+func notificationsFromEnv() (updater.NotificationsConfig, error) {
+	pushoverPriority, err := parseEnvInt(PushoverPriorityEnvVar, 0)
+	if err != nil {
+		return updater.NotificationsConfig{}, fmt.Errorf("%s: %w", "unable to configure pushover", err)
+	}
+	mqttQoS, err := parseEnvInt(MQTTQoSEnvVar, 0)
+	if err != nil {
+		return updater.NotificationsConfig{}, fmt.Errorf("%s: %w", "unable to configure mqtt", err)
+	}
+	gotifyPriority, err := parseEnvInt(GotifyPriorityEnvVar, updater.DefaultGotifyPriority)
+	if err != nil {
+		return updater.NotificationsConfig{}, fmt.Errorf("%s: %w", "unable to configure gotify", err)
+	}
+	failureRepeat, err := parseEnvInt(NotifyFailureRepeatEnvVar, 0)
+	if err != nil {
+		return updater.NotificationsConfig{}, err
+	}
+	minInterval, err := time.ParseDuration(envString(NotifyMinIntervalEnvVar, "0s"))
+	if err != nil {
+		return updater.NotificationsConfig{}, fmt.Errorf("%s %s: %w", "environmental variable is not a valid duration", NotifyMinIntervalEnvVar, err)
+	}
+
+	// CONFIG_R53DDNS_<NOTIFIER>_TITLE_TEMPLATE, _BODY_TEMPLATE and _EVENTS apply to a single notifier,
+	// e.g. CONFIG_R53DDNS_PAGERDUTY_EVENTS=update-failed,recovered
+	templates := map[string]updater.NotificationTemplates{}
+	events := map[string][]string{}
+	for _, name := range notifierNames {
+		prefix := "CONFIG_R53DDNS_" + strings.ToUpper(name) + "_"
+		if title, body := os.Getenv(prefix+"TITLE_TEMPLATE"), os.Getenv(prefix+"BODY_TEMPLATE"); title != "" || body != "" {
+			templates[name] = updater.NotificationTemplates{Title: title, Body: body}
+		}
+		if filter := envList(prefix + "EVENTS"); len(filter) > 0 {
+			events[name] = filter
+		}
+	}
+
+	return updater.NotificationsConfig{
+		WebhookURLs:       envList(WebhookURLsEnvVar),
+		WebhookPayload:    os.Getenv(WebhookPayloadEnvVar),
+		DiscordWebhookURL: os.Getenv(DiscordWebhookURLEnvVar),
+		Telegram: updater.TelegramConfig{
+			BotToken: os.Getenv(TelegramBotTokenEnvVar),
+			ChatID:   os.Getenv(TelegramChatIDEnvVar),
+		},
+		SMTP: updater.SMTPConfig{
+			Address:  os.Getenv(SMTPAddressEnvVar),
+			Security: envString(SMTPSecurityEnvVar, updater.DefaultSMTPSecurity),
+			Username: os.Getenv(SMTPUsernameEnvVar),
+			Password: os.Getenv(SMTPPasswordEnvVar),
+			From:     os.Getenv(SMTPFromEnvVar),
+			To:       envList(SMTPToEnvVar),
+		},
+		SES: updater.SESConfig{
+			From: os.Getenv(SESFromEnvVar),
+			To:   envList(SESToEnvVar),
+		},
+		SNSTopicARN:         os.Getenv(SNSTopicARNEnvVar),
+		EventBridgeBus:      os.Getenv(EventBridgeBusEnvVar),
+		PagerDutyRoutingKey: os.Getenv(PagerDutyRoutingKeyEnvVar),
+		NTFY: updater.NTFYConfig{
+			URL:      os.Getenv(NTFYURLEnvVar),
+			Token:    os.Getenv(NTFYTokenEnvVar),
+			Priority: os.Getenv(NTFYPriorityEnvVar),
+			Tags:     envList(NTFYTagsEnvVar),
+		},
+		Pushover: updater.PushoverConfig{
+			Token:    os.Getenv(PushoverTokenEnvVar),
+			User:     os.Getenv(PushoverUserEnvVar),
+			Priority: pushoverPriority,
+		},
+		MQTT: updater.MQTTConfig{
+			Broker:   os.Getenv(MQTTBrokerEnvVar),
+			Topic:    envString(MQTTTopicEnvVar, updater.DefaultMQTTTopic),
+			QoS:      mqttQoS,
+			Username: os.Getenv(MQTTUsernameEnvVar),
+			Password: os.Getenv(MQTTPasswordEnvVar),
+			CAFile:   os.Getenv(MQTTCAFileEnvVar),
+		},
+		Gotify: updater.GotifyConfig{
+			URL:      os.Getenv(GotifyURLEnvVar),
+			Token:    os.Getenv(GotifyTokenEnvVar),
+			Priority: gotifyPriority,
+		},
+		Matrix: updater.MatrixConfig{
+			Homeserver:  os.Getenv(MatrixHomeserverEnvVar),
+			AccessToken: os.Getenv(MatrixAccessTokenEnvVar),
+			RoomID:      os.Getenv(MatrixRoomIDEnvVar),
+		},
+		TitleTemplate: os.Getenv(NotifyTitleTemplateEnvVar),
+		BodyTemplate:  os.Getenv(NotifyBodyTemplateEnvVar),
+		Templates:     templates,
+		Events:        events,
+		FailureRepeat: failureRepeat,
+		MinInterval:   minInterval,
+	}, nil
+}
+
+// parseDynDNSUsers parses comma separated user:password pairs
+func parseDynDNSUsers(entries []string) (map[string]string, error) {
+	users := map[string]string{}
+	for _, entry := range entries {
+		user, password, found := strings.Cut(entry, ":")
+		if !found || user == "" || password == "" {
+			return nil, fmt.Errorf("%s: %q", "not a valid user:password pair", user)
+		}
+		users[user] = password
+	}
+
+	return users, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/rgravlin/route53ddns/pkg/updater"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// history output formats
+const (
+	HistoryFormatTable = "table"
+	HistoryFormatCSV   = "csv"
+	HistoryFormatJSON  = "json"
+)
+
+// historyStats summarizes ip changes over the selected range
+type historyStats struct {
+	Changes              int     `json:"changes"`
+	Submissions          int     `json:"submissions"`
+	FailedSubmissions    int     `json:"failed_submissions"`
+	ChangesPerWeek       float64 `json:"changes_per_week"`
+	AverageLeaseDuration string  `json:"average_lease_duration,omitempty"`
+}
+
+// historyReport is the json export of the history command
+type historyReport struct {
+	Entries []updater.JournalEntry `json:"entries"`
+	Stats   historyStats           `json:"stats"`
+}
+
+// runHistoryCommand prints or exports journal entries within a time range along with change statistics
+func runHistoryCommand(args []string) error {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	path := flags.String("journal", os.Getenv(JournalFileEnvVar), "change journal to read")
+	since := flags.String("since", "", "only include entries at or after this time, RFC3339, YYYY-MM-DD or a duration ago such as 720h")
+	until := flags.String("until", "", "only include entries before this time, in the same formats as --since")
+	format := flags.String("format", HistoryFormatTable, "output format, one of table, csv or json")
+	submissions := flags.Bool("submissions", false, "include route53 submissions as well as detected changes")
+	_ = flags.Parse(args)
+
+	if *path == "" {
+		return fmt.Errorf("%s, set %s or --journal", "no journal configured", JournalFileEnvVar)
+	}
+
+	now := time.Now()
+	from, err := parseHistoryTime(*since, now)
+	if err != nil {
+		return err
+	}
+	to, err := parseHistoryTime(*until, now)
+	if err != nil {
+		return err
+	}
+
+	entries, err := readJournal(*path)
+	if err != nil {
+		return err
+	}
+
+	var selected []updater.JournalEntry
+	for _, entry := range entries {
+		if (!from.IsZero() && entry.Time.Before(from)) || (!to.IsZero() && !entry.Time.Before(to)) {
+			continue
+		}
+		if entry.Event != updater.JournalEventDetected && !*submissions {
+			continue
+		}
+		selected = append(selected, entry)
+	}
+
+	if to.IsZero() {
+		to = now
+	}
+	stats := summarizeHistory(entries, from, to)
+
+	switch *format {
+	case HistoryFormatTable:
+		return writeHistoryTable(os.Stdout, selected, stats)
+	case HistoryFormatCSV:
+		return writeHistoryCSV(os.Stdout, selected)
+	case HistoryFormatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(historyReport{Entries: selected, Stats: stats})
+	}
+
+	return fmt.Errorf("%s: %s", "format must be one of table, csv or json", *format)
+}
+
+// parseHistoryTime parses an absolute time or a duration before now, an empty value is the zero time
+func parseHistoryTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+
+	return time.Time{}, fmt.Errorf("%s: %s", "not a valid time, date or duration", value)
+}
+
+// readJournal reads every entry in the journal at path, skipping lines that cannot be decoded
+func readJournal(path string) ([]updater.JournalEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	var entries []updater.JournalEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry updater.JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// summarizeHistory computes change statistics for entries between from and to, lease durations are
+// measured between consecutive detected changes
+func summarizeHistory(entries []updater.JournalEntry, from, to time.Time) historyStats {
+	var stats historyStats
+	var first, previous time.Time
+	var leases time.Duration
+	var leaseCount int
+
+	for _, entry := range entries {
+		if (!from.IsZero() && entry.Time.Before(from)) || !entry.Time.Before(to) {
+			continue
+		}
+
+		switch entry.Event {
+		case updater.JournalEventSubmitted:
+			stats.Submissions++
+			if entry.Result == updater.JournalResultFailed {
+				stats.FailedSubmissions++
+			}
+		case updater.JournalEventDetected:
+			// the first detection after a fresh start has nothing to change from
+			if entry.OldIP == "" {
+				continue
+			}
+			stats.Changes++
+			if first.IsZero() {
+				first = entry.Time
+			}
+			if !previous.IsZero() {
+				leases += entry.Time.Sub(previous)
+				leaseCount++
+			}
+			previous = entry.Time
+		}
+	}
+
+	start := from
+	if start.IsZero() {
+		start = first
+	}
+	if weeks := to.Sub(start).Hours() / (24 * 7); !start.IsZero() && weeks > 0 {
+		stats.ChangesPerWeek = float64(stats.Changes) / weeks
+	}
+	if leaseCount > 0 {
+		stats.AverageLeaseDuration = (leases / time.Duration(leaseCount)).Round(time.Minute).String()
+	}
+
+	return stats
+}
+
+// writeHistoryTable prints entries as aligned columns followed by the statistics
+func writeHistoryTable(w io.Writer, entries []updater.JournalEntry, stats historyStats) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "TIME\tEVENT\tFQDN\tOLD IP\tNEW IP\tRESULT\tCHANGE ID")
+	for _, e := range entries {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Time.Local().Format(time.DateTime),
+			e.Event, e.FQDN, e.OldIP, e.NewIP, e.Result, e.ChangeID)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	lease := stats.AverageLeaseDuration
+	if lease == "" {
+		lease = "n/a"
+	}
+
+	_, err := fmt.Fprintf(w, "\nchanges: %d  submissions: %d (%d failed)  changes per week: %.2f  average lease: %s\n",
+		stats.Changes, stats.Submissions, stats.FailedSubmissions, stats.ChangesPerWeek, lease)
+
+	return err
+}
+
+// writeHistoryCSV exports entries as csv with a header row
+func writeHistoryCSV(w io.Writer, entries []updater.JournalEntry) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"time", "event", "fqdn", "old_ip", "new_ip", "result", "change_id", "error"})
+	for _, e := range entries {
+		_ = cw.Write([]string{e.Time.Format(time.RFC3339), e.Event, e.FQDN, e.OldIP, e.NewIP, e.Result, e.ChangeID, e.Error})
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/rgravlin/route53ddns/pkg/dns"
+	"github.com/rgravlin/route53ddns/pkg/updater"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// iamPolicy is an iam policy document
+type iamPolicy struct {
+	Version   string         `json:"Version"`
+	Statement []iamStatement `json:"Statement"`
+}
+
+// iamStatement is a single statement of an iam policy document
+type iamStatement struct {
+	Sid       string                    `json:"Sid"`
+	Effect    string                    `json:"Effect"`
+	Action    []string                  `json:"Action"`
+	Resource  []string                  `json:"Resource"`
+	Condition map[string]map[string]any `json:"Condition,omitempty"`
+}
+
+// zoneIDList collects repeated --zone-id flags
+type zoneIDList []string
+
+func (l *zoneIDList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *zoneIDList) Set(value string) error {
+	*l = append(*l, strings.TrimPrefix(value, "/hostedzone/"))
+	return nil
+}
+
+// runIAMPolicyCommand prints the least privilege iam policy for the configured records and
+// features, scoped to the hosted zones holding the records of a single account
+func runIAMPolicyCommand(args []string) error {
+	flags := flag.NewFlagSet("iam-policy", flag.ExitOnError)
+	var zoneIDs zoneIDList
+	flags.Var(&zoneIDs, "zone-id", "hosted zone holding the records, may be repeated")
+	profile := flags.String("profile", "", "only include records updated with this profile, records without a profile by default")
+	path := flags.String("state-file", os.Getenv(StateFileEnvVar), "state file or s3:// or dynamodb:// state url to read zone ids from")
+	lookup := flags.Bool("lookup", false, "look up zone ids in route53 with the current credentials")
+	_ = flags.Parse(args)
+
+	all, err := updater.ParseRecords(recordEntriesFromEnv())
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, record := range all {
+		if record.Provider == updater.ProviderRoute53 && record.Profile == *profile {
+			names = append(names, strings.ToLower(record.FQDN))
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("%s, set %s or %s", "no records configured for this profile", FQDNEnvVar, RecordsEnvVar)
+	}
+
+	// zone ids from the state file, then from route53, when none were given
+	if len(zoneIDs) == 0 && *path != "" {
+		report, err := readStatus(*path)
+		if err != nil {
+			return err
+		}
+		for _, record := range report.Records {
+			if record.ZoneID != "" && slices.Contains(names, strings.ToLower(record.FQDN)) {
+				zoneIDs = append(zoneIDs, record.ZoneID)
+			}
+		}
+	}
+	if len(zoneIDs) == 0 && *lookup {
+		if zoneIDs, err = lookupZoneIDs(*profile, names); err != nil {
+			return err
+		}
+	}
+
+	zoneARNs := []string{"arn:aws:route53:::hostedzone/*"}
+	if len(zoneIDs) > 0 {
+		zoneARNs = nil
+		for _, zoneID := range uniqueStrings(zoneIDs) {
+			zoneARNs = append(zoneARNs, "arn:aws:route53:::hostedzone/"+zoneID)
+		}
+	} else {
+		_, _ = fmt.Fprintln(os.Stderr, "zone ids are unknown, the policy applies to every hosted zone, pass --zone-id or --lookup to narrow it")
+	}
+
+	policy := iamPolicy{Version: "2012-10-17", Statement: []iamStatement{
+		{
+			Sid:      "FindHostedZones",
+			Effect:   "Allow",
+			Action:   []string{"route53:ListHostedZonesByName"},
+			Resource: []string{"*"},
+		},
+		{
+			Sid:      "ReadRecords",
+			Effect:   "Allow",
+			Action:   readActions(),
+			Resource: zoneARNs,
+		},
+		{
+			Sid:      "UpsertRecords",
+			Effect:   "Allow",
+			Action:   []string{"route53:ChangeResourceRecordSets"},
+			Resource: zoneARNs,
+			Condition: map[string]map[string]any{"ForAllValues:StringEquals": {
+				"route53:ChangeResourceRecordSetsNormalizedRecordNames": uniqueStrings(names),
+				"route53:ChangeResourceRecordSetsRecordTypes":           recordTypes(),
+				"route53:ChangeResourceRecordSetsActions":               changeActions(),
+			}},
+		},
+	}}
+	policy.Statement = append(policy.Statement, featureStatements()...)
+
+	out, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(out))
+
+	return nil
+}
+
+// featureStatements returns the statements needed by the optional aws integrations that are configured
+func featureStatements() []iamStatement {
+	var statements []iamStatement
+	add := func(sid, action, resource string) {
+		statements = append(statements, iamStatement{Sid: sid, Effect: "Allow", Action: []string{action}, Resource: []string{resource}})
+	}
+
+	if namespace := os.Getenv(CloudWatchNamespaceEnvVar); namespace != "" {
+		statements = append(statements, iamStatement{Sid: "PublishMetrics", Effect: "Allow",
+			Action: []string{"cloudwatch:PutMetricData"}, Resource: []string{"*"},
+			Condition: map[string]map[string]any{"StringEquals": {"cloudwatch:namespace": namespace}}})
+	}
+	if os.Getenv(LogOutputEnvVar) == LogOutputCloudWatch {
+		statements = append(statements, iamStatement{Sid: "ShipLogs", Effect: "Allow",
+			Action:   []string{"logs:CreateLogStream", "logs:PutLogEvents"},
+			Resource: []string{"arn:aws:logs:*:*:log-group:" + os.Getenv(CloudWatchLogGroupEnvVar) + ":*"}})
+	}
+	if os.Getenv(SESFromEnvVar) != "" {
+		add("SendEmail", "ses:SendEmail", "*")
+	}
+	if topicARN := os.Getenv(SNSTopicARNEnvVar); topicARN != "" {
+		add("PublishEvents", "sns:Publish", topicARN)
+	}
+	if bus := os.Getenv(EventBridgeBusEnvVar); bus != "" {
+		resource := bus
+		if !strings.HasPrefix(bus, "arn:") {
+			resource = "arn:aws:events:*:*:event-bus/" + bus
+		}
+		add("PutEvents", "events:PutEvents", resource)
+	}
+	// parameters and secrets are read with the daemon's own credentials rather than the role it assumes
+	if paths := envList(SSMParameterPathsEnvVar); len(paths) > 0 && os.Getenv(RoleARNEnvVar) == "" {
+		var parameterARNs []string
+		for _, path := range paths {
+			parameterARNs = append(parameterARNs, "arn:aws:ssm:*:*:parameter/"+strings.Trim(path, "/"))
+		}
+		statements = append(statements, iamStatement{Sid: "ReadParameters", Effect: "Allow",
+			Action: []string{"ssm:GetParametersByPath"}, Resource: uniqueStrings(parameterARNs)})
+	}
+	if configURL := os.Getenv(ConfigURLEnvVar); strings.HasPrefix(configURL, "s3://") && os.Getenv(RoleARNEnvVar) == "" {
+		add("ReadConfig", "s3:GetObject", "arn:aws:s3:::"+strings.TrimPrefix(configURL, "s3://"))
+	}
+	if refs := findSecretReferences(); len(refs) > 0 && os.Getenv(RoleARNEnvVar) == "" {
+		var secretARNs []string
+		for _, ref := range refs {
+			secretARN, _, _ := strings.Cut(ref, "#")
+			secretARNs = append(secretARNs, secretARN)
+		}
+		statements = append(statements, iamStatement{Sid: "ReadSecrets", Effect: "Allow",
+			Action: []string{"secretsmanager:GetSecretValue"}, Resource: uniqueStrings(secretARNs)})
+	}
+	if domain := strings.Trim(os.Getenv(ACMEDNSDomainEnvVar), "."); domain != "" {
+		statements = append(statements, iamStatement{Sid: "SolveChallenges", Effect: "Allow",
+			Action:   []string{"route53:ChangeResourceRecordSets", "route53:ListResourceRecordSets"},
+			Resource: []string{"arn:aws:route53:::hostedzone/*"},
+			Condition: map[string]map[string]any{"ForAllValues:StringLike": {
+				"route53:ChangeResourceRecordSetsNormalizedRecordNames": []string{"*." + strings.ToLower(domain)},
+				"route53:ChangeResourceRecordSetsRecordTypes":           []string{"TXT"},
+			}}})
+	}
+	if os.Getenv(VerifyPermissionsEnvVar) != "" {
+		add("VerifyPermissions", "iam:SimulatePrincipalPolicy", "*")
+	}
+	if os.Getenv(IPSourceEnvVar) == IPSourceECS {
+		add("FindTaskAddress", "ec2:DescribeNetworkInterfaces", "*")
+	}
+
+	return statements
+}
+
+// lookupZoneIDs finds the hosted zone of every name with the credentials of profile
+func lookupZoneIDs(profile string, names []string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), credentialsTimeout)
+	defer cancel()
+
+	options, err := awsLoadOptions(config.WithSharedConfigProfile(profile))
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+	client := dns.NewRoute53Client(cfg, dns.RetryOptions{})
+
+	var zoneIDs []string
+	for _, name := range names {
+		_, domain, _ := strings.Cut(name, ".")
+		resp, err := client.ListHostedZonesByName(ctx, &route53.ListHostedZonesByNameInput{
+			DNSName:  aws.String(domain + "."),
+			MaxItems: aws.Int32(1),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.HostedZones) != 1 || aws.ToString(resp.HostedZones[0].Name) != domain+"." {
+			return nil, fmt.Errorf("%s: %s", "could not find domain", domain)
+		}
+		zoneIDs = append(zoneIDs, strings.TrimPrefix(aws.ToString(resp.HostedZones[0].Id), "/hostedzone/"))
+	}
+
+	return zoneIDs, nil
+}
+
+// uniqueStrings returns the sorted distinct values
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	sort.Strings(unique)
+
+	return unique
+}
+
+// readActions returns the actions the configured records' zones are read with
+func readActions() []string {
+	value := os.Getenv(DNSSECCheckIntervalEnvVar)
+	if d, err := time.ParseDuration(value); value == "" || (err == nil && d > 0) {
+		return []string{"route53:GetDNSSEC", "route53:ListResourceRecordSets"}
+	}
+
+	return []string{"route53:ListResourceRecordSets"}
+}
+
+// recordTypes returns the types of the records changed, the ownership markers are TXT records
+func recordTypes() []string {
+	if os.Getenv(OwnerIDEnvVar) != "" {
+		return []string{updater.RecordType, "TXT"}
+	}
+
+	return []string{updater.RecordType}
+}
+
+// changeActions returns the change actions the configured records are submitted with
+func changeActions() []string {
+	if deleteOnStop, _ := strconv.ParseBool(os.Getenv(DeleteOnStopEnvVar)); deleteOnStop {
+		return []string{"DELETE", "UPSERT"}
+	}
+
+	return []string{"UPSERT"}
+}
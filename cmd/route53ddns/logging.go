@@ -0,0 +1,72 @@
+package main
+
+import (
+	"github.com/rgravlin/route53ddns/pkg/updater"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// supported log output formats
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
+// supported log output targets
+const (
+	LogOutputStderr     = "stderr"
+	LogOutputSyslog     = "syslog"
+	LogOutputJournald   = "journald"
+	LogOutputCloudWatch = "cloudwatch"
+)
+
+var (
+	// flushLogs delivers buffered log records before the process exits
+	flushLogs = func() {}
+	logFormat = LogFormatJSON
+	// logLevel is shared by every handler so the level can be changed after the logger is installed
+	logLevel = new(slog.LevelVar)
+)
+
+// setupLogger installs the default structured logger writing to w, this also routes anything
+// written through the standard log package
+func setupLogger(w io.Writer) {
+	slog.SetDefault(slog.New(newFormatHandler(w)))
+}
+
+// newFormatHandler returns a handler writing records to w in the configured format
+func newFormatHandler(w io.Writer) slog.Handler {
+	opts := &slog.HandlerOptions{
+		Level: logLevel,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.LevelKey && len(groups) == 0 {
+				if level, ok := a.Value.Any().(slog.Level); ok && level == updater.LevelCritical {
+					a.Value = slog.StringValue("CRITICAL")
+				}
+			}
+			return a
+		},
+	}
+
+	if logFormat == LogFormatText {
+		return slog.NewTextHandler(w, opts)
+	}
+
+	return slog.NewJSONHandler(w, opts)
+}
+
+// parseLogLevel converts debug, info, warn or error into a level
+func parseLogLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(value))
+
+	return level, err
+}
+
+// fatal logs an error and exits non-zero
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	flushLogs()
+	os.Exit(1)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"github.com/rgravlin/route53ddns/pkg/updater"
+	"log/slog"
+	"log/syslog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// journaldSocket is where systemd-journald accepts native protocol datagrams
+const journaldSocket = "/run/systemd/journal/socket"
+
+// syslogHandler formats records like the other outputs and sends each as one syslog message at
+// the severity matching its level
+type syslogHandler struct {
+	inner slog.Handler
+	out   *syslogOutput
+}
+
+// syslogOutput adapts a syslog writer to io.Writer, level is set for every record
+type syslogOutput struct {
+	mu    sync.Mutex
+	w     *syslog.Writer
+	level slog.Level
+}
+
+// newSyslogHandler connects to the local syslog daemon, or to a remote one when address is
+// formatted as udp://host:port or tcp://host:port
+func newSyslogHandler(address string) (slog.Handler, error) {
+	var network, raddr string
+	if address != "" {
+		u, err := url.Parse(address)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("%s: %s", "syslog address must be formatted as udp://host:port or tcp://host:port", address)
+		}
+		network, raddr = u.Scheme, u.Host
+	}
+
+	w, err := syslog.Dial(network, raddr, syslog.LOG_INFO|syslog.LOG_DAEMON, filepath.Base(os.Args[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	out := &syslogOutput{w: w}
+
+	return &syslogHandler{inner: newFormatHandler(out), out: out}, nil
+}
+
+func (o *syslogOutput) Write(p []byte) (int, error) {
+	msg := string(bytes.TrimSuffix(p, []byte("\n")))
+
+	var err error
+	switch {
+	case o.level >= updater.LevelCritical:
+		err = o.w.Crit(msg)
+	case o.level >= slog.LevelError:
+		err = o.w.Err(msg)
+	case o.level >= slog.LevelWarn:
+		err = o.w.Warning(msg)
+	case o.level >= slog.LevelInfo:
+		err = o.w.Info(msg)
+	default:
+		err = o.w.Debug(msg)
+	}
+
+	return len(p), err
+}
+
+func (h *syslogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *syslogHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.out.mu.Lock()
+	defer h.out.mu.Unlock()
+
+	h.out.level = r.Level
+
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *syslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &syslogHandler{inner: h.inner.WithAttrs(attrs), out: h.out}
+}
+
+func (h *syslogHandler) WithGroup(name string) slog.Handler {
+	return &syslogHandler{inner: h.inner.WithGroup(name), out: h.out}
+}
+
+// journaldHandler writes records to journald using the native protocol so every attribute becomes
+// a structured journal field
+type journaldHandler struct {
+	conn   *net.UnixConn
+	addr   *net.UnixAddr
+	prefix string
+	attrs  []slog.Attr
+}
+
+// newJournaldHandler opens a datagram socket for the local journal
+func newJournaldHandler() (slog.Handler, error) {
+	if _, err := os.Stat(journaldSocket); err != nil {
+		return nil, fmt.Errorf("%s: %w", "journald is not available", err)
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	addr := &net.UnixAddr{Name: journaldSocket, Net: "unixgram"}
+
+	return &journaldHandler{conn: conn, addr: addr}, nil
+}
+
+func (h *journaldHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= logLevel.Level()
+}
+
+func (h *journaldHandler) Handle(_ context.Context, r slog.Record) error {
+	var buf bytes.Buffer
+	writeJournalField(&buf, "MESSAGE", r.Message)
+	writeJournalField(&buf, "PRIORITY", journalPriority(r.Level))
+	writeJournalField(&buf, "SYSLOG_IDENTIFIER", filepath.Base(os.Args[0]))
+
+	for _, attr := range h.attrs {
+		writeJournalAttr(&buf, "", attr)
+	}
+	r.Attrs(func(attr slog.Attr) bool {
+		writeJournalAttr(&buf, h.prefix, attr)
+		return true
+	})
+
+	_, err := h.conn.WriteToUnix(buf.Bytes(), h.addr)
+
+	return err
+}
+
+func (h *journaldHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append([]slog.Attr{}, h.attrs...)
+	for _, attr := range attrs {
+		if h.prefix != "" {
+			attr.Key = h.prefix + attr.Key
+		}
+		clone.attrs = append(clone.attrs, attr)
+	}
+
+	return &clone
+}
+
+func (h *journaldHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.prefix = h.prefix + name + "_"
+
+	return &clone
+}
+
+// journalPriority maps a level onto the syslog priorities journald uses
+func journalPriority(level slog.Level) string {
+	switch {
+	case level >= updater.LevelCritical:
+		return "2"
+	case level >= slog.LevelError:
+		return "3"
+	case level >= slog.LevelWarn:
+		return "4"
+	case level >= slog.LevelInfo:
+		return "6"
+	}
+
+	return "7"
+}
+
+// writeJournalAttr writes an attribute, flattening groups into prefixed field names
+func writeJournalAttr(buf *bytes.Buffer, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Value.Kind() == slog.KindGroup {
+		for _, member := range attr.Value.Group() {
+			writeJournalAttr(buf, prefix+attr.Key+"_", member)
+		}
+		return
+	}
+
+	writeJournalField(buf, prefix+attr.Key, attr.Value.String())
+}
+
+// writeJournalField appends a field in the native protocol, values containing newlines use the
+// length prefixed binary form
+func writeJournalField(buf *bytes.Buffer, key, value string) {
+	key = journalFieldName(key)
+	if key == "" {
+		return
+	}
+
+	if !strings.Contains(value, "\n") {
+		buf.WriteString(key + "=" + value + "\n")
+		return
+	}
+
+	buf.WriteString(key + "\n")
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value + "\n")
+}
+
+// journalFieldName converts a key into a valid journal field name, upper case letters, digits and
+// underscores not starting with an underscore
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, key)
+
+	return strings.TrimLeft(name, "_")
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/rgravlin/route53ddns/pkg/updater"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// main runs the route53ddns command, the daemon configured from the environment or one of its
+// subcommands named by the first argument
+func main() {
+	// commands other than running the daemon need no daemon configuration
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "status":
+			if err := runStatusCommand(os.Args[2:]); err != nil {
+				fatal("unable to retrieve status", "error", err)
+			}
+			return
+		case "history":
+			if err := runHistoryCommand(os.Args[2:]); err != nil {
+				fatal("unable to read history", "error", err)
+			}
+			return
+		case "iam-policy":
+			if err := runIAMPolicyCommand(os.Args[2:]); err != nil {
+				fatal("unable to generate iam policy", "error", err)
+			}
+			return
+		case "crd":
+			fmt.Print(updater.DDNSRecordCRD)
+			return
+		case "top":
+			if err := runTopCommand(os.Args[2:]); err != nil {
+				fatal("unable to display status", "error", err)
+			}
+			return
+		}
+	}
+
+	daemon := flag.Bool("daemon", false, "detach from the terminal and run in the background")
+	logPath := flag.String("log-file", "", "append logs to this file instead of stderr")
+	pidPath := flag.String("pid-file", "", "lock file holding the pid of the running instance")
+	dryRunFlag := flag.Bool("dry-run", false, "print planned changes without submitting them")
+	flag.StringVar(&ddclientConfPath, "ddclient-conf", os.Getenv(DDClientConfEnvVar), "read hosts and address detection from a ddclient configuration file")
+	enablePprof := flag.Bool("pprof", false, "expose profiling endpoints under /debug/pprof/")
+	flag.Parse()
+
+	// detach before reading any configuration, so it is read and credentials are retrieved once, by
+	// the background copy
+	if *daemon && !isDaemonChild() {
+		if err := daemonize(); err != nil {
+			fatal("unable to start in background", "error", err)
+		}
+	}
+
+	cfg := loadConfig()
+
+	// the lambda runtime invokes a cycle per event instead of running the scheduler
+	if runningInLambda() {
+		u := updater.New(updater.WithConfig(cfg), updater.WithLogger(slog.Default()))
+		if err := u.ServeLambda(context.Background()); err != nil {
+			fatal("unable to configure records", "error_category", updater.ErrorCause(err), "error", err)
+		}
+		return
+	}
+
+	// flags not given default to the configuration, which may have come from parameters or a config file
+	given := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	if !given["log-file"] {
+		*logPath = os.Getenv(LogFileEnvVar)
+	}
+	if !given["pid-file"] {
+		*pidPath = os.Getenv(PIDFileEnvVar)
+	}
+	if given["dry-run"] {
+		cfg.DryRun = *dryRunFlag
+	}
+	cfg.Pprof = *enablePprof
+	if !given["pprof"] {
+		cfg.Pprof = envBool(PprofEnvVar, false)
+	}
+	if cfg.Pprof && cfg.ListenAddress == "" {
+		slog.Warn("profiling requires a listen address", "variable", ListenAddressEnvVar)
+	}
+
+	// a log file replaces stderr, other log outputs aren't written to files
+	logOutput := envString(LogOutputEnvVar, LogOutputStderr)
+	if *logPath != "" && logOutput != LogOutputStderr {
+		fatal("a log file can only replace stderr", "log_file", *logPath, "variable", LogOutputEnvVar, "value", logOutput)
+	}
+
+	// background mode logs to a file unless logs go elsewhere, and writes a pid file
+	if *daemon {
+		if *logPath == "" && logOutput == LogOutputStderr {
+			*logPath = DefaultDaemonLogFile
+		}
+		if *pidPath == "" {
+			*pidPath = DefaultDaemonPIDFile
+		}
+	}
+
+	// log files are rotated by size and age
+	if *logPath != "" {
+		setupLogger(&lumberjack.Logger{
+			Filename:   *logPath,
+			MaxSize:    envInt(LogMaxSizeEnvVar, DefaultLogMaxSize),
+			MaxAge:     envInt(LogMaxAgeEnvVar, 0),
+			MaxBackups: envInt(LogMaxBackupsEnvVar, DefaultLogMaxBackups),
+			Compress:   envBool(LogCompressEnvVar, false),
+		})
+	}
+
+	// refuse to run alongside another instance sharing the same pid file
+	if path := *pidPath; path != "" {
+		if err := acquirePIDFile(path); err != nil {
+			fatal("unable to acquire pid file", "error", err)
+		}
+	}
+
+	u := updater.New(updater.WithConfig(cfg), updater.WithLogger(slog.Default()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handleControlSignals(u)
+	handleShutdownSignals(cancel)
+
+	// configuration sources are read again in the background, applying what can change while running
+	if secretsRefreshInterval > 0 {
+		refreshEvery(ctx, secretsRefreshInterval, func() { refreshSecrets(u) })
+	}
+	if parametersRefreshInterval > 0 && len(parameterPaths) > 0 {
+		refreshEvery(ctx, parametersRefreshInterval, func() { refreshParameters(u) })
+	}
+	if configPollInterval > 0 && s3Config != nil {
+		refreshEvery(ctx, configPollInterval, func() { pollS3Config(u) })
+	}
+
+	if err := u.Run(ctx); err != nil {
+		fatal("unable to run", "error_category", updater.ErrorCause(err), "error", err)
+	}
+	flushLogs()
+}
+
+// refreshEvery calls refresh every interval until ctx is done
+func refreshEvery(ctx context.Context, interval time.Duration, refresh func()) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				refresh()
+			}
+		}
+	}()
+}
+
+// handleShutdownSignals cancels the running updater on SIGINT or SIGTERM so the daemon can shut down
+// cleanly
+func handleShutdownSignals(cancel context.CancelFunc) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		sig := <-signals
+		slog.Info("shutting down", "signal", sig.String())
+		cancel()
+	}()
+}
+
+// handleControlSignals pauses publishing on SIGUSR1, resumes it on SIGUSR2 and runs a reconciliation
+// right away on SIGHUP
+func handleControlSignals(u *updater.Updater) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGHUP)
+
+	go func() {
+		for sig := range signals {
+			switch sig {
+			case syscall.SIGUSR1:
+				u.Pause()
+			case syscall.SIGUSR2:
+				u.Resume()
+			case syscall.SIGHUP:
+				slog.Info("reconciliation requested", "signal", sig.String())
+				if err := u.Reconcile(); err != nil {
+					slog.Warn("unable to run reconciliation", "error", err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/rgravlin/route53ddns/pkg/updater"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// parametersTimeout bounds reading every parameter path once
+const parametersTimeout = 30 * time.Second
+
+var (
+	// parameterPaths are the ssm parameter paths configuration is read from, a parameter of a later
+	// path overrides the same parameter of an earlier one
+	parameterPaths []string
+	// parametersConfig is the aws configuration parameters are read with
+	parametersConfig aws.Config
+	// parameterOverlay holds the variables currently set from parameters
+	parameterOverlay = &configOverlay{}
+)
+
+// readParameters returns the variables set by the parameters directly under every path, secure
+// strings are decrypted
+func readParameters(ctx context.Context, cfg aws.Config, paths []string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, parametersTimeout)
+	defer cancel()
+
+	client := ssm.NewFromConfig(cfg)
+	values := map[string]string{}
+	for _, path := range paths {
+		paginator := ssm.NewGetParametersByPathPaginator(client, &ssm.GetParametersByPathInput{
+			Path:           aws.String(path),
+			WithDecryption: aws.Bool(true),
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", "unable to read parameters of", path, err)
+			}
+			for _, parameter := range page.Parameters {
+				// the last element of the name names the variable, e.g. /route53ddns/home/hostname
+				name := aws.ToString(parameter.Name)
+				values[configVariable(name[strings.LastIndex(name, "/")+1:])] = aws.ToString(parameter.Value)
+			}
+		}
+	}
+
+	return values, nil
+}
+
+// refreshParameters reads the parameter paths again and applies any parameter that changed
+func refreshParameters(u *updater.Updater) {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	values, err := readParameters(context.Background(), parametersConfig, parameterPaths)
+	if err != nil {
+		slog.Warn("unable to refresh parameters, keeping previous values", "error", err)
+		return
+	}
+	changed, err := parameterOverlay.apply(values)
+	if err != nil {
+		slog.Error("unable to apply parameters", "error", err)
+		return
+	}
+	if len(changed) > 0 {
+		applyConfigChanges(u, "ssm", changed)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// pidFile is held open for the lifetime of the process so its lock is kept, the kernel releases
+// the lock when the process exits however it exits
+var pidFile *os.File
+
+// acquirePIDFile takes an exclusive lock on path and records the current pid in it, failing when
+// another instance already holds the lock
+func acquirePIDFile(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		owner, _ := io.ReadAll(f)
+		_ = f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return fmt.Errorf("%s (%s): pid %s", "another instance is already running", path, strings.TrimSpace(string(owner)))
+		}
+		return err
+	}
+
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%d\n", os.Getpid()); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	pidFile = f
+
+	return nil
+}
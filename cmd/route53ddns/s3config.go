@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/rgravlin/route53ddns/pkg/updater"
+	"io"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// s3ConfigTimeout bounds a single check or download of the configuration object
+const s3ConfigTimeout = 30 * time.Second
+
+// s3ConfigSource is a configuration file kept in s3, one NAME=value per line with blank lines and
+// lines starting with # ignored, names may omit the variable prefix and values may be double quoted
+type s3ConfigSource struct {
+	client *s3.Client
+	bucket string
+	key    string
+	// etag identifies the version of the object last applied
+	etag    string
+	overlay configOverlay
+}
+
+// s3Config is the s3 configuration source, nil when configuration isn't kept in s3
+var s3Config *s3ConfigSource
+
+// newS3ConfigSource creates a source for an s3://bucket/key url
+func newS3ConfigSource(cfg aws.Config, rawURL string) (*s3ConfigSource, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Scheme != "s3" || u.Host == "" || key == "" {
+		return nil, fmt.Errorf("%s: %s", "not an s3://bucket/key url", rawURL)
+	}
+
+	return &s3ConfigSource{client: s3.NewFromConfig(cfg), bucket: u.Host, key: key}, nil
+}
+
+// load downloads the configuration and applies it, returning the names of the variables that changed
+func (s *s3ConfigSource) load(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s3ConfigTimeout)
+	defer cancel()
+
+	resp, err := s.client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(s.key)})
+	if err != nil {
+		return nil, fmt.Errorf("%s s3://%s/%s: %w", "unable to download", s.bucket, s.key, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	values, err := parseConfigFile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	changed, err := s.overlay.apply(values)
+	if err != nil {
+		return nil, err
+	}
+	s.etag = aws.ToString(resp.ETag)
+
+	return changed, nil
+}
+
+// modified reports whether the object changed since it was last applied, comparing etags so an
+// unchanged object is never downloaded
+func (s *s3ConfigSource) modified(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s3ConfigTimeout)
+	defer cancel()
+
+	resp, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(s.key)})
+	if err != nil {
+		return false, err
+	}
+
+	return aws.ToString(resp.ETag) != s.etag, nil
+}
+
+// pollS3Config applies the configuration object again when its etag changed
+func pollS3Config(u *updater.Updater) {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	ctx := context.Background()
+	modified, err := s3Config.modified(ctx)
+	if err != nil {
+		slog.Warn("unable to check configuration object, keeping previous values", "error", err)
+		return
+	}
+	if !modified {
+		return
+	}
+
+	changed, err := s3Config.load(ctx)
+	if err != nil {
+		slog.Warn("unable to load configuration object, keeping previous values", "error", err)
+		return
+	}
+	slog.Debug("configuration object changed", "etag", s3Config.etag)
+	if len(changed) > 0 {
+		applyConfigChanges(u, "s3", changed)
+	}
+}
+
+// parseConfigFile parses NAME=value lines into the variables they set
+func parseConfigFile(data []byte) (map[string]string, error) {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		name, value, ok := strings.Cut(text, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%s %d: %q", "not a NAME=value line", line, text)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s %d: %w", "not a valid quoted value on line", line, err)
+			}
+			value = unquoted
+		}
+		values[configVariable(name)] = value
+	}
+
+	return values, scanner.Err()
+}
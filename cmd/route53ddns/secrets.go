@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/rgravlin/route53ddns/pkg/updater"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// secretsTimeout bounds resolving every secret reference once
+const secretsTimeout = 30 * time.Second
+
+var (
+	// secretReferences maps every configuration variable set to a secrets manager arn to that arn,
+	// kept so refreshes resolve the same secrets again once the variables hold their values
+	secretReferences map[string]string
+	// secretsConfig is the aws configuration secrets are read with
+	secretsConfig aws.Config
+)
+
+// findSecretReferences returns the configuration variables whose value is a secrets manager arn,
+// optionally followed by # and the field to pick from a json secret, e.g.
+// arn:aws:secretsmanager:eu-west-1:123456789012:secret:route53ddns-AbCdEf#discord_webhook
+func findSecretReferences() map[string]string {
+	refs := map[string]string{}
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		if !strings.HasPrefix(name, "CONFIG_R53DDNS_") {
+			continue
+		}
+
+		secretARN, _, _ := strings.Cut(value, "#")
+		if parsed, err := arn.Parse(secretARN); err == nil && parsed.Service == "secretsmanager" {
+			refs[name] = value
+		}
+	}
+
+	return refs
+}
+
+// resolveSecrets sets every variable of refs to the value of the secret it references, returning the
+// names of the variables whose value changed. nothing is set unless every secret could be read, and a
+// secret referenced by several variables is read once
+func resolveSecrets(ctx context.Context, cfg aws.Config, refs map[string]string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, secretsTimeout)
+	defer cancel()
+
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	clients := map[string]*secretsmanager.Client{}
+	secrets := map[string]string{}
+	values := map[string]string{}
+	for _, name := range names {
+		secretARN, field, _ := strings.Cut(refs[name], "#")
+
+		secret, ok := secrets[secretARN]
+		if !ok {
+			// secrets are regional, the arn names the region holding it
+			parsed, _ := arn.Parse(secretARN)
+			client, ok := clients[parsed.Region]
+			if !ok {
+				client = secretsmanager.NewFromConfig(cfg, func(o *secretsmanager.Options) {
+					o.Region = parsed.Region
+				})
+				clients[parsed.Region] = client
+			}
+
+			resp, err := client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(secretARN)})
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", "unable to read secret of", name, err)
+			}
+			if resp.SecretString == nil {
+				return nil, fmt.Errorf("%s %s: %s", "secret of", name, "binary secrets are not supported")
+			}
+			secret = *resp.SecretString
+			secrets[secretARN] = secret
+		}
+
+		value := secret
+		if field != "" {
+			var err error
+			if value, err = secretField(secret, field); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", "secret of", name, err)
+			}
+		}
+
+		values[name] = value
+	}
+
+	var changed []string
+	for _, name := range names {
+		if os.Getenv(name) != values[name] {
+			if err := os.Setenv(name, values[name]); err != nil {
+				return nil, err
+			}
+			changed = append(changed, name)
+		}
+	}
+
+	return changed, nil
+}
+
+// secretField returns field of a json secret, values other than strings are returned as json
+func secretField(secret, field string) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("%s: %w", "secret is not a json object", err)
+	}
+
+	raw, ok := fields[field]
+	if !ok {
+		return "", fmt.Errorf("%s: %s", "secret has no field", field)
+	}
+
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return string(raw), nil
+	}
+
+	return value, nil
+}
+
+// refreshSecrets resolves the secret references again and applies any secret that changed
+func refreshSecrets(u *updater.Updater) {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	changed, err := resolveSecrets(context.Background(), secretsConfig, secretReferences)
+	if err != nil {
+		slog.Warn("unable to refresh secrets, keeping previous values", "error", err)
+		return
+	}
+	if len(changed) > 0 {
+		applyConfigChanges(u, "secrets manager", changed)
+	}
+}
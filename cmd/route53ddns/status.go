@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/rgravlin/route53ddns/pkg/updater"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// runStatusCommand prints the status of the running daemon, falling back to the state file when
+// the daemon cannot be reached
+func runStatusCommand(args []string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	url := flags.String("url", statusURL(os.Getenv(ListenAddressEnvVar)), "status endpoint of the running daemon")
+	path := flags.String("state-file", os.Getenv(StateFileEnvVar), "state file or s3:// or dynamodb:// state url to read when the daemon is not reachable")
+	_ = flags.Parse(args)
+
+	report, err := fetchStatus(*url)
+	if err != nil {
+		if *path == "" {
+			return err
+		}
+
+		if report, err = readStatus(*path); err != nil {
+			return err
+		}
+	}
+
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(out))
+
+	return nil
+}
+
+// statusURL returns the status endpoint for a listen address, or an empty string when none is set
+func statusURL(address string) string {
+	if address == "" {
+		return ""
+	}
+
+	// a listen address without a host is reachable on loopback
+	if strings.HasPrefix(address, ":") {
+		address = "127.0.0.1" + address
+	}
+
+	return "http://" + address + "/status"
+}
+
+// fetchStatus retrieves the status report served at url
+func fetchStatus(url string) (updater.Status, error) {
+	var report updater.Status
+	if url == "" {
+		return report, fmt.Errorf("%s, set %s or --url", "no status endpoint configured", ListenAddressEnvVar)
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return report, err
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return report, fmt.Errorf("%s: %s", "unexpected status endpoint response", resp.Status)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&report)
+
+	return report, err
+}
+
+// readStatus reports the state saved at location by a daemon that isn't running, remote state is
+// read with the default aws configuration
+func readStatus(location string) (updater.Status, error) {
+	options, err := awsLoadOptions()
+	if err != nil {
+		return updater.Status{}, err
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background(), options...)
+	if err != nil {
+		return updater.Status{}, err
+	}
+
+	// older state files describe the single record named by the hostname variable
+	return updater.ReadStatus(context.Background(), location, cfg, os.Getenv(FQDNEnvVar))
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/rgravlin/route53ddns/pkg/updater"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// ansi sequences used to redraw the terminal in place
+const (
+	ansiClear     = "\033[H\033[2J"
+	ansiHideCur   = "\033[?25l"
+	ansiShowCur   = "\033[?25h"
+	ansiBold      = "\033[1m"
+	ansiRed       = "\033[31m"
+	ansiGreen     = "\033[32m"
+	ansiYellow    = "\033[33m"
+	ansiReset     = "\033[0m"
+	topMaxErrors  = 5
+	topTimeFormat = "2006-01-02 15:04:05"
+)
+
+// runTopCommand live-tails the status of the running daemon until interrupted, falling back to
+// the state file when the daemon cannot be reached
+func runTopCommand(args []string) error {
+	flags := flag.NewFlagSet("top", flag.ExitOnError)
+	url := flags.String("url", statusURL(os.Getenv(ListenAddressEnvVar)), "status endpoint of the running daemon")
+	path := flags.String("state-file", os.Getenv(StateFileEnvVar), "state file or s3:// or dynamodb:// state url to read when the daemon is not reachable")
+	interval := flags.Duration("interval", 2*time.Second, "how often to refresh the status")
+	_ = flags.Parse(args)
+
+	if *interval <= 0 {
+		return fmt.Errorf("%s: %s", "refresh interval must be positive", *interval)
+	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	fmt.Print(ansiHideCur)
+	defer fmt.Print(ansiShowCur)
+
+	refresh := time.NewTicker(*interval)
+	defer refresh.Stop()
+	countdown := time.NewTicker(time.Second)
+	defer countdown.Stop()
+
+	report, source, fetchErr := topFetch(*url, *path)
+	for {
+		renderTop(os.Stdout, report, source, fetchErr, time.Now().UTC())
+
+		select {
+		case <-stop:
+			fmt.Println()
+			return nil
+		case <-refresh.C:
+			report, source, fetchErr = topFetch(*url, *path)
+		case <-countdown.C:
+		}
+	}
+}
+
+// topFetch retrieves the status from the daemon, or from the state file when it is unreachable,
+// returning where it came from
+func topFetch(url, path string) (updater.Status, string, error) {
+	report, err := fetchStatus(url)
+	if err == nil {
+		return report, url, nil
+	}
+	if path == "" {
+		return report, "", err
+	}
+
+	if report, err = readStatus(path); err != nil {
+		return updater.Status{}, "", err
+	}
+
+	return report, path, nil
+}
+
+// renderTop redraws the terminal with report
+func renderTop(w io.Writer, report updater.Status, source string, fetchErr error, now time.Time) {
+	var b strings.Builder
+	b.WriteString(ansiClear)
+	fmt.Fprintf(&b, "%sroute53ddns%s  %s\n", ansiBold, ansiReset, now.Format(topTimeFormat))
+
+	if fetchErr != nil {
+		fmt.Fprintf(&b, "\n%sunable to retrieve status: %v%s\n", ansiRed, fetchErr, ansiReset)
+		_, _ = io.WriteString(w, b.String())
+		return
+	}
+
+	state := ansiGreen + "running" + ansiReset
+	switch {
+	case !report.Running:
+		state = ansiYellow + "not reachable (state file)" + ansiReset
+	case report.Paused:
+		state = ansiYellow + "paused" + ansiReset
+	}
+	fmt.Fprintf(&b, "source: %s  state: %s\n\n", source, state)
+
+	lastSuccess := "never"
+	if report.LastSuccess != nil {
+		lastSuccess = topAge(now.Sub(*report.LastSuccess)) + " ago"
+	}
+	nextRun := "-"
+	if report.NextRun != nil {
+		nextRun = "in " + topAge(report.NextRun.Sub(now))
+	}
+	failures := fmt.Sprint(report.ConsecutiveFailures)
+	if report.ConsecutiveFailures > 0 {
+		failures = ansiRed + failures + ansiReset
+	}
+	fmt.Fprintf(&b, "last success: %s  next run: %s  consecutive failures: %s\n\n", lastSuccess, nextRun, failures)
+
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "RECORD\tPUBLISHED\tDETECTED\tLAST CHANGE\tDNSSEC\t")
+	for _, record := range report.Records {
+		// escape sequences would throw off the column alignment, so pending changes are starred
+		detected := report.DetectedIP
+		if detected != "" && detected != record.PublishedIP {
+			detected += " *"
+		}
+		lastChange := "-"
+		if !record.LastChangeTime.IsZero() {
+			lastChange = topAge(now.Sub(record.LastChangeTime)) + " ago"
+		}
+		dnssec := strings.ToLower(record.DNSSECStatus)
+		if record.DNSSECProblem != "" {
+			dnssec += " !"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", record.FQDN, topValue(record.PublishedIP), topValue(detected), lastChange, topValue(dnssec))
+	}
+	_ = tw.Flush()
+
+	for _, record := range report.Records {
+		if record.DNSSECProblem != "" {
+			fmt.Fprintf(&b, "\n%sdnssec problem on %s: %s%s\n", ansiRed, record.FQDN, record.DNSSECProblem, ansiReset)
+		}
+	}
+
+	if len(report.RecentErrors) > 0 {
+		b.WriteString("\nrecent errors:\n")
+		errs := report.RecentErrors
+		if len(errs) > topMaxErrors {
+			errs = errs[len(errs)-topMaxErrors:]
+		}
+		// newest first
+		for i := len(errs) - 1; i >= 0; i-- {
+			e := errs[i]
+			fmt.Fprintf(&b, "  %s %s[%s]%s %s: %s\n", e.Time.Format(topTimeFormat), ansiRed, e.Cause, ansiReset, e.Job, e.Error)
+		}
+	}
+
+	_, _ = io.WriteString(w, b.String())
+}
+
+// topAge formats d to whole seconds, clamping negative durations to zero
+func topAge(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+
+	return d.Truncate(time.Second).String()
+}
+
+// topValue returns a placeholder for empty values
+func topValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
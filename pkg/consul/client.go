@@ -0,0 +1,109 @@
+// Package consul is a minimal client of the consul http api, enough to read the service catalog
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultAddress is the http api of the local consul agent
+const DefaultAddress = "http://127.0.0.1:8500"
+
+// Client calls the http api of a consul agent
+type Client struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// New returns a client of the agent at address with the acl token, no token is sent when empty, and
+// client or the default client when nil
+func New(address, token string, client *http.Client) *Client {
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &Client{baseURL: strings.TrimSuffix(address, "/"), token: token, client: client}
+}
+
+// ServiceEntry is a healthy instance of a service, with the node it runs on
+type ServiceEntry struct {
+	Node struct {
+		Node    string `json:"Node"`
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		ID      string            `json:"ID"`
+		Service string            `json:"Service"`
+		Address string            `json:"Address"`
+		Tags    []string          `json:"Tags"`
+		Meta    map[string]string `json:"Meta"`
+	} `json:"Service"`
+}
+
+// Address returns the address the instance is reached on, the address of its node unless the
+// service registered one of its own
+func (e ServiceEntry) Address() string {
+	if e.Service.Address != "" {
+		return e.Service.Address
+	}
+
+	return e.Node.Address
+}
+
+// Services returns the tags of every service in the catalog by service name
+func (c *Client) Services(ctx context.Context) (map[string][]string, error) {
+	var services map[string][]string
+	if err := c.get(ctx, "/v1/catalog/services", &services); err != nil {
+		return nil, err
+	}
+
+	return services, nil
+}
+
+// HealthyInstances returns the instances of service carrying tag whose checks are passing
+func (c *Client) HealthyInstances(ctx context.Context, service, tag string) ([]ServiceEntry, error) {
+	query := url.Values{"passing": {"true"}}
+	if tag != "" {
+		query.Set("tag", tag)
+	}
+
+	var entries []ServiceEntry
+	if err := c.get(ctx, "/v1/health/service/"+url.PathEscape(service)+"?"+query.Encode(), &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// get calls the api and decodes the response into result
+func (c *Client) get(ctx context.Context, path string, result any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return fmt.Errorf("consul api responded %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,234 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// DefaultCloudDNSURL is the base url of the cloud dns v1 api
+const DefaultCloudDNSURL = "https://dns.googleapis.com/dns/v1"
+
+// cloudDNSScope allows reading managed zones and changing their record sets
+const cloudDNSScope = "https://www.googleapis.com/auth/ndev.clouddns.readwrite"
+
+// CloudDNS is the provider of managed zones hosted in google cloud dns
+type CloudDNS struct {
+	project string
+	baseURL string
+	client  *http.Client
+}
+
+// NewCloudDNS returns a provider for the managed zones of project, calling the api at baseURL, or the
+// public api when empty, with client, which must authenticate its requests
+func NewCloudDNS(project, baseURL string, client *http.Client) *CloudDNS {
+	if baseURL == "" {
+		baseURL = DefaultCloudDNSURL
+	}
+
+	return &CloudDNS{project: project, baseURL: strings.TrimSuffix(baseURL, "/"), client: client}
+}
+
+// GoogleClient returns an http client authenticated with the service account key in credentialsFile,
+// or with the application default credentials when empty, and the project the credentials belong to
+func GoogleClient(ctx context.Context, credentialsFile string) (*http.Client, string, error) {
+	var credentials *google.Credentials
+	var err error
+	if credentialsFile != "" {
+		var data []byte
+		if data, err = os.ReadFile(credentialsFile); err != nil {
+			return nil, "", err
+		}
+		credentials, err = google.CredentialsFromJSON(ctx, data, cloudDNSScope)
+	} else {
+		credentials, err = google.FindDefaultCredentials(ctx, cloudDNSScope)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return oauth2.NewClient(ctx, credentials.TokenSource), credentials.ProjectID, nil
+}
+
+func (p *CloudDNS) Name() string {
+	return "clouddns"
+}
+
+// cloudDNSZone is a managed zone as listed by the api
+type cloudDNSZone struct {
+	Name       string `json:"name"`
+	DNSName    string `json:"dnsName"`
+	Visibility string `json:"visibility"`
+}
+
+// cloudDNSRecordSet is a record set as the api reads and writes it, sets with a routing policy answer
+// from that policy instead of their rrdatas
+type cloudDNSRecordSet struct {
+	Name          string          `json:"name"`
+	Type          string          `json:"type"`
+	TTL           int64           `json:"ttl"`
+	RRDatas       []string        `json:"rrdatas,omitempty"`
+	RoutingPolicy json.RawMessage `json:"routingPolicy,omitempty"`
+}
+
+// ResolveZone returns the name of the closest public managed zone holding the record named fqdn,
+// trying each parent domain in turn since zones may be delegated at any level
+func (p *CloudDNS) ResolveZone(ctx context.Context, fqdn string) (string, error) {
+	name := strings.TrimSuffix(fqdn, ".")
+	_, domain, found := strings.Cut(name, ".")
+	if !found || domain == "" {
+		return "", fmt.Errorf("%s: %s", "hostname has no domain", fqdn)
+	}
+
+	for candidate := domain; strings.Contains(candidate, "."); {
+		var resp struct {
+			ManagedZones []cloudDNSZone `json:"managedZones"`
+		}
+		if err := p.do(ctx, http.MethodGet, "/managedZones?"+url.Values{"dnsName": {candidate + "."}}.Encode(), nil, &resp); err != nil {
+			return "", err
+		}
+		for _, zone := range resp.ManagedZones {
+			if zone.Visibility != "private" && strings.EqualFold(zone.DNSName, candidate+".") {
+				return zone.Name, nil
+			}
+		}
+		_, candidate, _ = strings.Cut(candidate, ".")
+	}
+
+	return "", &NotFoundError{Domain: domain}
+}
+
+func (p *CloudDNS) GetRecord(ctx context.Context, zoneID, fqdn, recordType string) (*Record, error) {
+	set, err := p.getRecordSet(ctx, zoneID, fqdn, recordType)
+	if err != nil || set == nil {
+		return nil, err
+	}
+
+	record := &Record{Name: strings.TrimSuffix(set.Name, "."), Type: set.Type, TTL: set.TTL, Values: set.RRDatas, Routing: "simple"}
+	if len(set.RoutingPolicy) > 0 {
+		record.Routing = "routing-policy"
+	}
+
+	return record, nil
+}
+
+// UpsertRecord replaces the record set of the name and type in a single change, which cloud dns applies
+// atomically, and returns the id of the change
+func (p *CloudDNS) UpsertRecord(ctx context.Context, zoneID string, record Record, _ string) (string, error) {
+	current, err := p.getRecordSet(ctx, zoneID, record.Name, record.Type)
+	if err != nil {
+		return "", err
+	}
+
+	change := cloudDNSChange{Additions: []cloudDNSRecordSet{{
+		Name:    strings.TrimSuffix(record.Name, ".") + ".",
+		Type:    record.Type,
+		TTL:     record.TTL,
+		RRDatas: record.Values,
+	}}}
+	if current != nil {
+		change.Deletions = []cloudDNSRecordSet{*current}
+	}
+
+	return p.submit(ctx, zoneID, change)
+}
+
+// DeleteRecord deletes the record set of the name and type when it still holds the values of record,
+// and returns the id of the change
+func (p *CloudDNS) DeleteRecord(ctx context.Context, zoneID string, record Record, _ string) (string, error) {
+	current, err := p.getRecordSet(ctx, zoneID, record.Name, record.Type)
+	if err != nil || current == nil {
+		return "", err
+	}
+	if strings.Join(current.RRDatas, ",") != strings.Join(record.Values, ",") {
+		return "", fmt.Errorf("%s: %s", "record set changed since it was read", record.Name)
+	}
+
+	return p.submit(ctx, zoneID, cloudDNSChange{Deletions: []cloudDNSRecordSet{*current}})
+}
+
+// cloudDNSChange is a change to the record sets of a managed zone, deletions must match the sets
+// held exactly
+type cloudDNSChange struct {
+	Additions []cloudDNSRecordSet `json:"additions,omitempty"`
+	Deletions []cloudDNSRecordSet `json:"deletions,omitempty"`
+	ID        string              `json:"id,omitempty"`
+}
+
+// submit submits change to the zone and returns its id
+func (p *CloudDNS) submit(ctx context.Context, zoneID string, change cloudDNSChange) (string, error) {
+	var submitted cloudDNSChange
+	if err := p.do(ctx, http.MethodPost, "/managedZones/"+url.PathEscape(zoneID)+"/changes", change, &submitted); err != nil {
+		return "", err
+	}
+
+	return submitted.ID, nil
+}
+
+// getRecordSet returns the record set named fqdn of type recordType in the zone, or nil when there
+// is none
+func (p *CloudDNS) getRecordSet(ctx context.Context, zoneID, fqdn, recordType string) (*cloudDNSRecordSet, error) {
+	query := url.Values{"name": {strings.TrimSuffix(fqdn, ".") + "."}, "type": {recordType}}
+	var resp struct {
+		RRSets []cloudDNSRecordSet `json:"rrsets"`
+	}
+	if err := p.do(ctx, http.MethodGet, "/managedZones/"+url.PathEscape(zoneID)+"/rrsets?"+query.Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.RRSets) == 0 {
+		return nil, nil
+	}
+
+	return &resp.RRSets[0], nil
+}
+
+// do calls the api for the project, decoding the response into result when not nil
+func (p *CloudDNS) do(ctx context.Context, method, path string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL+"/projects/"+url.PathEscape(p.project)+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= 300 {
+		var failure struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&failure)
+		return throttled(p.Name(), &APIError{Provider: p.Name(), Status: resp.StatusCode, Message: failure.Error.Message})
+	}
+	if result == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,259 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultCloudflareURL is the base url of the cloudflare v4 api
+const DefaultCloudflareURL = "https://api.cloudflare.com/client/v4"
+
+// cloudflareCommentLimit is the longest record comment every cloudflare plan accepts
+const cloudflareCommentLimit = 100
+
+// APIError is an error answered by the api of a provider, with the http status so callers can tell an
+// authentication problem apart from throttling
+type APIError struct {
+	Provider string
+	Status   int
+	Message  string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s api responded %d: %s", e.Provider, e.Status, e.Message)
+}
+
+// Cloudflare is the provider of zones hosted in cloudflare, authenticated with an api token allowed to
+// read zones and edit their dns records
+type Cloudflare struct {
+	token   string
+	baseURL string
+	client  *http.Client
+}
+
+// NewCloudflare returns a provider calling the api at baseURL, or the public api when empty, with
+// token and client, or the default client when nil
+func NewCloudflare(token, baseURL string, client *http.Client) *Cloudflare {
+	if baseURL == "" {
+		baseURL = DefaultCloudflareURL
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &Cloudflare{token: token, baseURL: strings.TrimSuffix(baseURL, "/"), client: client}
+}
+
+func (p *Cloudflare) Name() string {
+	return "cloudflare"
+}
+
+// cloudflareZone is a zone as listed by the api
+type cloudflareZone struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// cloudflareRecord is a dns record as the api reads and writes it, cloudflare holds every value of a
+// name as a record of its own
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int64  `json:"ttl"`
+	Proxied bool   `json:"proxied"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// ResolveZone returns the id of the closest zone holding the record named fqdn, trying each parent
+// domain in turn since cloudflare zones may be delegated at any level
+func (p *Cloudflare) ResolveZone(ctx context.Context, fqdn string) (string, error) {
+	name := strings.TrimSuffix(fqdn, ".")
+	_, domain, found := strings.Cut(name, ".")
+	if !found || domain == "" {
+		return "", fmt.Errorf("%s: %s", "hostname has no domain", fqdn)
+	}
+
+	for candidate := domain; strings.Contains(candidate, "."); {
+		var zones []cloudflareZone
+		if err := p.do(ctx, http.MethodGet, "/zones?"+url.Values{"name": {candidate}}.Encode(), nil, &zones); err != nil {
+			return "", err
+		}
+		for _, zone := range zones {
+			if strings.EqualFold(zone.Name, candidate) {
+				return zone.ID, nil
+			}
+		}
+		_, candidate, _ = strings.Cut(candidate, ".")
+	}
+
+	return "", &NotFoundError{Domain: domain}
+}
+
+func (p *Cloudflare) GetRecord(ctx context.Context, zoneID, fqdn, recordType string) (*Record, error) {
+	found, err := p.listRecords(ctx, zoneID, fqdn, recordType)
+	if err != nil || len(found) == 0 {
+		return nil, err
+	}
+
+	record := &Record{Name: strings.TrimSuffix(fqdn, "."), Type: recordType, TTL: found[0].TTL, Routing: "simple"}
+	for _, r := range found {
+		record.Values = append(record.Values, r.Content)
+		if r.Proxied {
+			record.Routing = "proxied"
+		}
+	}
+
+	return record, nil
+}
+
+// UpsertRecord replaces the records of the name and type with one record per value, updating records
+// in place so their id, proxy setting and history are kept, and returns the id of the first record
+func (p *Cloudflare) UpsertRecord(ctx context.Context, zoneID string, record Record, comment string) (string, error) {
+	existing, err := p.listRecords(ctx, zoneID, record.Name, record.Type)
+	if err != nil {
+		return "", err
+	}
+	if len(comment) > cloudflareCommentLimit {
+		comment = comment[:cloudflareCommentLimit]
+	}
+
+	var firstID string
+	for i, value := range record.Values {
+		desired := cloudflareRecord{
+			Type:    record.Type,
+			Name:    strings.TrimSuffix(record.Name, "."),
+			Content: value,
+			TTL:     record.TTL,
+			Comment: comment,
+		}
+
+		var written cloudflareRecord
+		if i < len(existing) {
+			desired.Proxied = existing[i].Proxied
+			err = p.do(ctx, http.MethodPut, "/zones/"+zoneID+"/dns_records/"+existing[i].ID, desired, &written)
+		} else {
+			err = p.do(ctx, http.MethodPost, "/zones/"+zoneID+"/dns_records", desired, &written)
+		}
+		if err != nil {
+			return "", err
+		}
+		if firstID == "" {
+			firstID = written.ID
+		}
+	}
+
+	// values no longer wanted are removed once the wanted ones are in place
+	for i := len(record.Values); i < len(existing); i++ {
+		if err := p.do(ctx, http.MethodDelete, "/zones/"+zoneID+"/dns_records/"+existing[i].ID, nil, nil); err != nil {
+			return firstID, err
+		}
+	}
+
+	return firstID, nil
+}
+
+// DeleteRecord deletes the records of the name and type holding the values of record, and returns the
+// id of the last record deleted
+func (p *Cloudflare) DeleteRecord(ctx context.Context, zoneID string, record Record, _ string) (string, error) {
+	existing, err := p.listRecords(ctx, zoneID, record.Name, record.Type)
+	if err != nil {
+		return "", err
+	}
+
+	var deletedID string
+	for _, r := range existing {
+		if !contains(record.Values, r.Content) {
+			continue
+		}
+		if err := p.do(ctx, http.MethodDelete, "/zones/"+zoneID+"/dns_records/"+r.ID, nil, nil); err != nil {
+			return "", err
+		}
+		deletedID = r.ID
+	}
+
+	return deletedID, nil
+}
+
+// listRecords returns the records named fqdn of type recordType in the zone
+func (p *Cloudflare) listRecords(ctx context.Context, zoneID, fqdn, recordType string) ([]cloudflareRecord, error) {
+	query := url.Values{"name": {strings.TrimSuffix(fqdn, ".")}, "type": {recordType}}
+	var found []cloudflareRecord
+	if err := p.do(ctx, http.MethodGet, "/zones/"+zoneID+"/dns_records?"+query.Encode(), nil, &found); err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+// do calls the api, decoding the result of its response envelope into result when not nil
+func (p *Cloudflare) do(ctx context.Context, method, path string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var envelope struct {
+		Success bool `json:"success"`
+		Errors  []struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return &APIError{Provider: p.Name(), Status: resp.StatusCode, Message: "response is not valid json: " + err.Error()}
+	}
+	if resp.StatusCode >= 300 || !envelope.Success {
+		messages := make([]string, 0, len(envelope.Errors))
+		for _, e := range envelope.Errors {
+			messages = append(messages, fmt.Sprintf("%d %s", e.Code, e.Message))
+		}
+		return throttled(p.Name(), &APIError{Provider: p.Name(), Status: resp.StatusCode, Message: strings.Join(messages, ", ")})
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(envelope.Result, result)
+}
+
+// contains reports whether values contains value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,59 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/smithy-go"
+	"net/http"
+)
+
+// ErrZoneNotFound is matched by the errors of providers finding no zone holding a record
+var ErrZoneNotFound = errors.New("zone not found")
+
+// NotFoundError reports that no hosted zone holds a domain
+type NotFoundError struct {
+	Domain string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s: %s", "could not find domain", e.Domain)
+}
+
+// Is matches ErrZoneNotFound
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrZoneNotFound
+}
+
+// ThrottledError reports that a provider rate limited a call, retrying later is expected to succeed
+type ThrottledError struct {
+	Provider string
+	Err      error
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%s %s: %v", "throttled by", e.Provider, e.Err)
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return e.Err
+}
+
+// throttled wraps err in a ThrottledError when it reports rate limiting, an aws throttling code or an
+// http 429 from a provider api
+func throttled(provider string, err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusTooManyRequests {
+		return &ThrottledError{Provider: provider, Err: err}
+	}
+
+	var awsErr smithy.APIError
+	if errors.As(err, &awsErr) {
+		switch awsErr.ErrorCode() {
+		case "Throttling", "ThrottlingException", "ThrottledException", "RequestLimitExceeded", "TooManyRequestsException",
+			"PriorRequestNotComplete":
+			return &ThrottledError{Provider: provider, Err: err}
+		}
+	}
+
+	return err
+}
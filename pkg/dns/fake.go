@@ -0,0 +1,164 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeRoute53 is an in-memory route53 holding the record sets of a fixed set of hosted zones, so
+// updates can be exercised without aws credentials. changes are applied immediately and lost on exit
+type FakeRoute53 struct {
+	mu sync.Mutex
+	// zones maps the name of each zone, with the trailing dot, to its id
+	zones map[string]string
+	// sets holds the record sets of each zone by name and type
+	sets    map[string]map[string]route53types.ResourceRecordSet
+	changes int
+}
+
+// NewFakeRoute53 returns an in-memory route53 hosting an empty zone for each of zones
+func NewFakeRoute53(zones ...string) *FakeRoute53 {
+	f := &FakeRoute53{zones: map[string]string{}, sets: map[string]map[string]route53types.ResourceRecordSet{}}
+	for i, zone := range zones {
+		id := fmt.Sprintf("FAKEZONE%d", i+1)
+		f.zones[strings.TrimSuffix(strings.ToLower(zone), ".")+"."] = id
+		f.sets[id] = map[string]route53types.ResourceRecordSet{}
+	}
+
+	return f
+}
+
+// NewFake returns a provider named fake updating the zones of an in-memory route53, for trying the
+// daemon out without touching real records
+func NewFake(zones ...string) *Route53 {
+	return &Route53{name: "fake", client: NewFakeRoute53(zones...)}
+}
+
+// ListHostedZonesByName returns the zone named DNSName, unlike route53 zones following it are not listed
+func (f *FakeRoute53) ListHostedZonesByName(_ context.Context, params *route53.ListHostedZonesByNameInput, _ ...func(*route53.Options)) (*route53.ListHostedZonesByNameOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := strings.ToLower(aws.ToString(params.DNSName))
+	out := &route53.ListHostedZonesByNameOutput{}
+	if id, ok := f.zones[name]; ok {
+		out.HostedZones = []route53types.HostedZone{{Id: aws.String("/hostedzone/" + id), Name: aws.String(name)}}
+	}
+
+	return out, nil
+}
+
+// ListResourceRecordSets returns the record set named StartRecordName of type StartRecordType, or every
+// set of the name ordered by type when no type is given. unlike route53 sets of other names are not listed
+func (f *FakeRoute53) ListResourceRecordSets(_ context.Context, params *route53.ListResourceRecordSetsInput, _ ...func(*route53.Options)) (*route53.ListResourceRecordSetsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sets, ok := f.sets[aws.ToString(params.HostedZoneId)]
+	if !ok {
+		return nil, &route53types.NoSuchHostedZone{Message: params.HostedZoneId}
+	}
+
+	out := &route53.ListResourceRecordSetsOutput{}
+	if params.StartRecordType == "" {
+		prefix := setKey(aws.ToString(params.StartRecordName), "")
+		for key, set := range sets {
+			if strings.HasPrefix(key, prefix) {
+				out.ResourceRecordSets = append(out.ResourceRecordSets, set)
+			}
+		}
+		slices.SortFunc(out.ResourceRecordSets, func(a, b route53types.ResourceRecordSet) int {
+			return strings.Compare(string(a.Type), string(b.Type))
+		})
+		return out, nil
+	}
+	if set, ok := sets[setKey(aws.ToString(params.StartRecordName), params.StartRecordType)]; ok {
+		out.ResourceRecordSets = []route53types.ResourceRecordSet{set}
+	}
+
+	return out, nil
+}
+
+// ChangeResourceRecordSets applies the changes of the batch, failing the whole batch like route53 when
+// a set is created twice or a deleted set doesn't match the one held
+func (f *FakeRoute53) ChangeResourceRecordSets(_ context.Context, params *route53.ChangeResourceRecordSetsInput, _ ...func(*route53.Options)) (*route53.ChangeResourceRecordSetsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	held, ok := f.sets[aws.ToString(params.HostedZoneId)]
+	if !ok {
+		return nil, &route53types.NoSuchHostedZone{Message: params.HostedZoneId}
+	}
+
+	// changes are applied to a copy so a failing change leaves the zone untouched
+	sets := make(map[string]route53types.ResourceRecordSet, len(held))
+	for key, set := range held {
+		sets[key] = set
+	}
+	for _, change := range params.ChangeBatch.Changes {
+		set := change.ResourceRecordSet
+		if set == nil {
+			return nil, &route53types.InvalidInput{Message: aws.String("change has no record set")}
+		}
+		key := setKey(aws.ToString(set.Name), set.Type)
+		current, exists := sets[key]
+		switch change.Action {
+		case route53types.ChangeActionCreate:
+			if exists {
+				return nil, invalidChangeBatch("record set already exists", key)
+			}
+			sets[key] = *set
+		case route53types.ChangeActionUpsert:
+			sets[key] = *set
+		case route53types.ChangeActionDelete:
+			if !exists || !sameRecordSet(current, *set) {
+				return nil, invalidChangeBatch("record set to delete was not found", key)
+			}
+			delete(sets, key)
+		default:
+			return nil, &route53types.InvalidInput{Message: aws.String("unsupported change action " + string(change.Action))}
+		}
+	}
+	f.sets[aws.ToString(params.HostedZoneId)] = sets
+
+	f.changes++
+	now := time.Now().UTC()
+
+	return &route53.ChangeResourceRecordSetsOutput{ChangeInfo: &route53types.ChangeInfo{
+		Id:          aws.String(fmt.Sprintf("/change/FAKECHANGE%d", f.changes)),
+		Status:      route53types.ChangeStatusInsync,
+		SubmittedAt: &now,
+		Comment:     params.ChangeBatch.Comment,
+	}}, nil
+}
+
+// setKey identifies the record set named name of type recordType within a zone
+func setKey(name string, recordType route53types.RRType) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".") + ".|" + string(recordType)
+}
+
+// sameRecordSet reports whether two simple record sets hold the same ttl and values
+func sameRecordSet(a, b route53types.ResourceRecordSet) bool {
+	if aws.ToInt64(a.TTL) != aws.ToInt64(b.TTL) || len(a.ResourceRecords) != len(b.ResourceRecords) {
+		return false
+	}
+	for i := range a.ResourceRecords {
+		if aws.ToString(a.ResourceRecords[i].Value) != aws.ToString(b.ResourceRecords[i].Value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// invalidChangeBatch is the error route53 returns for a batch that can't be applied
+func invalidChangeBatch(message, key string) error {
+	return &route53types.InvalidChangeBatch{Messages: []string{fmt.Sprintf("%s: %s", message, key)}}
+}
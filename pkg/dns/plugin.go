@@ -0,0 +1,177 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// PluginProtocolVersion is the version of the plugin protocol sent with every request
+const PluginProtocolVersion = 1
+
+// plugin methods, one per provider call
+const (
+	PluginMethodResolveZone  = "resolve_zone"
+	PluginMethodGetRecord    = "get_record"
+	PluginMethodUpsertRecord = "upsert_record"
+	PluginMethodDeleteRecord = "delete_record"
+)
+
+// PluginRecord is a record as it is exchanged with plugins
+type PluginRecord struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	TTL     int64    `json:"ttl"`
+	Values  []string `json:"values"`
+	Routing string   `json:"routing,omitempty"`
+	// SetIdentifier and Weight are passed on for plugins supporting weighted records
+	SetIdentifier string `json:"set_identifier,omitempty"`
+	Weight        *int64 `json:"weight,omitempty"`
+}
+
+// PluginRequest is written as json to the stdin of the plugin, a request per run
+type PluginRequest struct {
+	Version    int           `json:"version"`
+	Method     string        `json:"method"`
+	FQDN       string        `json:"fqdn,omitempty"`
+	ZoneID     string        `json:"zone_id,omitempty"`
+	RecordType string        `json:"record_type,omitempty"`
+	Record     *PluginRecord `json:"record,omitempty"`
+	Comment    string        `json:"comment,omitempty"`
+}
+
+// PluginResponse is read as json from the stdout of the plugin, a plugin failing a request sets
+// Error and, when no zone holds the name asked to resolve, NotFound
+type PluginResponse struct {
+	ZoneID   string        `json:"zone_id,omitempty"`
+	Record   *PluginRecord `json:"record,omitempty"`
+	ChangeID string        `json:"change_id,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	NotFound bool          `json:"not_found,omitempty"`
+}
+
+// PluginError is a request the plugin answered with an error
+type PluginError struct {
+	Plugin  string
+	Method  string
+	Message string
+}
+
+func (e *PluginError) Error() string {
+	return fmt.Sprintf("%s %s %s: %s", "plugin", e.Plugin, e.Method, e.Message)
+}
+
+// Plugin is the provider of zones updated by an external executable, run once per call with a json
+// request on stdin and answering with a json response on stdout, so registrars without a built in
+// provider can be supported without changing the daemon. anything the plugin writes to stderr is
+// included in the error when it fails
+type Plugin struct {
+	name    string
+	path    string
+	timeout time.Duration
+}
+
+// NewPlugin returns a provider named name running the executable at path
+func NewPlugin(name, path string) *Plugin {
+	return &Plugin{name: name, path: path, timeout: 30 * time.Second}
+}
+
+func (p *Plugin) Name() string {
+	return p.name
+}
+
+func (p *Plugin) ResolveZone(ctx context.Context, fqdn string) (string, error) {
+	resp, err := p.call(ctx, PluginRequest{Method: PluginMethodResolveZone, FQDN: fqdn})
+	if err != nil {
+		return "", err
+	}
+	if resp.NotFound || resp.ZoneID == "" {
+		_, domain, _ := strings.Cut(strings.TrimSuffix(fqdn, "."), ".")
+		return "", &NotFoundError{Domain: domain}
+	}
+
+	return resp.ZoneID, nil
+}
+
+func (p *Plugin) GetRecord(ctx context.Context, zoneID, fqdn, recordType string) (*Record, error) {
+	resp, err := p.call(ctx, PluginRequest{Method: PluginMethodGetRecord, FQDN: fqdn, ZoneID: zoneID, RecordType: recordType})
+	if err != nil || resp.Record == nil {
+		return nil, err
+	}
+
+	record := Record(*resp.Record)
+	if record.Routing == "" {
+		record.Routing = "simple"
+	}
+
+	return &record, nil
+}
+
+func (p *Plugin) UpsertRecord(ctx context.Context, zoneID string, record Record, comment string) (string, error) {
+	return p.change(ctx, PluginMethodUpsertRecord, zoneID, record, comment)
+}
+
+func (p *Plugin) DeleteRecord(ctx context.Context, zoneID string, record Record, comment string) (string, error) {
+	return p.change(ctx, PluginMethodDeleteRecord, zoneID, record, comment)
+}
+
+// change sends a change of record to the plugin and returns the id of the change it answers with
+func (p *Plugin) change(ctx context.Context, method, zoneID string, record Record, comment string) (string, error) {
+	sent := PluginRecord(record)
+	resp, err := p.call(ctx, PluginRequest{Method: method, FQDN: record.Name, ZoneID: zoneID, RecordType: record.Type,
+		Record: &sent, Comment: comment})
+	if err != nil {
+		return "", err
+	}
+
+	return resp.ChangeID, nil
+}
+
+// call runs the plugin with request and decodes its response, the plugin is killed once its timeout
+// passes
+func (p *Plugin) call(ctx context.Context, request PluginRequest) (*PluginResponse, error) {
+	request.Version = PluginProtocolVersion
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.path)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	runErr := cmd.Run()
+	var resp PluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		if runErr != nil {
+			return nil, &PluginError{Plugin: p.name, Method: request.Method, Message: pluginFailure(runErr, stderr.String())}
+		}
+		return nil, fmt.Errorf("%s %s: %w", "plugin responded with invalid json", p.name, err)
+	}
+	if resp.Error != "" {
+		return nil, &PluginError{Plugin: p.name, Method: request.Method, Message: resp.Error}
+	}
+	if runErr != nil {
+		return nil, &PluginError{Plugin: p.name, Method: request.Method, Message: pluginFailure(runErr, stderr.String())}
+	}
+
+	return &resp, nil
+}
+
+// pluginFailure describes a plugin that exited without answering, with what it wrote to stderr
+func pluginFailure(err error, stderr string) string {
+	if stderr = strings.TrimSpace(stderr); stderr != "" {
+		return fmt.Sprintf("%v: %s", err, stderr)
+	}
+
+	return err.Error()
+}
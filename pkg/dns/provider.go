@@ -0,0 +1,43 @@
+// Package dns reads and changes the records of dns providers, finding the zone holding a record and
+// submitting changes to it, with route53 clients following a configurable retry policy
+package dns
+
+import (
+	"context"
+)
+
+// Record is a record set as a provider holds it
+type Record struct {
+	// Name is the fully qualified name without the trailing dot
+	Name string
+	// Type is the record type, e.g. A
+	Type string
+	// TTL is how long resolvers cache the record, in seconds
+	TTL int64
+	// Values are the addresses or other data the record answers with
+	Values []string
+	// Routing describes how the provider chooses between sets sharing the name, "simple" when it
+	// answers with every value
+	Routing string
+	// SetIdentifier and Weight make the record one weighted set among those sharing its name and type,
+	// only route53 honors them
+	SetIdentifier string
+	Weight        *int64
+}
+
+// Provider reads and changes the records of a dns service, so updates are submitted the same way
+// whichever service hosts the zone
+type Provider interface {
+	// Name identifies the provider in logs and traces
+	Name() string
+	// ResolveZone returns the id of the zone holding the record named fqdn, a NotFoundError when no
+	// zone holds it
+	ResolveZone(ctx context.Context, fqdn string) (string, error)
+	// GetRecord returns the record named fqdn of type recordType in the zone, or nil when there is none
+	GetRecord(ctx context.Context, zoneID, fqdn, recordType string) (*Record, error)
+	// UpsertRecord creates record or replaces the record of the same name and type, returning the id
+	// of the change when the provider tracks changes
+	UpsertRecord(ctx context.Context, zoneID string, record Record, comment string) (string, error)
+	// DeleteRecord deletes record, which must match the record held by the provider
+	DeleteRecord(ctx context.Context, zoneID string, record Record, comment string) (string, error)
+}
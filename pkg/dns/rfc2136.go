@@ -0,0 +1,194 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	mdns "github.com/miekg/dns"
+	"net"
+	"strings"
+	"time"
+)
+
+// DefaultTSIGAlgorithm signs updates when no algorithm is configured
+const DefaultTSIGAlgorithm = "hmac-sha256"
+
+// UpdateError is a dns response with an error code, e.g. REFUSED when the server does not accept
+// updates from the key
+type UpdateError struct {
+	Server string
+	Rcode  int
+}
+
+func (e *UpdateError) Error() string {
+	return fmt.Sprintf("%s %s: %s", "dns server", e.Server, mdns.RcodeToString[e.Rcode])
+}
+
+// Unauthorized reports whether the server refused the key, servers refuse updates the key is not
+// allowed to make and answer notauth when its signature does not verify
+func (e *UpdateError) Unauthorized() bool {
+	return e.Rcode == mdns.RcodeRefused || e.Rcode == mdns.RcodeNotAuth
+}
+
+// RFC2136 is the provider of zones served by an authoritative server accepting rfc 2136 dynamic
+// updates, e.g. bind, knot or powerdns, signed with a tsig key when one is configured
+type RFC2136 struct {
+	server    string
+	keyName   string
+	algorithm string
+	secret    string
+	timeout   time.Duration
+}
+
+// NewRFC2136 returns a provider sending queries and updates to server, a host with an optional port,
+// signing them with the base64 secret of the tsig key keyName using algorithm, e.g. hmac-sha256, or
+// unsigned when keyName is empty
+func NewRFC2136(server, keyName, algorithm, secret string) (*RFC2136, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	if algorithm == "" {
+		algorithm = DefaultTSIGAlgorithm
+	}
+	algorithm = mdns.Fqdn(strings.ToLower(algorithm))
+	switch algorithm {
+	case mdns.HmacSHA1, mdns.HmacSHA224, mdns.HmacSHA256, mdns.HmacSHA384, mdns.HmacSHA512:
+	default:
+		return nil, fmt.Errorf("%s: %s", "unsupported tsig algorithm", algorithm)
+	}
+	if keyName != "" && secret == "" {
+		return nil, fmt.Errorf("%s: %s", "tsig key has no secret", keyName)
+	}
+
+	return &RFC2136{server: server, keyName: keyName, algorithm: algorithm, secret: secret, timeout: 10 * time.Second}, nil
+}
+
+func (p *RFC2136) Name() string {
+	return "rfc2136"
+}
+
+// ResolveZone returns the zone holding the record named fqdn, the owner of the soa record the server
+// answers for the name
+func (p *RFC2136) ResolveZone(ctx context.Context, fqdn string) (string, error) {
+	query := new(mdns.Msg)
+	query.SetQuestion(mdns.Fqdn(fqdn), mdns.TypeSOA)
+	resp, err := p.exchange(ctx, query)
+	if err != nil {
+		return "", err
+	}
+
+	// the name is the apex when it owns the soa, otherwise the soa of its zone comes as authority
+	for _, rr := range append(resp.Answer, resp.Ns...) {
+		if soa, ok := rr.(*mdns.SOA); ok {
+			return strings.TrimSuffix(soa.Hdr.Name, "."), nil
+		}
+	}
+	_, domain, _ := strings.Cut(strings.TrimSuffix(fqdn, "."), ".")
+
+	return "", &NotFoundError{Domain: domain}
+}
+
+func (p *RFC2136) GetRecord(ctx context.Context, _, fqdn, recordType string) (*Record, error) {
+	rrType, ok := mdns.StringToType[recordType]
+	if !ok {
+		return nil, fmt.Errorf("%s: %s", "unsupported record type", recordType)
+	}
+
+	query := new(mdns.Msg)
+	query.SetQuestion(mdns.Fqdn(fqdn), rrType)
+	resp, err := p.exchange(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var record *Record
+	for _, rr := range resp.Answer {
+		header := rr.Header()
+		if header.Rrtype != rrType || !strings.EqualFold(header.Name, mdns.Fqdn(fqdn)) {
+			continue
+		}
+		if record == nil {
+			record = &Record{Name: strings.TrimSuffix(fqdn, "."), Type: recordType, TTL: int64(header.Ttl), Routing: "simple"}
+		}
+		record.Values = append(record.Values, strings.TrimPrefix(rr.String(), header.String()))
+	}
+
+	return record, nil
+}
+
+// UpsertRecord replaces the record set of the name and type in a single update, the id of the update
+// message is returned since servers keep no change history
+func (p *RFC2136) UpsertRecord(ctx context.Context, zoneID string, record Record, _ string) (string, error) {
+	rrs, err := resourceRecords(record)
+	if err != nil {
+		return "", err
+	}
+
+	update := new(mdns.Msg)
+	update.SetUpdate(mdns.Fqdn(zoneID))
+	update.RemoveRRset([]mdns.RR{&mdns.ANY{Hdr: mdns.RR_Header{Name: mdns.Fqdn(record.Name), Rrtype: rrs[0].Header().Rrtype, Class: mdns.ClassINET}}})
+	update.Insert(rrs)
+
+	return p.send(ctx, update)
+}
+
+// DeleteRecord deletes the values of record from the record set of the name and type
+func (p *RFC2136) DeleteRecord(ctx context.Context, zoneID string, record Record, _ string) (string, error) {
+	rrs, err := resourceRecords(record)
+	if err != nil {
+		return "", err
+	}
+
+	update := new(mdns.Msg)
+	update.SetUpdate(mdns.Fqdn(zoneID))
+	update.Remove(rrs)
+
+	return p.send(ctx, update)
+}
+
+// send sends update and returns the id of the message
+func (p *RFC2136) send(ctx context.Context, update *mdns.Msg) (string, error) {
+	if _, err := p.exchange(ctx, update); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%d", update.Id), nil
+}
+
+// exchange sends msg to the server, signed when a key is configured, over tcp so large answers and
+// updates are never truncated
+func (p *RFC2136) exchange(ctx context.Context, msg *mdns.Msg) (*mdns.Msg, error) {
+	client := &mdns.Client{Net: "tcp", Timeout: p.timeout}
+	if p.keyName != "" {
+		keyName := mdns.Fqdn(p.keyName)
+		client.TsigSecret = map[string]string{keyName: p.secret}
+		msg.SetTsig(keyName, p.algorithm, 300, time.Now().Unix())
+	}
+
+	resp, _, err := client.ExchangeContext(ctx, msg, p.server)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != mdns.RcodeSuccess && resp.Rcode != mdns.RcodeNameError {
+		return nil, &UpdateError{Server: p.server, Rcode: resp.Rcode}
+	}
+
+	return resp, nil
+}
+
+// resourceRecords returns a resource record per value of record
+func resourceRecords(record Record) ([]mdns.RR, error) {
+	if len(record.Values) == 0 {
+		return nil, fmt.Errorf("%s: %s", "record has no values", record.Name)
+	}
+
+	rrs := make([]mdns.RR, 0, len(record.Values))
+	for _, value := range record.Values {
+		rr, err := mdns.NewRR(fmt.Sprintf("%s %d IN %s %s", mdns.Fqdn(record.Name), record.TTL, record.Type, value))
+		if err != nil {
+			return nil, err
+		}
+		rrs = append(rrs, rr)
+	}
+
+	return rrs, nil
+}
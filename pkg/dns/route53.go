@@ -0,0 +1,286 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"strings"
+	"time"
+)
+
+// sdk retry modes
+const (
+	RetryModeStandard = "standard"
+	RetryModeAdaptive = "adaptive"
+)
+
+// ValidRetryMode reports whether mode is a supported sdk retry mode
+func ValidRetryMode(mode string) bool {
+	return mode == RetryModeStandard || mode == RetryModeAdaptive
+}
+
+// RetryOptions is the retry policy of route53 clients
+type RetryOptions struct {
+	// Mode selects the retryer, standard when empty
+	Mode string
+	// MaxAttempts bounds the attempts of a single call, zero keeps the sdk default
+	MaxAttempts int
+	// RequestTimeout bounds a single attempt of a call, zero disables the timeout
+	RequestTimeout time.Duration
+}
+
+// retryer returns a retryer following the policy, adaptive retryers slow down client side once
+// throttling starts
+func (o RetryOptions) retryer() aws.Retryer {
+	standard := func(so *retry.StandardOptions) {
+		if o.MaxAttempts > 0 {
+			so.MaxAttempts = o.MaxAttempts
+		}
+	}
+
+	if o.Mode == RetryModeAdaptive {
+		return retry.NewAdaptiveMode(func(ao *retry.AdaptiveModeOptions) {
+			ao.StandardOptions = append(ao.StandardOptions, standard)
+		})
+	}
+
+	return retry.NewStandard(standard)
+}
+
+// NewRoute53Client creates a route53 client from cfg following the retry policy, route53 is a global
+// service so no region needs to be configured
+func NewRoute53Client(cfg aws.Config, retryOptions RetryOptions) *route53.Client {
+	return route53.NewFromConfig(cfg, func(o *route53.Options) {
+		if o.Region == "" {
+			o.Region = "us-east-1"
+		}
+		o.Retryer = retryOptions.retryer()
+		if retryOptions.RequestTimeout > 0 {
+			o.HTTPClient = awshttp.NewBuildableClient().WithTimeout(retryOptions.RequestTimeout)
+		}
+	})
+}
+
+// Route53API is the part of the route53 client the provider calls, implemented by route53 clients
+// and by FakeRoute53
+type Route53API interface {
+	ListHostedZonesByName(ctx context.Context, params *route53.ListHostedZonesByNameInput, optFns ...func(*route53.Options)) (*route53.ListHostedZonesByNameOutput, error)
+	ListResourceRecordSets(ctx context.Context, params *route53.ListResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ListResourceRecordSetsOutput, error)
+	ChangeResourceRecordSets(ctx context.Context, params *route53.ChangeResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ChangeResourceRecordSetsOutput, error)
+}
+
+// Change is a change to a record set, submitted along with others by ChangeRecords
+type Change struct {
+	// Delete deletes Record, which must match the record held, instead of creating or replacing it
+	Delete bool
+	Record Record
+}
+
+// Route53 is the provider of zones hosted in route53
+type Route53 struct {
+	name   string
+	client Route53API
+}
+
+// NewRoute53 returns a provider submitting changes with client
+func NewRoute53(client Route53API) *Route53 {
+	return &Route53{name: "route53", client: client}
+}
+
+func (p *Route53) Name() string {
+	return p.name
+}
+
+// ResolveZone returns the id of the hosted zone holding the record named fqdn, the zone of its parent
+// domain
+func (p *Route53) ResolveZone(ctx context.Context, fqdn string) (string, error) {
+	_, domain, found := strings.Cut(fqdn, ".")
+	if !found || domain == "" {
+		return "", fmt.Errorf("%s: %s", "hostname has no domain", fqdn)
+	}
+
+	resp, err := p.client.ListHostedZonesByName(ctx, &route53.ListHostedZonesByNameInput{
+		DNSName:  aws.String(domain + "."),
+		MaxItems: aws.Int32(1),
+	})
+	if err != nil {
+		return "", throttled(p.Name(), err)
+	}
+	if len(resp.HostedZones) != 1 || aws.ToString(resp.HostedZones[0].Name) != domain+"." {
+		return "", &NotFoundError{Domain: domain}
+	}
+
+	return strings.TrimPrefix(aws.ToString(resp.HostedZones[0].Id), "/hostedzone/"), nil
+}
+
+func (p *Route53) GetRecord(ctx context.Context, zoneID, fqdn, recordType string) (*Record, error) {
+	set, err := p.getRecordSet(ctx, zoneID, fqdn, route53types.RRType(recordType))
+	if err != nil || set == nil {
+		return nil, err
+	}
+
+	record := recordOf(set)
+
+	return &record, nil
+}
+
+// ListRecords returns the record sets named fqdn in the zone whatever their type, reading them with a
+// single call unless the name holds more sets than a page of results
+func (p *Route53) ListRecords(ctx context.Context, zoneID, fqdn string) ([]Record, error) {
+	name := strings.TrimSuffix(fqdn, ".") + "."
+	input := &route53.ListResourceRecordSetsInput{
+		StartRecordName: aws.String(name),
+		HostedZoneId:    aws.String(zoneID),
+	}
+
+	var records []Record
+	for {
+		resp, err := p.client.ListResourceRecordSets(ctx, input)
+		if err != nil {
+			return nil, throttled(p.Name(), err)
+		}
+
+		// listing starts at the name, so the sets of later names follow its own
+		for _, set := range resp.ResourceRecordSets {
+			if !strings.EqualFold(aws.ToString(set.Name), name) {
+				return records, nil
+			}
+			records = append(records, recordOf(&set))
+		}
+		if !resp.IsTruncated {
+			return records, nil
+		}
+		input.StartRecordName, input.StartRecordType, input.StartRecordIdentifier = resp.NextRecordName, resp.NextRecordType, resp.NextRecordIdentifier
+	}
+}
+
+func (p *Route53) UpsertRecord(ctx context.Context, zoneID string, record Record, comment string) (string, error) {
+	return p.changeRecordSets(ctx, zoneID, []route53types.Change{{Action: route53types.ChangeActionUpsert, ResourceRecordSet: recordSet(record)}}, comment)
+}
+
+func (p *Route53) DeleteRecord(ctx context.Context, zoneID string, record Record, comment string) (string, error) {
+	if record.Routing != "" && record.Routing != "simple" && record.SetIdentifier == "" {
+		return "", fmt.Errorf("%s: %s", "only simple records can be deleted, not", record.Routing)
+	}
+
+	return p.changeRecordSets(ctx, zoneID, []route53types.Change{{Action: route53types.ChangeActionDelete, ResourceRecordSet: recordSet(record)}}, comment)
+}
+
+// ChangeRecords submits changes as a single batch, which route53 applies as a whole or not at all,
+// and returns the id of the change
+func (p *Route53) ChangeRecords(ctx context.Context, zoneID string, changes []Change, comment string) (string, error) {
+	batch := make([]route53types.Change, 0, len(changes))
+	for _, change := range changes {
+		action := route53types.ChangeActionUpsert
+		if change.Delete {
+			action = route53types.ChangeActionDelete
+		}
+		batch = append(batch, route53types.Change{Action: action, ResourceRecordSet: recordSet(change.Record)})
+	}
+
+	return p.changeRecordSets(ctx, zoneID, batch, comment)
+}
+
+// getRecordSet returns the record set named fqdn of type recordType in the zone, or nil when there
+// is none
+func (p *Route53) getRecordSet(ctx context.Context, zoneID, fqdn string, recordType route53types.RRType) (*route53types.ResourceRecordSet, error) {
+	resp, err := p.client.ListResourceRecordSets(ctx, &route53.ListResourceRecordSetsInput{
+		StartRecordName: aws.String(fqdn),
+		StartRecordType: recordType,
+		HostedZoneId:    aws.String(zoneID),
+		MaxItems:        aws.Int32(1),
+	})
+	if err != nil {
+		return nil, throttled(p.Name(), err)
+	}
+
+	// listing starts at the name, so the first set is a later record when this one is missing
+	if len(resp.ResourceRecordSets) != 1 {
+		return nil, nil
+	}
+	set := resp.ResourceRecordSets[0]
+	if aws.ToString(set.Name) != strings.TrimSuffix(fqdn, ".")+"." || set.Type != recordType {
+		return nil, nil
+	}
+
+	return &set, nil
+}
+
+// changeRecordSets submits changes to the zone as one batch tagged with comment, and returns the id
+// of the change
+func (p *Route53) changeRecordSets(ctx context.Context, zoneID string, changes []route53types.Change, comment string) (string, error) {
+	change, err := p.client.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53types.ChangeBatch{
+			Changes: changes,
+			Comment: aws.String(comment),
+		},
+		HostedZoneId: aws.String(zoneID),
+	})
+	if err != nil {
+		return "", throttled(p.Name(), err)
+	}
+
+	return aws.ToString(change.ChangeInfo.Id), nil
+}
+
+// recordOf returns the record held by set
+func recordOf(set *route53types.ResourceRecordSet) Record {
+	record := Record{
+		Name:    strings.TrimSuffix(aws.ToString(set.Name), "."),
+		Type:    string(set.Type),
+		TTL:     aws.ToInt64(set.TTL),
+		Routing: routingPolicy(set),
+	}
+	for _, value := range set.ResourceRecords {
+		record.Values = append(record.Values, aws.ToString(value.Value))
+	}
+
+	return record
+}
+
+// recordSet returns the simple or weighted record set holding record
+func recordSet(record Record) *route53types.ResourceRecordSet {
+	set := &route53types.ResourceRecordSet{
+		Name: aws.String(strings.TrimSuffix(record.Name, ".") + "."),
+		Type: route53types.RRType(record.Type),
+		TTL:  aws.Int64(record.TTL),
+	}
+	if record.SetIdentifier != "" {
+		set.SetIdentifier = aws.String(record.SetIdentifier)
+		set.Weight = record.Weight
+	}
+	for _, value := range record.Values {
+		set.ResourceRecords = append(set.ResourceRecords, route53types.ResourceRecord{Value: aws.String(value)})
+	}
+
+	return set
+}
+
+// routingPolicy describes the routing policy of a record set
+func routingPolicy(set *route53types.ResourceRecordSet) string {
+	var policy string
+	switch {
+	case set.Weight != nil:
+		policy = fmt.Sprintf("weighted(%d)", *set.Weight)
+	case set.Region != "":
+		policy = "latency(" + string(set.Region) + ")"
+	case set.Failover != "":
+		policy = "failover(" + strings.ToLower(string(set.Failover)) + ")"
+	case set.GeoLocation != nil:
+		policy = "geolocation"
+	case aws.ToBool(set.MultiValueAnswer):
+		policy = "multivalue"
+	default:
+		return "simple"
+	}
+
+	if set.SetIdentifier != nil {
+		policy += " id=" + *set.SetIdentifier
+	}
+
+	return policy
+}
@@ -0,0 +1,134 @@
+// Package docker is a minimal client of the docker engine api, enough to list containers and follow
+// their events without depending on the docker sdk
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultHost is the socket of the local docker daemon
+const DefaultHost = "unix:///var/run/docker.sock"
+
+// Client calls the engine api of a docker daemon
+type Client struct {
+	baseURL string
+	client  *http.Client
+}
+
+// New returns a client of the daemon at host, a unix:// socket or a tcp:// address
+func New(host string) (*Client, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport := &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		}}
+		return &Client{baseURL: "http://docker", client: &http.Client{Transport: transport}}, nil
+	case "tcp", "http":
+		return &Client{baseURL: "http://" + u.Host, client: http.DefaultClient}, nil
+	default:
+		return nil, fmt.Errorf("%s: %s", "not a unix:// or tcp:// docker host", host)
+	}
+}
+
+// Container is a container as listed by the api
+type Container struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Labels map[string]string `json:"Labels"`
+	State  string            `json:"State"`
+}
+
+// Event is something that happened to an object, for containers the attributes of the actor hold
+// its labels and name
+type Event struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+	Time int64 `json:"time"`
+}
+
+// Containers returns the running containers carrying label
+func (c *Client) Containers(ctx context.Context, label string) ([]Container, error) {
+	filters, _ := json.Marshal(map[string][]string{"label": {label}})
+	resp, err := c.get(ctx, "/containers/json?"+url.Values{"filters": {string(filters)}}.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var containers []Container
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, err
+	}
+
+	return containers, nil
+}
+
+// ContainerEvents calls fn with every container event since since until ctx is done, the daemon
+// closes the stream or fn fails
+func (c *Client) ContainerEvents(ctx context.Context, since time.Time, fn func(Event) error) error {
+	filters, _ := json.Marshal(map[string][]string{"type": {"container"}})
+	query := url.Values{"filters": {string(filters)}, "since": {strconv.FormatInt(since.Unix(), 10)}}
+	resp, err := c.get(ctx, "/events?"+query.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event Event
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+}
+
+// get sends a get request and returns the response when successful
+func (c *Client) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var body struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+		return nil, fmt.Errorf("docker api responded %d: %s", resp.StatusCode, strings.TrimSpace(body.Message))
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,47 @@
+package ipsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// EC2 reads the public address of the ec2 instance the process runs on from the instance metadata
+// service, it is read every time so a reassociated elastic ip is picked up
+type EC2 struct {
+	client *imds.Client
+}
+
+// NewEC2 returns a source reading the instance metadata service configured by cfg
+func NewEC2(cfg aws.Config) *EC2 {
+	return &EC2{client: imds.NewFromConfig(cfg)}
+}
+
+func (s *EC2) Name() string {
+	return "ec2"
+}
+
+func (s *EC2) IP(ctx context.Context) (string, error) {
+	resp, err := s.client.GetMetadata(ctx, &imds.GetMetadataInput{Path: "public-ipv4"})
+	if err != nil {
+		var respErr *awshttp.ResponseError
+		if errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound {
+			return "", withKind(KindConfig, fmt.Errorf("%s: %w", "instance has no public address", err))
+		}
+		return "", withKind(KindNetwork, err)
+	}
+	defer resp.Content.Close()
+
+	body, err := io.ReadAll(resp.Content)
+	if err != nil {
+		return "", withKind(KindNetwork, err)
+	}
+
+	return parseIP(strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,112 @@
+package ipsource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// ecsTaskMetadata is the part of the ecs task metadata v4 response needed to find the task's interface
+type ecsTaskMetadata struct {
+	TaskARN    string `json:"TaskARN"`
+	Containers []struct {
+		Networks []struct {
+			NetworkMode   string   `json:"NetworkMode"`
+			IPv4Addresses []string `json:"IPv4Addresses"`
+		} `json:"Networks"`
+	} `json:"Containers"`
+}
+
+// ECS finds the public address of the elastic network interface of the ecs task the process runs
+// in, by the task's private address in the task metadata
+type ECS struct {
+	cfg aws.Config
+	mu  sync.Mutex
+	// ip caches the public address of the task, which never changes during its lifetime
+	ip string
+}
+
+// NewECS returns a source looking the task's interface up with the credentials of cfg
+func NewECS(cfg aws.Config) *ECS {
+	return &ECS{cfg: cfg}
+}
+
+func (s *ECS) Name() string {
+	return "ecs"
+}
+
+func (s *ECS) IP(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ip != "" {
+		return s.ip, nil
+	}
+
+	metadataURI := os.Getenv("ECS_CONTAINER_METADATA_URI_V4")
+	if metadataURI == "" {
+		return "", withKind(KindConfig, fmt.Errorf("%s: %s", "not running in an ecs task, missing", "ECS_CONTAINER_METADATA_URI_V4"))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURI+"/task", nil)
+	if err != nil {
+		return "", withKind(KindConfig, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", withKind(KindNetwork, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", withKind(KindInvalidResponse, fmt.Errorf("%s: %s", "unexpected task metadata response", resp.Status))
+	}
+
+	var task ecsTaskMetadata
+	if err := json.NewDecoder(resp.Body).Decode(&task); err != nil {
+		return "", withKind(KindInvalidResponse, err)
+	}
+
+	var privateIP string
+	for _, container := range task.Containers {
+		for _, network := range container.Networks {
+			if network.NetworkMode == "awsvpc" && len(network.IPv4Addresses) > 0 {
+				privateIP = network.IPv4Addresses[0]
+			}
+		}
+	}
+	if privateIP == "" {
+		return "", withKind(KindConfig, fmt.Errorf("%s: %s", "task has no awsvpc network interface", task.TaskARN))
+	}
+
+	// the interface lives in the region of the task
+	taskARN, err := arn.Parse(task.TaskARN)
+	if err != nil {
+		return "", withKind(KindInvalidResponse, err)
+	}
+	client := ec2.NewFromConfig(s.cfg, func(o *ec2.Options) {
+		o.Region = taskARN.Region
+	})
+	interfaces, err := client.DescribeNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{
+		Filters: []ec2types.Filter{{Name: aws.String("addresses.private-ip-address"), Values: []string{privateIP}}},
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(interfaces.NetworkInterfaces) != 1 {
+		return "", withKind(KindInvalidResponse, fmt.Errorf("%s: %s", "could not find the network interface of", privateIP))
+	}
+
+	association := interfaces.NetworkInterfaces[0].Association
+	if association == nil || aws.ToString(association.PublicIp) == "" {
+		return "", withKind(KindConfig, fmt.Errorf("%s: %s", "task has no public address, enable assignPublicIp", aws.ToString(interfaces.NetworkInterfaces[0].NetworkInterfaceId)))
+	}
+	s.ip = aws.ToString(association.PublicIp)
+
+	return s.ip, nil
+}
@@ -0,0 +1,75 @@
+package ipsource
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// TailscalePrefix is the range tailscale assigns ipv4 addresses of tailnet nodes from
+var TailscalePrefix = netip.MustParsePrefix("100.64.0.0/10")
+
+// Interface reads the address of a local network interface, e.g. of a tailscale or wireguard tunnel,
+// it is read every time so an address assigned later is picked up
+type Interface struct {
+	name   string
+	prefix netip.Prefix
+}
+
+// NewInterface returns a source reading the first ipv4 address of the interface named name, or of
+// any interface holding an address within prefix when name is empty
+func NewInterface(name string, prefix netip.Prefix) *Interface {
+	return &Interface{name: name, prefix: prefix}
+}
+
+func (s *Interface) Name() string {
+	return "interface"
+}
+
+// String returns the interface read, or the range looked for
+func (s *Interface) String() string {
+	if s.name != "" {
+		return s.name
+	}
+
+	return s.prefix.String()
+}
+
+func (s *Interface) IP(_ context.Context) (string, error) {
+	var interfaces []net.Interface
+	if s.name != "" {
+		iface, err := net.InterfaceByName(s.name)
+		if err != nil {
+			return "", withKind(KindConfig, err)
+		}
+		interfaces = []net.Interface{*iface}
+	} else {
+		all, err := net.Interfaces()
+		if err != nil {
+			return "", withKind(KindNetwork, err)
+		}
+		interfaces = all
+	}
+
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return "", withKind(KindNetwork, err)
+		}
+		for _, addr := range addrs {
+			prefix, err := netip.ParsePrefix(addr.String())
+			if err != nil || !prefix.Addr().Is4() {
+				continue
+			}
+			if s.name != "" || s.prefix.Contains(prefix.Addr()) {
+				return prefix.Addr().String(), nil
+			}
+		}
+	}
+
+	return "", withKind(KindConfig, fmt.Errorf("%s: %s", "no interface holds an ipv4 address", s))
+}
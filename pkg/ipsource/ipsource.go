@@ -0,0 +1,116 @@
+// Package ipsource determines the public address of the host, from an http endpoint answering with
+// the caller's address or from the metadata of the ecs task or ec2 instance it runs in
+package ipsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// kinds of failure, so callers can tell a local outage apart from a broken source or configuration
+const (
+	KindNetwork         = "network"
+	KindInvalidResponse = "invalid-response"
+	KindConfig          = "config"
+)
+
+// ErrInvalidIP is matched by errors about an address that does not parse
+var ErrInvalidIP = errors.New("not a valid IP address")
+
+// Source determines the public address to publish
+type Source interface {
+	// Name identifies the source in logs and traces
+	Name() string
+	// IP returns the current address in its canonical form
+	IP(ctx context.Context) (string, error)
+}
+
+// Error is a failure to determine the address tagged with its kind
+type Error struct {
+	Kind string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// withKind tags err with kind
+func withKind(kind string, err error) error {
+	return &Error{Kind: kind, Err: err}
+}
+
+// URL asks an http endpoint answering with the caller's address in plain text, e.g.
+// https://checkip.amazonaws.com
+type URL struct {
+	url    string
+	client *http.Client
+}
+
+// NewURL returns a source asking url with client, or the default client when nil
+func NewURL(url string, client *http.Client) *URL {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &URL{url: url, client: client}
+}
+
+func (s *URL) Name() string {
+	return "url"
+}
+
+// String returns the url asked
+func (s *URL) String() string {
+	return s.url
+}
+
+func (s *URL) IP(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return "", withKind(KindConfig, err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", withKind(KindNetwork, err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			slog.WarnContext(ctx, "unable to close http socket", "error", err)
+		}
+	}(resp.Body)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", withKind(KindNetwork, err)
+	}
+	slog.DebugContext(ctx, "ip source responded", "url", s.url, "status", resp.StatusCode, "bytes", len(body))
+
+	if resp.StatusCode != http.StatusOK {
+		return "", withKind(KindInvalidResponse, fmt.Errorf("%s: %s", "unexpected ip source response", resp.Status))
+	}
+
+	return parseIP(strings.TrimSuffix(string(body), "\n"))
+}
+
+// parseIP returns the canonical form of value, ensuring it is an ip
+func parseIP(value string) (string, error) {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return "", withKind(KindInvalidResponse, fmt.Errorf("%w: %q", ErrInvalidIP, value))
+	}
+
+	return ip.String(), nil
+}
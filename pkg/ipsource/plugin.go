@@ -0,0 +1,84 @@
+package ipsource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// pluginRequest is written as json to the stdin of a source plugin
+type pluginRequest struct {
+	Version int    `json:"version"`
+	Method  string `json:"method"`
+}
+
+// pluginResponse is read as json from the stdout of a source plugin, a plugin unable to determine
+// the address sets Error instead
+type pluginResponse struct {
+	IP    string `json:"ip,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Plugin asks an external executable for the address, run once per check with a json request on
+// stdin and answering with a json response on stdout, for routers and modems only reachable with
+// their own tools
+type Plugin struct {
+	path    string
+	timeout time.Duration
+}
+
+// NewPlugin returns a source running the executable at path
+func NewPlugin(path string) *Plugin {
+	return &Plugin{path: path, timeout: 30 * time.Second}
+}
+
+func (s *Plugin) Name() string {
+	return "plugin"
+}
+
+// String returns the path of the executable run
+func (s *Plugin) String() string {
+	return s.path
+}
+
+func (s *Plugin) IP(ctx context.Context) (string, error) {
+	body, err := json.Marshal(pluginRequest{Version: 1, Method: "ip"})
+	if err != nil {
+		return "", withKind(KindConfig, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.path)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return "", withKind(KindConfig, err)
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			err = fmt.Errorf("%w: %s", err, message)
+		}
+		return "", withKind(KindInvalidResponse, fmt.Errorf("%s: %w", "ip source plugin failed", err))
+	}
+
+	var resp pluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return "", withKind(KindInvalidResponse, fmt.Errorf("%s: %w", "ip source plugin responded with invalid json", err))
+	}
+	if resp.Error != "" {
+		return "", withKind(KindInvalidResponse, fmt.Errorf("%s: %s", "ip source plugin failed", resp.Error))
+	}
+
+	return parseIP(resp.IP)
+}
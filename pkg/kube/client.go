@@ -0,0 +1,227 @@
+// Package kube is a minimal client of the kubernetes api, enough to list and watch the objects
+// records are published for without depending on client-go
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// paths of the service account credentials mounted into every pod
+const (
+	serviceAccountDir   = "/var/run/secrets/kubernetes.io/serviceaccount"
+	serviceAccountCA    = serviceAccountDir + "/ca.crt"
+	serviceAccountJWT   = serviceAccountDir + "/token"
+	watchTimeoutSeconds = "300"
+)
+
+// ErrExpired is matched by errors of watches whose resource version is too old to resume from, the
+// objects must be listed again
+var ErrExpired = errors.New("resource version expired")
+
+// StatusError is an error answered by the api server
+type StatusError struct {
+	Status  int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("kubernetes api responded %d: %s", e.Status, e.Message)
+}
+
+// Is matches ErrExpired for 410 Gone
+func (e *StatusError) Is(target error) bool {
+	return target == ErrExpired && e.Status == http.StatusGone
+}
+
+// Client calls the kubernetes api at baseURL, authenticated with a bearer token
+type Client struct {
+	baseURL string
+	token   string
+	// tokenFile is read before every request when set, since projected tokens are rotated
+	tokenFile string
+	client    *http.Client
+}
+
+// New returns a client calling the api at baseURL with token, no token is sent when empty e.g. for
+// kubectl proxy, and client or the default client when nil
+func New(baseURL, token string, client *http.Client) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), token: token, client: client}
+}
+
+// NewInCluster returns a client calling the api server of the cluster the process runs in, with the
+// credentials of its service account
+func NewInCluster() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a kubernetes cluster")
+	}
+
+	ca, err := os.ReadFile(serviceAccountCA)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", "unable to read service account ca", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("%s: %s", "no certificates in service account ca", serviceAccountCA)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+
+	c := New("https://"+net.JoinHostPort(host, port), "", &http.Client{Transport: transport})
+	c.tokenFile = serviceAccountJWT
+
+	return c, nil
+}
+
+// List is a page of objects as listed by the api
+type List struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []json.RawMessage `json:"items"`
+}
+
+// Event is a change to an object delivered by a watch, of type ADDED, MODIFIED, DELETED, BOOKMARK or
+// ERROR
+type Event struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// List returns the objects of the collection at path
+func (c *Client) List(ctx context.Context, path string) (*List, error) {
+	var list List
+	if err := c.Do(ctx, http.MethodGet, path, nil, &list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// Watch calls fn with every change to the collection at path after resourceVersion until the server
+// ends the watch, ctx is done or fn fails
+func (c *Client) Watch(ctx context.Context, path, resourceVersion string, fn func(Event) error) error {
+	query := url.Values{"watch": {"1"}, "resourceVersion": {resourceVersion}, "allowWatchBookmarks": {"true"},
+		"timeoutSeconds": {watchTimeoutSeconds}}
+	resp, err := c.send(ctx, http.MethodGet, path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event Event
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if event.Type == "ERROR" {
+			return statusError(event.Object)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+}
+
+// Do calls the api, encoding body as json when not nil and decoding the response into result when
+// not nil
+func (c *Client) Do(ctx context.Context, method, path string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	resp, err := c.send(ctx, method, path, reader)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if result == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// send sends a request and returns the response when successful
+func (c *Client) send(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		contentType := "application/json"
+		if method == http.MethodPatch {
+			contentType = "application/merge-patch+json"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	token := c.token
+	if c.tokenFile != "" {
+		data, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", "unable to read service account token", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		err := statusError(data)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.Status == 0 {
+			statusErr.Status = resp.StatusCode
+		}
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// statusError returns the error described by a kubernetes status object
+func statusError(data []byte) error {
+	var status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(data, &status); err != nil || status.Message == "" {
+		status.Message = strings.TrimSpace(string(data))
+	}
+
+	return &StatusError{Status: status.Code, Message: status.Message}
+}
@@ -0,0 +1,63 @@
+package kube
+
+// ObjectMeta is the metadata every object carries
+type ObjectMeta struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace,omitempty"`
+	UID               string            `json:"uid,omitempty"`
+	ResourceVersion   string            `json:"resourceVersion,omitempty"`
+	Generation        int64             `json:"generation,omitempty"`
+	Annotations       map[string]string `json:"annotations,omitempty"`
+	DeletionTimestamp *string           `json:"deletionTimestamp,omitempty"`
+}
+
+// LoadBalancerIngress is an address a load balancer is reachable on, an ip or a hostname
+type LoadBalancerIngress struct {
+	IP       string `json:"ip,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+}
+
+// LoadBalancerStatus lists the addresses of a load balancer
+type LoadBalancerStatus struct {
+	Ingress []LoadBalancerIngress `json:"ingress,omitempty"`
+}
+
+// Service is a core v1 service, with only what records are published from
+type Service struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Spec     struct {
+		Type string `json:"type"`
+	} `json:"spec"`
+	Status struct {
+		LoadBalancer LoadBalancerStatus `json:"loadBalancer"`
+	} `json:"status"`
+}
+
+// Ingress is a networking v1 ingress, with only what records are published from
+type Ingress struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Status   struct {
+		LoadBalancer LoadBalancerStatus `json:"loadBalancer"`
+	} `json:"status"`
+}
+
+// MicroTimeFormat is the format of the timestamps of leases
+const MicroTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// Lease is a coordination v1 lease, held by one of the candidates of a leader election
+type Lease struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Metadata   ObjectMeta `json:"metadata"`
+	Spec       LeaseSpec  `json:"spec"`
+}
+
+// LeaseSpec is who holds a lease and until when, the lease is free once its duration has passed
+// since it was renewed
+type LeaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int32  `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int32  `json:"leaseTransitions,omitempty"`
+}
@@ -0,0 +1,409 @@
+package updater
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rgravlin/route53ddns/pkg/dns"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// headers authenticating an acme-dns update
+const (
+	ACMEDNSUserHeader = "X-Api-User"
+	ACMEDNSKeyHeader  = "X-Api-Key"
+)
+
+// ACMEDNSTTL is the ttl of the challenge records, kept short since a validation follows the update
+const ACMEDNSTTL = 60
+
+// acme-dns error codes, as clients of acme-dns expect them
+const (
+	ACMEDNSForbidden       = "forbidden"
+	ACMEDNSBadSubdomain    = "bad_subdomain"
+	ACMEDNSBadTXT          = "bad_txt"
+	ACMEDNSBadAllowFrom    = "invalid_allowfrom_ip"
+	ACMEDNSMalformedJSON   = "malformed_json_payload"
+	ACMEDNSUnavailable     = "not_ready"
+	ACMEDNSUpdateFailed    = "update_failed"
+	ACMEDNSRegisterRefused = "registration_not_allowed"
+)
+
+// acmeDNSTXTRegex matches a dns-01 key authorization digest, a base64url encoded sha-256 hash
+var acmeDNSTXTRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{43}$`)
+
+// ACMEDNSConfig configures the acme-dns compatible api, letting services solve dns-01 challenges
+// through the daemon's credentials without credentials of their own
+type ACMEDNSConfig struct {
+	// Domain is the route53 hosted zone the challenge records are created in, the api is disabled when
+	// empty. names being validated are pointed at their challenge record with a CNAME
+	Domain string
+	// Store is where the accounts are kept, an s3://bucket/key url, a dynamodb://table/id url or a
+	// local file path
+	Store string
+	// AllowRegisterFrom lists the networks accounts may be registered from, any when empty
+	AllowRegisterFrom []string
+}
+
+// acmeDNSAccount is a registered account allowed to update the challenge record of its subdomain.
+// passwords are generated with 240 bits of entropy, so a salted hash keeps them safe without a slow
+// key derivation
+type acmeDNSAccount struct {
+	Username     string   `json:"username"`
+	PasswordHash string   `json:"password_hash"`
+	Salt         string   `json:"salt"`
+	Subdomain    string   `json:"subdomain"`
+	AllowFrom    []string `json:"allow_from,omitempty"`
+}
+
+// acmeDNSAccounts is the content of the account store
+type acmeDNSAccounts struct {
+	Accounts map[string]acmeDNSAccount `json:"accounts"`
+}
+
+// acmeDNSRegistration is the response to a registration, the only time the password is revealed
+type acmeDNSRegistration struct {
+	Username   string   `json:"username"`
+	Password   string   `json:"password"`
+	FullDomain string   `json:"fulldomain"`
+	Subdomain  string   `json:"subdomain"`
+	AllowFrom  []string `json:"allowfrom"`
+}
+
+// acmeDNSUpdate is the body of an update
+type acmeDNSUpdate struct {
+	Subdomain string `json:"subdomain"`
+	TXT       string `json:"txt"`
+}
+
+// acmeDNSServer registers accounts and updates their challenge records in route53
+type acmeDNSServer struct {
+	domain        string
+	location      string
+	allowRegister []*net.IPNet
+	// updater opens the account store
+	updater *Updater
+
+	mu       sync.Mutex
+	store    StateStore
+	provider dns.Provider
+}
+
+// newACMEDNSServer returns the api creating challenge records under the configured domain, the
+// account store is opened on first use
+func (u *Updater) newACMEDNSServer(cfg ACMEDNSConfig) (*acmeDNSServer, error) {
+	domain := strings.ToLower(strings.Trim(cfg.Domain, "."))
+	if domainRegex.FindStringSubmatch(domain) == nil {
+		return nil, fmt.Errorf("%s: %s", "not a valid domain", cfg.Domain)
+	}
+	if cfg.Store == "" {
+		return nil, errors.New("an account store is required")
+	}
+	networks, err := parseNetworks(cfg.AllowRegisterFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	return &acmeDNSServer{domain: domain, location: cfg.Store, allowRegister: networks, updater: u}, nil
+}
+
+// setProvider sets the provider of the domain, the api answers not ready until it is set
+func (s *acmeDNSServer) setProvider(provider dns.Provider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.provider = provider
+}
+
+// register serves the acme-dns api under /acme-dns/ on mux, clients are given the prefix as the
+// url of the acme-dns server
+func (s *acmeDNSServer) register(mux *http.ServeMux) {
+	mux.HandleFunc("/acme-dns/register", s.handleRegister)
+	mux.HandleFunc("/acme-dns/update", s.handleUpdate)
+	mux.HandleFunc("/acme-dns/health", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// handleRegister creates an account with a subdomain of its own, limited to updates from the
+// networks in the optional allowfrom list of the body
+func (s *acmeDNSServer) handleRegister(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeAPI(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+		return
+	}
+	if len(s.allowRegister) > 0 && !networksContain(s.allowRegister, requestIP(r)) {
+		s.updater.logger.WarnContext(r.Context(), "refused acme-dns registration", "client", r.RemoteAddr)
+		writeAPI(w, http.StatusForbidden, apiError{Error: ACMEDNSRegisterRefused})
+		return
+	}
+
+	var req struct {
+		AllowFrom []string `json:"allowfrom"`
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 64<<10))
+	if err != nil || (len(strings.TrimSpace(string(body))) > 0 && json.Unmarshal(body, &req) != nil) {
+		writeAPI(w, http.StatusBadRequest, apiError{Error: ACMEDNSMalformedJSON})
+		return
+	}
+	allowFrom := make([]string, 0, len(req.AllowFrom))
+	for _, network := range req.AllowFrom {
+		_, parsed, err := net.ParseCIDR(strings.TrimSpace(network))
+		if err != nil {
+			writeAPI(w, http.StatusBadRequest, apiError{Error: ACMEDNSBadAllowFrom})
+			return
+		}
+		allowFrom = append(allowFrom, parsed.String())
+	}
+
+	password, err := acmeDNSSecret()
+	if err != nil {
+		writeAPI(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+		return
+	}
+	salt, err := acmeDNSSecret()
+	if err != nil {
+		writeAPI(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+		return
+	}
+	account := acmeDNSAccount{Username: uuid.NewString(), Salt: salt, Subdomain: uuid.NewString(), AllowFrom: allowFrom}
+	account.PasswordHash = acmeDNSHash(salt, password)
+
+	if err := s.save(r.Context(), account); err != nil {
+		s.updater.logger.ErrorContext(r.Context(), "unable to save acme-dns account", "store", s.location, "error", err)
+		writeAPI(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+		return
+	}
+	s.updater.logger.InfoContext(r.Context(), "registered acme-dns account", "username", account.Username, "subdomain", account.Subdomain,
+		"client", r.RemoteAddr)
+
+	writeAPI(w, http.StatusCreated, acmeDNSRegistration{
+		Username:   account.Username,
+		Password:   password,
+		FullDomain: account.Subdomain + "." + s.domain,
+		Subdomain:  account.Subdomain,
+		AllowFrom:  allowFrom,
+	})
+}
+
+// handleUpdate sets the challenge record of the subdomain of the account, keeping the value set
+// before so a wildcard and its base domain can be validated together
+func (s *acmeDNSServer) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeAPI(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+		return
+	}
+
+	var req acmeDNSUpdate
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		writeAPI(w, http.StatusBadRequest, apiError{Error: ACMEDNSMalformedJSON})
+		return
+	}
+
+	account, err := s.authenticate(r.Context(), r.Header.Get(ACMEDNSUserHeader), r.Header.Get(ACMEDNSKeyHeader))
+	if err != nil {
+		s.updater.logger.ErrorContext(r.Context(), "unable to load acme-dns accounts", "store", s.location, "error", err)
+		writeAPI(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+		return
+	}
+	if account == nil || !s.allowed(account, requestIP(r)) {
+		s.updater.logger.WarnContext(r.Context(), "refused acme-dns update", "username", r.Header.Get(ACMEDNSUserHeader), "client", r.RemoteAddr)
+		writeAPI(w, http.StatusUnauthorized, apiError{Error: ACMEDNSForbidden})
+		return
+	}
+	if !strings.EqualFold(req.Subdomain, account.Subdomain) {
+		writeAPI(w, http.StatusUnauthorized, apiError{Error: ACMEDNSBadSubdomain})
+		return
+	}
+	if !acmeDNSTXTRegex.MatchString(req.TXT) {
+		writeAPI(w, http.StatusBadRequest, apiError{Error: ACMEDNSBadTXT})
+		return
+	}
+
+	if err := s.updateTXT(r.Context(), account.Subdomain+"."+s.domain, req.TXT); err != nil {
+		if errors.Is(err, errACMEDNSNotReady) {
+			writeAPI(w, http.StatusServiceUnavailable, apiError{Error: ACMEDNSUnavailable})
+			return
+		}
+		s.updater.logger.ErrorContext(r.Context(), "acme-dns update failed", "subdomain", account.Subdomain, "error_category", ErrorCause(err), "error", err)
+		writeAPI(w, http.StatusBadGateway, apiError{Error: ACMEDNSUpdateFailed})
+		return
+	}
+	s.updater.logger.InfoContext(r.Context(), "updated acme-dns challenge", "username", account.Username, "subdomain", account.Subdomain)
+
+	writeAPI(w, http.StatusOK, map[string]string{"txt": req.TXT})
+}
+
+// errACMEDNSNotReady is returned for updates arriving before the provider is set
+var errACMEDNSNotReady = errors.New("acme-dns provider is not ready")
+
+// updateTXT sets value as the newest of the two values of the TXT record named fqdn
+func (s *acmeDNSServer) updateTXT(ctx context.Context, fqdn, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.provider == nil {
+		return errACMEDNSNotReady
+	}
+	zoneID, err := findZoneID(ctx, s.provider, fqdn)
+	if err != nil {
+		return err
+	}
+	current, err := s.provider.GetRecord(ctx, zoneID, fqdn, "TXT")
+	if err != nil {
+		return withCause(dnsErrorCause(err), err)
+	}
+
+	quoted := `"` + value + `"`
+	desired := dns.Record{Name: fqdn, Type: "TXT", TTL: ACMEDNSTTL, Values: []string{quoted}, Routing: "simple"}
+	if current != nil && len(current.Values) > 0 && current.Values[0] != quoted {
+		desired.Values = append(desired.Values, current.Values[0])
+	}
+	if _, err := s.provider.UpsertRecord(ctx, zoneID, desired, "route53ddns acme-dns update"); err != nil {
+		return withCause(dnsErrorCause(err), err)
+	}
+
+	return nil
+}
+
+// authenticate returns the account of user when password is its password, nil otherwise
+func (s *acmeDNSServer) authenticate(ctx context.Context, user, password string) (*acmeDNSAccount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accounts, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	account, ok := accounts.Accounts[user]
+	if !ok {
+		// hash anyway so unknown users take as long as wrong passwords
+		acmeDNSHash("", password)
+		return nil, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(acmeDNSHash(account.Salt, password)), []byte(account.PasswordHash)) != 1 {
+		return nil, nil
+	}
+
+	return &account, nil
+}
+
+// allowed reports whether the account may be used from ip
+func (s *acmeDNSServer) allowed(account *acmeDNSAccount, ip net.IP) bool {
+	if len(account.AllowFrom) == 0 {
+		return true
+	}
+	networks, err := parseNetworks(account.AllowFrom)
+
+	return err == nil && networksContain(networks, ip)
+}
+
+// save adds account to the store
+func (s *acmeDNSServer) save(ctx context.Context, account acmeDNSAccount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accounts, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+	accounts.Accounts[account.Username] = account
+	data, err := json.MarshalIndent(accounts, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return s.store.Save(ctx, data)
+}
+
+// load reads the accounts from the store, opening it on first use. accounts are read on every
+// request so instances sharing a remote store see each other's registrations
+func (s *acmeDNSServer) load(ctx context.Context) (acmeDNSAccounts, error) {
+	if s.store == nil {
+		store, err := s.updater.openStateStore(s.location)
+		if err != nil {
+			return acmeDNSAccounts{}, err
+		}
+		s.store = store
+	}
+
+	accounts := acmeDNSAccounts{Accounts: map[string]acmeDNSAccount{}}
+	data, err := s.store.Load(ctx)
+	if err != nil || len(data) == 0 {
+		return accounts, err
+	}
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return accounts, fmt.Errorf("%s %s: %w", "unable to parse acme-dns accounts in", s.store, err)
+	}
+	if accounts.Accounts == nil {
+		accounts.Accounts = map[string]acmeDNSAccount{}
+	}
+
+	return accounts, nil
+}
+
+// acmeDNSSecret returns 40 random url safe characters
+func acmeDNSSecret() (string, error) {
+	b := make([]byte, 30)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("%s: %w", "unable to generate a secret", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// acmeDNSHash returns the hex encoded sha-256 hash of salt and password
+func acmeDNSHash(salt, password string) string {
+	sum := sha256.Sum256([]byte(salt + ":" + password))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// parseNetworks parses cidr blocks
+func parseNetworks(values []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, value := range values {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", "not a cidr block", value)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// networksContain reports whether ip is in any of networks
+func networksContain(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requestIP returns the address of the client of r, nil when it can't be parsed
+func requestIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return net.ParseIP(host)
+}
@@ -0,0 +1,172 @@
+package updater
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// apiRecord describes a managed record in api responses
+type apiRecord struct {
+	FQDN     string       `json:"fqdn"`
+	Key      string       `json:"key"`
+	Provider string       `json:"provider"`
+	Profile  string       `json:"profile,omitempty"`
+	Status   RecordStatus `json:"status"`
+}
+
+// apiRecordRequest is the body of PUT /v1/records/{fqdn}, provider may join several providers with +
+// to publish the record to each of them
+type apiRecordRequest struct {
+	Provider string `json:"provider,omitempty"`
+	Profile  string `json:"profile,omitempty"`
+}
+
+// apiError is the body of a failed api request
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// registerAPI serves the control api on mux
+func (u *Updater) registerAPI(mux *http.ServeMux) {
+	mux.Handle("/v1/status", u.apiHandler(http.MethodGet, func(w http.ResponseWriter, _ *http.Request) {
+		writeAPI(w, http.StatusOK, u.currentStatus())
+	}))
+	mux.Handle("/v1/update", u.apiHandler(http.MethodPost, u.handleAPIUpdate))
+	mux.Handle("/v1/records", u.apiHandler(http.MethodGet, func(w http.ResponseWriter, _ *http.Request) {
+		writeAPI(w, http.StatusOK, u.apiRecords(func(*dnsRecord) bool { return true }))
+	}))
+	mux.Handle("/v1/records/", u.apiHandler(http.MethodPut, u.handleAPIPutRecord))
+}
+
+// apiHandler serves next for requests of method carrying the api token as a bearer token
+func (u *Updater) apiHandler(method string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), []byte(u.cfg.APIToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="route53ddns"`)
+			writeAPI(w, http.StatusUnauthorized, apiError{Error: "missing or invalid token"})
+			return
+		}
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeAPI(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// writeAPI writes body as the json response with status
+func writeAPI(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// handleAPIUpdate runs a reconciliation right away, the request returns once it is queued
+func (u *Updater) handleAPIUpdate(w http.ResponseWriter, r *http.Request) {
+	if err := u.scheduler.Trigger(JobReconcile); err != nil {
+		writeAPI(w, http.StatusServiceUnavailable, apiError{Error: err.Error()})
+		return
+	}
+
+	u.logger.InfoContext(r.Context(), "reconciliation requested", "source", "api")
+	writeAPI(w, http.StatusAccepted, map[string]string{"job": JobReconcile})
+}
+
+// handleAPIPutRecord adds the record named by the path or replaces its providers, the change lasts
+// until the records are next reloaded from the configuration
+func (u *Updater) handleAPIPutRecord(w http.ResponseWriter, r *http.Request) {
+	name := strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/records/"), "."))
+
+	if strings.Contains(name, ",") {
+		writeAPI(w, http.StatusBadRequest, apiError{Error: "not a valid record: " + name})
+		return
+	}
+
+	var req apiRecordRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+			writeAPI(w, http.StatusBadRequest, apiError{Error: fmt.Sprintf("%s: %v", "not a valid request body", err)})
+			return
+		}
+	}
+
+	entry := name
+	if req.Provider != "" {
+		entry = req.Provider + ":" + entry
+	}
+	if req.Profile != "" {
+		entry += "@" + req.Profile
+	}
+	parsed, err := parseRecords(entry)
+	if err == nil && len(parsed) == 0 {
+		err = fmt.Errorf("%s: %q", "not a valid record", name)
+	}
+	if err == nil {
+		err = u.setupRecordClients(r.Context(), parsed, u.cfg.AWS, u.dnsClient)
+	}
+	if err != nil {
+		writeAPI(w, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+
+	created := u.replaceRecords(name, parsed)
+	u.logger.InfoContext(r.Context(), "record managed through the api", "record", name, "created", created)
+	if err := u.scheduler.Trigger(JobReconcile); err != nil {
+		u.logger.WarnContext(r.Context(), "unable to run reconciliation", "error", err)
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeAPI(w, status, u.apiRecords(func(record *dnsRecord) bool { return strings.EqualFold(record.fqdn, name) }))
+}
+
+// replaceRecords replaces the records named fqdn with replacements between cycles, reporting whether
+// none were managed before
+func (u *Updater) replaceRecords(fqdn string, replacements []*dnsRecord) bool {
+	u.cycleMu.Lock()
+	defer u.cycleMu.Unlock()
+
+	kept := make([]*dnsRecord, 0, len(u.records)+len(replacements))
+	for _, record := range u.records {
+		if !strings.EqualFold(record.fqdn, fqdn) {
+			kept = append(kept, record)
+		}
+	}
+	created := len(kept) == len(u.records)
+	u.records = append(kept, replacements...)
+
+	return created
+}
+
+// apiRecords describes the managed records matching keep
+func (u *Updater) apiRecords(keep func(record *dnsRecord) bool) []apiRecord {
+	// records are replaced between cycles, so the current set is copied before describing it
+	u.cycleMu.Lock()
+	managed := slices.Clone(u.records)
+	u.cycleMu.Unlock()
+
+	described := []apiRecord{}
+	for _, record := range managed {
+		if !keep(record) {
+			continue
+		}
+		described = append(described, apiRecord{
+			FQDN:     record.fqdn,
+			Key:      record.key,
+			Provider: record.provider.Name(),
+			Profile:  record.profile,
+			Status:   stateStatus(u.getState(), []string{record.key}).Records[0],
+		})
+	}
+
+	return described
+}
@@ -0,0 +1,73 @@
+package updater
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// setupAuditLog opens path for appending and directs the audit stream to it
+func (u *Updater) setupAuditLog(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	u.auditLogger = slog.New(slog.NewJSONHandler(f, nil))
+
+	return nil
+}
+
+// auditAWSRequest records every completed ChangeResourceRecordSets call with the aws request id and
+// change id, including calls that failed
+func (u *Updater) auditAWSRequest(ctx context.Context, call awsCall) {
+	if u.auditLogger == nil || call.operation != "ChangeResourceRecordSets" {
+		return
+	}
+
+	args := []any{"run_id", runIDFrom(ctx), "request_id", call.requestID, "status", call.status,
+		"retries", call.retries}
+
+	if input, ok := call.params.(*route53.ChangeResourceRecordSetsInput); ok {
+		args = append(args, "zone_id", aws.ToString(input.HostedZoneId))
+		if input.ChangeBatch != nil {
+			args = append(args, "comment", aws.ToString(input.ChangeBatch.Comment))
+			var changes []string
+			for _, change := range input.ChangeBatch.Changes {
+				if change.ResourceRecordSet == nil {
+					continue
+				}
+				set := change.ResourceRecordSet
+				changes = append(changes, strings.Join(append([]string{string(change.Action),
+					aws.ToString(set.Name), string(set.Type)}, recordValues(set)...), " "))
+			}
+			args = append(args, "changes", changes)
+		}
+	}
+
+	if output, ok := call.result.(*route53.ChangeResourceRecordSetsOutput); ok && call.err == nil && output.ChangeInfo != nil {
+		args = append(args, "change_id", aws.ToString(output.ChangeInfo.Id),
+			"change_status", string(output.ChangeInfo.Status))
+	}
+
+	if call.err != nil {
+		u.auditLogger.ErrorContext(ctx, "route53 change failed", append(args, "error", call.err)...)
+		return
+	}
+
+	u.auditLogger.InfoContext(ctx, "route53 change submitted", args...)
+}
+
+// recordValues returns the values of a record set
+func recordValues(set *route53types.ResourceRecordSet) []string {
+	var values []string
+	for _, record := range set.ResourceRecords {
+		values = append(values, aws.ToString(record.Value))
+	}
+
+	return values
+}
@@ -0,0 +1,80 @@
+package updater
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
+	"time"
+)
+
+// awsCall describes a completed AWS API call, including every retry
+type awsCall struct {
+	service   string
+	operation string
+	requestID string
+	status    int
+	retries   int
+	duration  time.Duration
+	params    any
+	result    any
+	err       error
+}
+
+// awsCallObserver returns the middleware logging, auditing and measuring every AWS API call
+func (u *Updater) awsCallObserver() middleware.InitializeMiddleware {
+	return middleware.InitializeMiddlewareFunc("route53ddnsObserver", u.observeAWSCall)
+}
+
+// observeAWSCall logs, audits and measures the call handled by next
+func (u *Updater) observeAWSCall(
+	ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler,
+) (middleware.InitializeOutput, middleware.Metadata, error) {
+	start := time.Now()
+	out, metadata, err := next.HandleInitialize(ctx, in)
+
+	call := awsCall{
+		service:   awsmiddleware.GetServiceID(ctx),
+		operation: awsmiddleware.GetOperationName(ctx),
+		duration:  time.Since(start),
+		params:    in.Parameters,
+		result:    out.Result,
+		err:       err,
+	}
+	call.requestID, _ = awsmiddleware.GetRequestIDMetadata(metadata)
+	if resp, ok := awsmiddleware.GetRawResponse(metadata).(*smithyhttp.Response); ok {
+		call.status = resp.StatusCode
+	}
+	if attempts, ok := retry.GetAttemptResults(metadata); ok && len(attempts.Results) > 0 {
+		call.retries = len(attempts.Results) - 1
+	}
+
+	// shipping logs to cloudwatch must not log its own calls
+	if call.service != "CloudWatch Logs" {
+		u.logAWSRequest(ctx, call)
+	}
+	u.auditAWSRequest(ctx, call)
+	if call.service == "Route 53" {
+		u.observeAWSRequest(call)
+	}
+
+	return out, metadata, err
+}
+
+// addAWSCallObserver adds the observer to an operation's middleware stack, after the service
+// metadata it reports is registered and before retries so it sees the call as a whole
+func (u *Updater) addAWSCallObserver(stack *middleware.Stack) error {
+	return stack.Initialize.Add(u.awsCallObserver(), middleware.After)
+}
+
+// instrumentAWSConfig adds the call observer to every client created from cfg, and a span per call
+// when x-ray tracing is enabled so aws calls show up in the service map
+func (u *Updater) instrumentAWSConfig(cfg *aws.Config) {
+	cfg.APIOptions = append(cfg.APIOptions, u.addAWSCallObserver)
+	if u.cfg.Tracing.XRay {
+		otelaws.AppendMiddlewares(&cfg.APIOptions)
+	}
+}
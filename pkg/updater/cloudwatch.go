@@ -0,0 +1,44 @@
+package updater
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cloudwatchtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"time"
+)
+
+// cloudWatchTimeout bounds a single metric publication
+const cloudWatchTimeout = 10 * time.Second
+
+// publishCloudWatch sends a count metric for fqdn in the background so a slow or failing
+// cloudwatch endpoint never delays an update cycle
+func (u *Updater) publishCloudWatch(name string, value float64) {
+	if u.cloudWatchClient == nil {
+		return
+	}
+
+	datum := cloudwatchtypes.MetricDatum{
+		MetricName: aws.String(name),
+		Dimensions: []cloudwatchtypes.Dimension{{
+			Name:  aws.String("FQDN"),
+			Value: aws.String(u.fqdn),
+		}},
+		Timestamp: aws.Time(time.Now()),
+		Unit:      cloudwatchtypes.StandardUnitCount,
+		Value:     aws.Float64(value),
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cloudWatchTimeout)
+		defer cancel()
+
+		_, err := u.cloudWatchClient.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(u.cfg.CloudWatchNamespace),
+			MetricData: []cloudwatchtypes.MetricDatum{datum},
+		})
+		if err != nil {
+			u.logger.Warn("unable to publish cloudwatch metric", "metric", name, "namespace", u.cfg.CloudWatchNamespace, "error", err)
+		}
+	}()
+}
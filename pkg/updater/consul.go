@@ -0,0 +1,113 @@
+package updater
+
+import (
+	"context"
+	"github.com/rgravlin/route53ddns/pkg/consul"
+	"net"
+	"strings"
+	"time"
+)
+
+// JobConsul syncs records with the consul catalog
+const JobConsul = "consul"
+
+// ConsulHostnameMeta is the service meta key naming the records of a service, in the syntax of the
+// records variable, instead of the service name within the consul domain
+const ConsulHostnameMeta = "route53ddns-hostname"
+
+// DefaultConsulInterval is how often the catalog is read
+const DefaultConsulInterval = time.Minute
+
+// ConsulConfig configures the records kept in sync with the services of a consul catalog
+type ConsulConfig struct {
+	// Tag selects the services published, the sync is disabled when empty
+	Tag string
+	// Address and Token reach the consul agent, the local agent without a token when empty
+	Address string
+	Token   string
+	// Domain is appended to the names of services without a hostname meta key
+	Domain string
+	// Interval is how often the catalog is read
+	Interval time.Duration
+}
+
+// consulSync publishes a record for every service carrying the tag, pointed at the addresses of its
+// healthy instances, and deletes it once the service has none
+type consulSync struct {
+	*targetPublisher
+	client *consul.Client
+	tag    string
+	domain string
+}
+
+// newConsulSync returns the sync of cfg
+func (u *Updater) newConsulSync(cfg ConsulConfig) *consulSync {
+	address := cfg.Address
+	if address == "" {
+		address = consul.DefaultAddress
+	}
+
+	return &consulSync{targetPublisher: u.newTargetPublisher("consul"), client: consul.New(address, cfg.Token, nil),
+		tag: cfg.Tag, domain: strings.Trim(cfg.Domain, ".")}
+}
+
+// refresh reads the services carrying the tag and publishes their records, the records are kept as
+// they are when the catalog can't be read
+func (c *consulSync) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), c.updater.cfg.CycleTimeout)
+	defer cancel()
+
+	services, err := c.client.Services(ctx)
+	if err != nil {
+		c.updater.logger.WarnContext(ctx, "unable to read consul catalog", "error", err)
+		return
+	}
+
+	targets := map[string]*publishTarget{}
+	for service, tags := range services {
+		if !containsString(tags, c.tag) {
+			continue
+		}
+		instances, err := c.client.HealthyInstances(ctx, service, c.tag)
+		if err != nil {
+			c.updater.logger.WarnContext(ctx, "unable to read consul service", "service", service, "error", err)
+			return
+		}
+		targets["consul/"+service] = c.target(service, instances)
+	}
+
+	c.replace("consul/", targets)
+	c.sync(ctx)
+}
+
+// target returns what the healthy instances of service ask to be published, nil when it has none
+func (c *consulSync) target(service string, instances []consul.ServiceEntry) *publishTarget {
+	if len(instances) == 0 {
+		return nil
+	}
+
+	value := instances[0].Service.Meta[ConsulHostnameMeta]
+	if value == "" {
+		if c.domain == "" {
+			c.updater.logger.Warn("consul service has no hostname and no domain is configured", "service", service, "meta", ConsulHostnameMeta)
+			return nil
+		}
+		value = strings.ToLower(service) + "." + c.domain
+	}
+
+	target, err := c.updater.newPublishTarget(value, "")
+	if err != nil {
+		c.updater.logger.Warn("ignoring consul service", "service", service, "error", err)
+		return nil
+	}
+	for _, instance := range instances {
+		if ip := net.ParseIP(instance.Address()); ip != nil && !containsString(target.ips, ip.String()) {
+			target.ips = append(target.ips, ip.String())
+		}
+	}
+	if len(target.ips) == 0 {
+		return nil
+	}
+
+	return target
+}
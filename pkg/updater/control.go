@@ -0,0 +1,29 @@
+package updater
+
+import (
+	"errors"
+)
+
+// errChangeDeferred is returned for a change detected but not published, the cycle it belongs to
+// neither fails nor succeeds
+var errChangeDeferred = errors.New("change deferred, updates are not being published")
+
+// Pause stops changes from being published, cycles keep detecting the address and reporting what
+// would change
+func (u *Updater) Pause() {
+	if !u.paused.Swap(true) {
+		u.logger.Info("updates paused, changes will be detected but not published")
+	}
+}
+
+// Resume publishes changes again after Pause
+func (u *Updater) Resume() {
+	if u.paused.Swap(false) {
+		u.logger.Info("updates resumed")
+	}
+}
+
+// Reconcile runs a reconciliation right away in the background
+func (u *Updater) Reconcile() error {
+	return u.scheduler.Trigger(JobReconcile)
+}
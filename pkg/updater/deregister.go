@@ -0,0 +1,70 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// deregisterRecords deletes every record, a failing record does not stop the others from being deleted
+func (u *Updater) deregisterRecords(ctx context.Context) error {
+	var errs []error
+	for _, record := range u.records {
+		if err := u.deleteRecord(ctx, record); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", "could not delete record", record.key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// deleteRecord deletes record from its provider when it still holds the address last published,
+// a record something else has since changed is left alone
+func (u *Updater) deleteRecord(ctx context.Context, record *dnsRecord) error {
+	fqdn, key, provider := record.fqdn, record.key, record.provider
+	published := u.getPublishedIP(key)
+	if published == "" {
+		return nil
+	}
+
+	zoneID, err := findZoneID(ctx, provider, fqdn)
+	if err != nil {
+		return err
+	}
+	current, err := provider.GetRecord(ctx, zoneID, fqdn, RecordType)
+	if err != nil {
+		return withCause(dnsErrorCause(err), err)
+	}
+
+	// the delete must match the record exactly, so it is only sent for the single value published
+	if current == nil {
+		return nil
+	}
+	if len(current.Values) != 1 || current.Values[0] != published {
+		u.logger.WarnContext(ctx, "record changed since it was published, not deleting it", "record", fqdn, "provider", provider.Name(), "zone_id", zoneID, "published_ip", published)
+		return nil
+	}
+	ownership, err := u.checkOwnership(ctx, provider, zoneID, fqdn)
+	if err != nil {
+		return err
+	}
+
+	if u.cfg.DryRun {
+		u.logger.InfoContext(ctx, "dry run, not deleting record", "record", fqdn, "provider", provider.Name(), "zone_id", zoneID, "ip", published)
+		return nil
+	}
+
+	changeID, err := provider.DeleteRecord(ctx, zoneID, *current, "route53ddns deregistration")
+	if err != nil {
+		u.appendJournal(JournalEntry{Event: JournalEventDeleted, FQDN: key, OldIP: published,
+			Result: JournalResultFailed, Error: err.Error()})
+		return withCause(dnsErrorCause(err), err)
+	}
+
+	u.appendJournal(JournalEntry{Event: JournalEventDeleted, FQDN: key, OldIP: published,
+		ChangeID: changeID, Result: JournalResultSubmitted})
+	u.setLastChange(key, "", changeID)
+	u.logger.InfoContext(ctx, "deleted record", "record", fqdn, "provider", provider.Name(), "zone_id", zoneID, "ip", published, "change_id", changeID)
+
+	return u.releaseOwnership(ctx, provider, zoneID, fqdn, ownership)
+}
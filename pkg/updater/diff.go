@@ -0,0 +1,71 @@
+package updater
+
+import (
+	"fmt"
+	"github.com/rgravlin/route53ddns/pkg/dns"
+	"strings"
+)
+
+// formatRecordDiff renders the planned change from before to after in the style of a terraform
+// plan, before is nil when the record does not exist yet
+func formatRecordDiff(zoneID string, before, after *dns.Record) string {
+	var b strings.Builder
+
+	action := "~"
+	if before == nil {
+		action = "+"
+		before = &dns.Record{}
+	}
+
+	fmt.Fprintf(&b, "%s %s. %s (zone %s)\n", action, after.Name, after.Type, zoneID)
+
+	writeDiffField(&b, "ttl", formatTTL(before), formatTTL(after))
+	writeDiffField(&b, "routing", before.Routing, after.Routing)
+
+	for _, value := range before.Values {
+		if !containsString(after.Values, value) {
+			fmt.Fprintf(&b, "  - %s\n", value)
+		}
+	}
+	for _, value := range after.Values {
+		if containsString(before.Values, value) {
+			fmt.Fprintf(&b, "    %s\n", value)
+		} else {
+			fmt.Fprintf(&b, "  + %s\n", value)
+		}
+	}
+
+	return b.String()
+}
+
+// writeDiffField writes a single attribute, marking it when it changes
+func writeDiffField(b *strings.Builder, name, before, after string) {
+	switch {
+	case before == after:
+		fmt.Fprintf(b, "    %-8s %s\n", name+":", after)
+	case before == "":
+		fmt.Fprintf(b, "  + %-8s %s\n", name+":", after)
+	default:
+		fmt.Fprintf(b, "  ~ %-8s %s -> %s\n", name+":", before, after)
+	}
+}
+
+// formatTTL returns the ttl of a record, or an empty string when it has none
+func formatTTL(record *dns.Record) string {
+	if record.Name == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%d", record.TTL)
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
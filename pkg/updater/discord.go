@@ -0,0 +1,79 @@
+package updater
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// discord embed colors
+const (
+	discordColorChanged = 0x2ecc71
+	discordColorFailed  = 0xe74c3c
+)
+
+// discordNotifier posts events as embeds to a discord webhook
+type discordNotifier struct {
+	client *http.Client
+	url    string
+}
+
+// discordEmbedField is a name/value pair shown in an embed
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// discordEmbed is the rich content of a discord message
+type discordEmbed struct {
+	Title     string              `json:"title"`
+	Color     int                 `json:"color"`
+	Fields    []discordEmbedField `json:"fields,omitempty"`
+	Timestamp string              `json:"timestamp"`
+}
+
+// discordMessage is the body of a discord webhook execution
+type discordMessage struct {
+	Username string         `json:"username"`
+	Embeds   []discordEmbed `json:"embeds"`
+}
+
+// newDiscordNotifier validates the webhook url
+func newDiscordNotifier(client *http.Client, webhookURL string) (*discordNotifier, error) {
+	if _, err := url.ParseRequestURI(webhookURL); err != nil {
+		return nil, err
+	}
+
+	return &discordNotifier{client: client, url: webhookURL}, nil
+}
+
+func (n *discordNotifier) name() string {
+	return "discord"
+}
+
+func (n *discordNotifier) notify(ctx context.Context, event notificationEvent) error {
+	embed := discordEmbed{Title: event.title(), Color: discordColorChanged, Timestamp: event.Time.Format(time.RFC3339)}
+	field := func(name, value string, inline bool) {
+		if value != "" {
+			embed.Fields = append(embed.Fields, discordEmbedField{Name: name, Value: value, Inline: inline})
+		}
+	}
+
+	field("Record", event.FQDN, false)
+	switch event.Type {
+	case EventIPChanged:
+		field("Old IP", event.OldIP, true)
+		field("New IP", event.NewIP, true)
+		field("Change", event.ChangeID, false)
+	case EventUpdateFailed:
+		embed.Color = discordColorFailed
+		field("Category", event.Category, true)
+		if event.Error != "" {
+			field("Error", "```"+event.Error+"```", false)
+		}
+	}
+
+	return postJSON(ctx, n.client, n.url, discordMessage{Username: "route53ddns", Embeds: []discordEmbed{embed}}, nil)
+}
@@ -0,0 +1,96 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"strings"
+	"time"
+)
+
+// dnssecTimeout bounds checking the signing status of every zone once
+const dnssecTimeout = 30 * time.Second
+
+// signing statuses route53 reports for a zone
+const (
+	DNSSECSigning    = "SIGNING"
+	DNSSECNotSigning = "NOT_SIGNING"
+)
+
+// zoneDNSSEC returns the signing status of zoneID and a description of any problem keeping signed
+// answers from validating, such as a failed signing or a key signing key needing action
+func zoneDNSSEC(ctx context.Context, client *route53.Client, zoneID string) (string, string, error) {
+	resp, err := client.GetDNSSEC(ctx, &route53.GetDNSSECInput{HostedZoneId: aws.String(zoneID)})
+	if err != nil {
+		return "", "", err
+	}
+
+	status := aws.ToString(resp.Status.ServeSignature)
+	var problems []string
+	if status != DNSSECSigning && status != DNSSECNotSigning {
+		problems = append(problems, fmt.Sprintf("zone %s: %s", strings.ToLower(status), aws.ToString(resp.Status.StatusMessage)))
+	}
+	for _, key := range resp.KeySigningKeys {
+		switch keyStatus := aws.ToString(key.Status); keyStatus {
+		case "ACTIVE", "INACTIVE", "DELETING":
+		default:
+			problems = append(problems, fmt.Sprintf("key signing key %s %s: %s", aws.ToString(key.Name), strings.ToLower(keyStatus), aws.ToString(key.StatusMessage)))
+		}
+	}
+
+	return status, strings.Join(problems, "; "), nil
+}
+
+// checkDNSSEC records the signing status of the zone of every record, warning about every problem
+// found since a broken chain of trust makes a correctly updated record unresolvable for validating
+// resolvers
+func (u *Updater) checkDNSSEC() {
+	if u.dnssecDenied.Load() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dnssecTimeout)
+	defer cancel()
+
+	for _, record := range u.records {
+		if record.client == nil {
+			continue
+		}
+		zoneID := u.getRecordState(record.key).ZoneID
+		if zoneID == "" {
+			var err error
+			if zoneID, err = findZoneID(ctx, record.provider, record.fqdn); err != nil {
+				u.logger.Warn("unable to find zone to check dnssec", "record", record.fqdn, "error_category", ErrorCause(err), "error", err)
+				continue
+			}
+		}
+
+		status, problem, err := zoneDNSSEC(ctx, record.client, zoneID)
+		if isAccessDenied(err) {
+			u.dnssecDenied.Store(true)
+			u.logger.Warn("missing route53:GetDNSSEC, dnssec status is not checked", "record", record.fqdn, "zone_id", zoneID)
+			return
+		}
+		if err != nil {
+			u.logger.Warn("unable to check dnssec", "record", record.fqdn, "zone_id", zoneID, "error_category", awsErrorCause(err), "error", err)
+			continue
+		}
+
+		previous := u.getRecordState(record.key)
+		if problem != "" {
+			u.logger.Warn("dnssec problem, validating resolvers may fail to resolve the record", "record", record.fqdn, "zone_id", zoneID,
+				"dnssec_status", status, "problem", problem)
+		} else if previous.DNSSECProblem != "" {
+			u.logger.Info("dnssec problem resolved", "record", record.fqdn, "zone_id", zoneID, "dnssec_status", status)
+		}
+		if previous.DNSSECStatus != status {
+			u.logger.Info("dnssec status", "record", record.fqdn, "zone_id", zoneID, "dnssec_status", status)
+		}
+
+		u.updateRecordState(record.key, func(r *recordState) {
+			r.DNSSECStatus = status
+			r.DNSSECProblem = problem
+		})
+	}
+}
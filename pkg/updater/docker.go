@@ -0,0 +1,125 @@
+package updater
+
+import (
+	"context"
+	"github.com/rgravlin/route53ddns/pkg/docker"
+	"net"
+	"time"
+)
+
+// labels of containers records are published for, the hostname label holds records in the syntax of
+// the records variable and the records point at the detected address unless the ip label is set
+const (
+	DockerHostnameLabel = "route53ddns.hostname"
+	DockerIPLabel       = "route53ddns.ip"
+	DockerTTLLabel      = "route53ddns.ttl"
+)
+
+// DockerConfig configures the watcher publishing records for labeled containers
+type DockerConfig struct {
+	// Watch enables the watcher
+	Watch bool
+	// Host is the docker daemon watched, the local socket when empty
+	Host string
+}
+
+// client returns the client of the configured daemon
+func (c DockerConfig) client() (*docker.Client, error) {
+	if c.Host == "" {
+		return docker.New(docker.DefaultHost)
+	}
+
+	return docker.New(c.Host)
+}
+
+// dockerWatcher publishes records for the running containers carrying a hostname label, deleting
+// them when the containers stop
+type dockerWatcher struct {
+	*targetPublisher
+	client *docker.Client
+}
+
+// newDockerWatcher returns a watcher of the containers of client
+func (u *Updater) newDockerWatcher(client *docker.Client) *dockerWatcher {
+	return &dockerWatcher{targetPublisher: u.newTargetPublisher("docker"), client: client}
+}
+
+// start watches the containers in the background until ctx is done, records following the detected
+// address are published again when it changes
+func (w *dockerWatcher) start(ctx context.Context) {
+	w.updater.lifecycle.subscribe(func(_ context.Context, event lifecycleEvent) {
+		if _, ok := event.(ipChangedEvent); ok {
+			go w.sync(ctx)
+		}
+	})
+
+	go w.watch(ctx)
+}
+
+// watch lists the labeled containers and follows container events, listing again whenever the
+// event stream ends
+func (w *dockerWatcher) watch(ctx context.Context) {
+	for ctx.Err() == nil {
+		// events are followed from before the listing so no container starting in between is missed
+		since := time.Now()
+		containers, err := w.client.Containers(ctx, DockerHostnameLabel)
+		if err != nil {
+			w.updater.logger.Error("unable to list docker containers", "error", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(watchRetryDelay):
+			}
+			continue
+		}
+
+		targets := map[string]*publishTarget{}
+		for _, container := range containers {
+			targets["docker/"+container.ID] = w.target(container.ID, container.Labels)
+		}
+		w.replace("docker/", targets)
+		w.sync(ctx)
+
+		err = w.client.ContainerEvents(ctx, since, func(event docker.Event) error {
+			key := "docker/" + event.Actor.ID
+			switch event.Action {
+			case "start", "unpause":
+				w.set(key, w.target(event.Actor.ID, event.Actor.Attributes))
+			case "die", "stop", "kill", "pause", "destroy":
+				w.set(key, nil)
+			default:
+				return nil
+			}
+			w.sync(ctx)
+			return nil
+		})
+		if err != nil && ctx.Err() == nil {
+			w.updater.logger.Warn("docker event stream failed, listing again", "error", err)
+		}
+	}
+}
+
+// target returns what a container asks to be published, nil when it has no hostname label or its
+// labels aren't valid
+func (w *dockerWatcher) target(id string, labels map[string]string) *publishTarget {
+	value := labels[DockerHostnameLabel]
+	if value == "" {
+		return nil
+	}
+
+	target, err := w.updater.newPublishTarget(value, labels[DockerTTLLabel])
+	if err == nil && labels[DockerIPLabel] != "" && net.ParseIP(labels[DockerIPLabel]) == nil {
+		err = &net.ParseError{Type: "IP address", Text: labels[DockerIPLabel]}
+	}
+	if err != nil {
+		w.updater.logger.Warn("ignoring docker container", "container", id, "error", err)
+		return nil
+	}
+
+	if ip := net.ParseIP(labels[DockerIPLabel]); ip != nil {
+		target.ips = []string{ip.String()}
+	} else {
+		target.detected = true
+	}
+
+	return target
+}
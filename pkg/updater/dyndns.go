@@ -0,0 +1,157 @@
+package updater
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// dyndns2 return codes
+const (
+	DynDNSGood    = "good"
+	DynDNSNoChg   = "nochg"
+	DynDNSBadAuth = "badauth"
+	DynDNSNotFQDN = "notfqdn"
+	DynDNSNoHost  = "nohost"
+	DynDNSNumHost = "numhost"
+	DynDNSDNSErr  = "dnserr"
+	DynDNS911     = "911"
+)
+
+// dyndnsMaxHostnames bounds the hostnames of a single update like dyndns2 services do
+const dyndnsMaxHostnames = 20
+
+// setupDynDNSRecords parses the records pushed by dyndns2 clients, which may not also be updated by
+// the scheduled cycles
+func (u *Updater) setupDynDNSRecords(ctx context.Context, entries []string) error {
+	if len(entries) == 0 {
+		u.dyndnsRecords = nil
+		return nil
+	}
+
+	parsed, err := newRecords(entries)
+	if err != nil {
+		return err
+	}
+	for _, record := range parsed {
+		if containsString(u.recordNames(), record.key) {
+			return fmt.Errorf("%s: %s", "record is updated by the daemon and by dyndns clients", record.key)
+		}
+	}
+	if err := u.setupRecordClients(ctx, parsed, u.cfg.AWS, u.dnsClient); err != nil {
+		return err
+	}
+	u.dyndnsRecords = parsed
+
+	return nil
+}
+
+// handleDynDNSUpdate serves /nic/update, pointing the records named by hostname at myip, or at the
+// address of the client when myip is missing or not valid as the protocol asks. every hostname gets a
+// line of its own with its return code
+func (u *Updater) handleDynDNSUpdate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	user, password, ok := r.BasicAuth()
+	if !ok || !u.dyndnsAuthorized(user, password) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="route53ddns"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = fmt.Fprintln(w, DynDNSBadAuth)
+		return
+	}
+
+	hostnames := strings.Split(r.URL.Query().Get("hostname"), ",")
+	if len(hostnames) > dyndnsMaxHostnames {
+		_, _ = fmt.Fprintln(w, DynDNSNumHost)
+		return
+	}
+
+	ip := dyndnsAddress(r)
+	for _, hostname := range hostnames {
+		_, _ = fmt.Fprintln(w, u.dyndnsUpdate(r.Context(), user, strings.ToLower(strings.TrimSpace(hostname)), ip))
+	}
+}
+
+// dyndnsAuthorized reports whether password is the password of user, in constant time
+func (u *Updater) dyndnsAuthorized(user, password string) bool {
+	expected, ok := u.cfg.DynDNSUsers[user]
+	match := subtle.ConstantTimeCompare([]byte(password), []byte(expected)) == 1
+
+	return ok && match
+}
+
+// dyndnsAddress returns the IPv4 address an update asks for, myip or else the address of the client,
+// or an empty string when neither is an IPv4 address
+func dyndnsAddress(r *http.Request) string {
+	if ip := net.ParseIP(strings.TrimSpace(r.URL.Query().Get("myip"))); ip != nil && ip.To4() != nil {
+		return ip.String()
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+		return ip.String()
+	}
+
+	return ""
+}
+
+// dyndnsUpdate points every record named hostname at ip and returns the dyndns2 return code
+func (u *Updater) dyndnsUpdate(ctx context.Context, user, hostname, ip string) string {
+	if domainRegex.FindStringSubmatch(hostname) == nil {
+		return DynDNSNotFQDN
+	}
+
+	var matched []*dnsRecord
+	unchanged := true
+	for _, record := range u.dyndnsRecords {
+		if strings.EqualFold(record.fqdn, hostname) {
+			matched = append(matched, record)
+			unchanged = unchanged && u.getPublishedIP(record.key) == ip
+		}
+	}
+	if len(matched) == 0 {
+		return DynDNSNoHost
+	}
+	if ip == "" {
+		return DynDNSDNSErr
+	}
+	if unchanged {
+		return DynDNSNoChg + " " + ip
+	}
+
+	// pushed updates are serialized with the scheduled cycles like any other job
+	u.cycleMu.Lock()
+	defer u.cycleMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), u.cfg.CycleTimeout)
+	defer cancel()
+	ctx = withRunID(ctx, uuid.NewString())
+
+	err := u.updateRecords(ctx, ip, matched)
+	if errors.Is(err, errNotLeader) {
+		// a standby made no change, clients retry later and reach the leader
+		u.logger.WarnContext(ctx, "dyndns update refused, not the leader", "user", user, "record", hostname, "ip", ip)
+		return DynDNS911
+	}
+	if errors.Is(err, errChangeDeferred) {
+		// nothing was published, clients retry later once updates resume
+		u.logger.InfoContext(ctx, "dyndns update deferred", "user", user, "record", hostname, "ip", ip)
+		return DynDNS911
+	}
+	if err != nil {
+		u.logger.ErrorContext(ctx, "dyndns update failed", "user", user, "record", hostname, "ip", ip,
+			"error_category", ErrorCause(err), "error", err)
+		return DynDNSDNSErr
+	}
+	u.logger.InfoContext(ctx, "dyndns update", "user", user, "record", hostname, "ip", ip)
+
+	return DynDNSGood + " " + ip
+}
@@ -0,0 +1,78 @@
+package updater
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestHandleDynDNSUpdateAuth(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		user       string
+		password   string
+		noAuth     bool
+		hostname   string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "no credentials", noAuth: true, hostname: "dyn.example.com", wantStatus: http.StatusUnauthorized, wantBody: DynDNSBadAuth},
+		{name: "unknown user", user: "intruder", password: "hunter2", hostname: "dyn.example.com", wantStatus: http.StatusUnauthorized, wantBody: DynDNSBadAuth},
+		{name: "wrong password", user: "router", password: "hunter3", hostname: "dyn.example.com", wantStatus: http.StatusUnauthorized, wantBody: DynDNSBadAuth},
+		{name: "empty password", user: "router", hostname: "dyn.example.com", wantStatus: http.StatusUnauthorized, wantBody: DynDNSBadAuth},
+		{name: "unmanaged hostname", user: "router", password: "hunter2", hostname: "other.example.com", wantStatus: http.StatusOK, wantBody: DynDNSNoHost},
+		{name: "scheduled record", user: "router", password: "hunter2", hostname: testRecord, wantStatus: http.StatusOK, wantBody: DynDNSNoHost},
+		{name: "not a hostname", user: "router", password: "hunter2", hostname: "localhost", wantStatus: http.StatusOK, wantBody: DynDNSNotFQDN},
+		{name: "update", user: "router", password: "hunter2", hostname: "Dyn.Example.com", wantStatus: http.StatusOK, wantBody: DynDNSGood + " 192.0.2.5"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			u, _ := newTestUpdater(t, "", WithConfig(Config{
+				Records:       []string{testRecord},
+				DynDNSRecords: []string{"dyn.example.com"},
+				DynDNSUsers:   map[string]string{"router": "hunter2"},
+				ListenAddress: "127.0.0.1:0",
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/nic/update?hostname="+tc.hostname+"&myip=192.0.2.5", nil)
+			if !tc.noAuth {
+				r.SetBasicAuth(tc.user, tc.password)
+			}
+			w := httptest.NewRecorder()
+			u.handleDynDNSUpdate(w, r)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tc.wantStatus)
+			}
+			if body := strings.TrimSpace(w.Body.String()); body != tc.wantBody {
+				t.Errorf("body = %q, want %q", body, tc.wantBody)
+			}
+			if tc.wantStatus == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("WWW-Authenticate header is missing")
+			}
+		})
+	}
+}
+
+func TestHandleDynDNSUpdateUnchanged(t *testing.T) {
+	u, _ := newTestUpdater(t, "", WithConfig(Config{
+		Records:       []string{testRecord},
+		DynDNSRecords: []string{"dyn.example.com"},
+		DynDNSUsers:   map[string]string{"router": "hunter2"},
+		ListenAddress: "127.0.0.1:0",
+	}))
+
+	var bodies []string
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest(http.MethodGet, "/nic/update?hostname=dyn.example.com&myip=192.0.2.5", nil)
+		r.SetBasicAuth("router", "hunter2")
+		w := httptest.NewRecorder()
+		u.handleDynDNSUpdate(w, r)
+		bodies = append(bodies, strings.TrimSpace(w.Body.String()))
+	}
+
+	if want := []string{DynDNSGood + " 192.0.2.5", DynDNSNoChg + " 192.0.2.5"}; !slices.Equal(bodies, want) {
+		t.Errorf("bodies = %q, want %q", bodies, want)
+	}
+}
@@ -0,0 +1,112 @@
+package updater
+
+import (
+	"errors"
+	"github.com/aws/smithy-go"
+	"github.com/rgravlin/route53ddns/pkg/dns"
+	"net/http"
+	"strings"
+)
+
+// error categories propagated through logs, metric labels and notifications so alerts can tell
+// a local outage apart from an aws or configuration problem
+const (
+	CauseDetectionNetwork         = "detection-network"
+	CauseDetectionInvalidResponse = "detection-invalid-response"
+	CauseAWSAuth                  = "aws-auth"
+	CauseAWSThrottle              = "aws-throttle"
+	CauseAWSNotFound              = "aws-notfound"
+	CauseAWSOther                 = "aws-other"
+	CauseConfig                   = "config"
+	CauseHook                     = "hook"
+	CauseOwnership                = "ownership"
+	CauseUnknown                  = "unknown"
+)
+
+// causeError tags an error with its category
+type causeError struct {
+	cause string
+	err   error
+}
+
+func (e *causeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *causeError) Unwrap() error {
+	return e.err
+}
+
+// withCause tags err with cause
+func withCause(cause string, err error) error {
+	return &causeError{cause: cause, err: err}
+}
+
+// ErrorCause returns the category err was tagged with
+func ErrorCause(err error) string {
+	var ce *causeError
+	if errors.As(err, &ce) {
+		return ce.cause
+	}
+
+	return CauseUnknown
+}
+
+// dnsErrorCause categorizes an error returned by a dns provider, the errors of other providers share
+// the categories of aws errors
+func dnsErrorCause(err error) string {
+	var throttledErr *dns.ThrottledError
+	if errors.As(err, &throttledErr) {
+		return CauseAWSThrottle
+	}
+
+	var updateErr *dns.UpdateError
+	if errors.As(err, &updateErr) {
+		if updateErr.Unauthorized() {
+			return CauseAWSAuth
+		}
+		return CauseAWSOther
+	}
+
+	var apiErr *dns.APIError
+	if !errors.As(err, &apiErr) {
+		return awsErrorCause(err)
+	}
+
+	switch {
+	case apiErr.Status == http.StatusUnauthorized || apiErr.Status == http.StatusForbidden:
+		return CauseAWSAuth
+	case apiErr.Status == http.StatusTooManyRequests:
+		return CauseAWSThrottle
+	case apiErr.Status == http.StatusNotFound:
+		return CauseAWSNotFound
+	}
+
+	return CauseAWSOther
+}
+
+// awsErrorCause categorizes an error returned by an aws api call
+func awsErrorCause(err error) string {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		// the sdk has no error type for missing or unusable credentials
+		if msg := err.Error(); strings.Contains(msg, "get identity") || strings.Contains(msg, "retrieve credentials") {
+			return CauseAWSAuth
+		}
+		return CauseAWSOther
+	}
+
+	switch apiErr.ErrorCode() {
+	case "AccessDenied", "AccessDeniedException", "InvalidClientTokenId", "ExpiredToken", "ExpiredTokenException",
+		"UnrecognizedClientException", "SignatureDoesNotMatch", "IncompleteSignature",
+		"InvalidSignatureException", "MissingAuthenticationToken":
+		return CauseAWSAuth
+	case "Throttling", "ThrottlingException", "ThrottledException", "RequestLimitExceeded",
+		"TooManyRequestsException", "PriorRequestNotComplete":
+		return CauseAWSThrottle
+	case "NoSuchHostedZone", "HostedZoneNotFound":
+		return CauseAWSNotFound
+	}
+
+	return CauseAWSOther
+}
@@ -0,0 +1,56 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventbridgetypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+)
+
+// eventBridgeSource is the source of every event put on the bus, detail types are prefixed with it
+const eventBridgeSource = "route53ddns"
+
+// eventBridgeNotifier puts events on an eventbridge bus as route53ddns.<event> detail types
+type eventBridgeNotifier struct {
+	client *eventbridge.Client
+	bus    string
+}
+
+// newEventBridgeNotifier targets bus, which may be a name or an arn
+func newEventBridgeNotifier(cfg aws.Config, bus string) *eventBridgeNotifier {
+	return &eventBridgeNotifier{client: eventbridge.NewFromConfig(cfg), bus: bus}
+}
+
+func (n *eventBridgeNotifier) name() string {
+	return "eventbridge"
+}
+
+func (n *eventBridgeNotifier) notify(ctx context.Context, event notificationEvent) error {
+	detail, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	out, err := n.client.PutEvents(ctx, &eventbridge.PutEventsInput{
+		Entries: []eventbridgetypes.PutEventsRequestEntry{{
+			EventBusName: aws.String(n.bus),
+			Source:       aws.String(eventBridgeSource),
+			DetailType:   aws.String(eventBridgeSource + "." + event.Type),
+			Detail:       aws.String(string(detail)),
+			Time:         aws.Time(event.Time),
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// put events reports per entry failures in the response rather than as an error
+	if out.FailedEntryCount > 0 && len(out.Entries) > 0 {
+		entry := out.Entries[0]
+		return fmt.Errorf("%s: %s: %s", "event was rejected", aws.ToString(entry.ErrorCode), aws.ToString(entry.ErrorMessage))
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package updater
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// lifecycleEvent is something that happened during a cycle, published on the event bus so the
+// journal, metrics, notifications and hooks react to it without the update logic calling each of them
+type lifecycleEvent interface {
+	// eventName identifies the event in logs
+	eventName() string
+}
+
+// ipDetectedEvent is published every time the ip source answers
+type ipDetectedEvent struct {
+	IP         string
+	PreviousIP string
+}
+
+// ipChangedEvent is published when the detected address differs from the one detected before
+type ipChangedEvent struct {
+	IP         string
+	PreviousIP string
+}
+
+// updateSucceededEvent is published when a change pointing a record at a new address was submitted
+type updateSucceededEvent struct {
+	Record   *dnsRecord
+	ZoneID   string
+	OldIP    string
+	NewIP    string
+	ChangeID string
+}
+
+// updateFailedEvent is published when the provider rejected a change, Err is the provider's error
+// and Cause its category
+type updateFailedEvent struct {
+	Record *dnsRecord
+	ZoneID string
+	OldIP  string
+	NewIP  string
+	Err    error
+	Cause  string
+}
+
+// driftDetectedEvent is published when a record no longer holds the address last published to it
+type driftDetectedEvent struct {
+	Record      *dnsRecord
+	ZoneID      string
+	RecordIP    string
+	PublishedIP string
+	NewIP       string
+}
+
+func (ipDetectedEvent) eventName() string      { return "ip-detected" }
+func (ipChangedEvent) eventName() string       { return "ip-changed" }
+func (updateSucceededEvent) eventName() string { return "update-succeeded" }
+func (updateFailedEvent) eventName() string    { return "update-failed" }
+func (driftDetectedEvent) eventName() string   { return "drift-detected" }
+
+// eventHandler reacts to a lifecycle event, handlers ignore the events they don't care about
+type eventHandler func(ctx context.Context, event lifecycleEvent)
+
+// eventBus delivers lifecycle events to its handlers synchronously, in the order they subscribed, so
+// a handler sees events in the order they happened
+type eventBus struct {
+	logger   *slog.Logger
+	mu       sync.RWMutex
+	handlers []eventHandler
+}
+
+// subscribe adds handler to the handlers receiving every event published from now on
+func (b *eventBus) subscribe(handler eventHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers = append(b.handlers, handler)
+}
+
+// publish delivers event to every handler, handlers must not block for long since cycles wait for them
+func (b *eventBus) publish(ctx context.Context, event lifecycleEvent) {
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+
+	b.logger.DebugContext(ctx, "lifecycle event", "event", event.eventName(), "handlers", len(handlers))
+	for _, handler := range handlers {
+		handler(ctx, event)
+	}
+}
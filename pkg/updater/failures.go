@@ -0,0 +1,107 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// actions taken once the consecutive failure threshold has been reached
+const (
+	FailureActionAlert = "alert"
+	FailureActionExit  = "exit"
+	FailureActionBoth  = "both"
+)
+
+// errFailureThreshold stops the running updater once the failure policy exits
+var errFailureThreshold = errors.New("consecutive failure threshold reached")
+
+// failureStreak counts the cycles failed in a row for the failure policy
+type failureStreak struct {
+	failureMu           sync.Mutex
+	consecutiveFailures int
+	// failureCategory is the error category of the last failure notification of the streak
+	failureCategory string
+}
+
+// validFailureAction reports whether action is a supported failure policy
+func validFailureAction(action string) bool {
+	switch action {
+	case FailureActionAlert, FailureActionExit, FailureActionBoth:
+		return true
+	}
+
+	return false
+}
+
+// trackResult updates the consecutive failure streak and applies the failure policy, the streak is
+// persisted first so it carries over when the policy stops the updater or the process restarts
+func (u *Updater) trackResult(ctx context.Context, err error) {
+	u.failureMu.Lock()
+	defer u.failureMu.Unlock()
+
+	if err == nil {
+		if u.cfg.FailureThreshold > 0 && u.consecutiveFailures >= u.cfg.FailureThreshold {
+			u.logger.InfoContext(ctx, "recovered from consecutive failures", "failures", u.consecutiveFailures)
+		}
+		if u.failureCategory != "" {
+			u.notify(ctx, notificationEvent{Type: EventRecovered, FQDN: u.fqdn, Failures: u.consecutiveFailures})
+		}
+		u.consecutiveFailures = 0
+		u.failureCategory = ""
+		u.persistFailureStreak()
+		return
+	}
+
+	u.consecutiveFailures++
+	u.persistFailureStreak()
+	u.notifyFailure(ctx, err)
+
+	// a threshold of zero disables the failure policy
+	if u.cfg.FailureThreshold <= 0 || u.consecutiveFailures < u.cfg.FailureThreshold {
+		return
+	}
+
+	// alert once when the streak crosses the threshold
+	if u.consecutiveFailures == u.cfg.FailureThreshold && u.cfg.FailureAction != FailureActionExit {
+		u.logCritical(ctx, "consecutive failure threshold reached", "failures", u.consecutiveFailures, "error_category", ErrorCause(err), "error", err)
+	}
+
+	if u.cfg.FailureAction == FailureActionExit || u.cfg.FailureAction == FailureActionBoth {
+		u.logger.ErrorContext(ctx, "stopping after consecutive failures", "failures", u.consecutiveFailures, "error_category", ErrorCause(err), "error", err)
+		if u.stopRun != nil {
+			u.stopRun(withCause(ErrorCause(err), fmt.Errorf("%w after %d failures: %w", errFailureThreshold, u.consecutiveFailures, err)))
+		}
+	}
+}
+
+// notifyFailure sends a failure notification on the first failure of a streak, or once the threshold
+// is reached when one is set, then every notifyFailureRepeat failures and whenever the error category
+// changes, so a long outage doesn't send one notification per cycle. the caller must hold failureMu
+func (u *Updater) notifyFailure(ctx context.Context, err error) {
+	first := max(u.cfg.FailureThreshold, 1)
+	if u.consecutiveFailures < first {
+		return
+	}
+
+	category := ErrorCause(err)
+	repeat := u.notifyFailureRepeat > 0 && (u.consecutiveFailures-first)%u.notifyFailureRepeat == 0
+	if u.consecutiveFailures != first && !repeat && category == u.failureCategory {
+		return
+	}
+
+	u.failureCategory = category
+	u.notify(ctx, notificationEvent{Type: EventUpdateFailed, FQDN: u.fqdn,
+		Failures: u.consecutiveFailures, Category: category, Error: strings.TrimSpace(err.Error())})
+}
+
+// persistFailureStreak copies the streak into the persisted state, the caller must hold failureMu
+func (u *Updater) persistFailureStreak() {
+	streak := u.consecutiveFailures
+	u.observeFailureStreak(streak)
+	u.updateState(func(s *runtimeState) {
+		s.ConsecutiveFailures = streak
+	})
+}
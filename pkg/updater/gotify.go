@@ -0,0 +1,55 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// gotifyNotifier sends events to a self-hosted gotify server
+type gotifyNotifier struct {
+	client   *http.Client
+	url      string
+	token    string
+	priority int
+}
+
+// gotifyMessage is the body of a create message request
+type gotifyMessage struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+}
+
+// GotifyConfig is the gotify server and application notifications are sent with
+type GotifyConfig struct {
+	// URL is the server url, gotify is disabled when empty
+	URL      string
+	Token    string
+	Priority int
+}
+
+// newGotifyNotifier validates the server url and requires an application token
+func newGotifyNotifier(client *http.Client, serverURL, token string, priority int) (*gotifyNotifier, error) {
+	if _, err := url.ParseRequestURI(serverURL); err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return nil, errors.New("an application token is required")
+	}
+
+	return &gotifyNotifier{client: client, url: strings.TrimSuffix(serverURL, "/") + "/message", token: token, priority: priority}, nil
+}
+
+func (n *gotifyNotifier) name() string {
+	return "gotify"
+}
+
+func (n *gotifyNotifier) notify(ctx context.Context, event notificationEvent) error {
+	header := http.Header{}
+	header.Set("X-Gotify-Key", n.token)
+
+	return postJSON(ctx, n.client, n.url, gotifyMessage{Title: event.title(), Message: event.message(), Priority: n.priority}, header)
+}
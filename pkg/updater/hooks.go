@@ -0,0 +1,78 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// hook results passed to the post update hook as RESULT
+const (
+	HookResultSuccess = "success"
+	HookResultFailure = "failure"
+)
+
+// HooksConfig configures the shell commands run around every change
+type HooksConfig struct {
+	// PreUpdate runs before a change is submitted, a failure skips the change. disabled when empty
+	PreUpdate string
+	// PostUpdate runs after a change was submitted or failed, disabled when empty
+	PostUpdate string
+	// Timeout bounds a single run of a hook
+	Timeout time.Duration
+}
+
+// runHook runs command through the shell with the record details in its environment, its output is
+// logged and it is killed once hookTimeout passes
+func (u *Updater) runHook(ctx context.Context, name, command, fqdn, oldIP, newIP, result, changeID string, updateErr error) error {
+	if command == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, u.cfg.Hooks.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"HOOK="+name,
+		"FQDN="+fqdn,
+		"OLD_IP="+oldIP,
+		"NEW_IP="+newIP,
+		"RESULT="+result,
+		"CHANGE_ID="+changeID,
+		"RUN_ID="+runIDFrom(ctx),
+	)
+	if updateErr != nil {
+		cmd.Env = append(cmd.Env, "ERROR="+strings.TrimSpace(updateErr.Error()), "ERROR_CATEGORY="+ErrorCause(updateErr))
+	}
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	start := time.Now()
+	err := cmd.Run()
+	args := []any{"hook", name, "duration", time.Since(start).Seconds(), "output", strings.TrimSpace(output.String())}
+	if err != nil {
+		u.logger.WarnContext(ctx, "update hook failed", append(args, "error", err)...)
+		return fmt.Errorf("%s hook: %w", name, err)
+	}
+
+	u.logger.InfoContext(ctx, "update hook completed", args...)
+
+	return nil
+}
+
+// hooksLifecycle runs the post update hook once a change was submitted or rejected
+func (u *Updater) hooksLifecycle(ctx context.Context, event lifecycleEvent) {
+	switch e := event.(type) {
+	case updateSucceededEvent:
+		_ = u.runHook(ctx, "post-update", u.cfg.Hooks.PostUpdate, e.Record.fqdn, e.OldIP, e.NewIP, HookResultSuccess, e.ChangeID, nil)
+	case updateFailedEvent:
+		_ = u.runHook(ctx, "post-update", u.cfg.Hooks.PostUpdate, e.Record.fqdn, e.OldIP, e.NewIP, HookResultFailure, "", withCause(e.Cause, e.Err))
+	}
+}
@@ -0,0 +1,105 @@
+package updater
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// startHTTPServer serves the daemon endpoints on address in the background until the returned
+// server is closed, profiling endpoints are only exposed when enablePprof is set
+func (u *Updater) startHTTPServer(address string, enablePprof bool) (*http.Server, error) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", u.handleHealthz)
+	mux.HandleFunc("/readyz", u.handleReadyz)
+	mux.HandleFunc("/status", u.handleStatus)
+	if len(u.cfg.DynDNSUsers) > 0 {
+		mux.HandleFunc("/nic/update", u.handleDynDNSUpdate)
+	}
+	if u.cfg.APIToken != "" {
+		u.registerAPI(mux)
+	}
+	if u.cfg.TriggerSecret != "" {
+		mux.HandleFunc("/hooks/trigger", u.handleTrigger)
+	}
+	if u.acmeDNS != nil {
+		u.acmeDNS.register(mux)
+	}
+
+	if enablePprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		u.logger.Warn("profiling endpoints enabled", "path", "/debug/pprof/")
+	}
+
+	// listen before returning so an address in use fails the start rather than a goroutine
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		u.logger.Info("serving http endpoints", "address", address)
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			u.logger.Error("http server failed", "address", address, "error", err)
+		}
+	}()
+
+	return server, nil
+}
+
+// handleHealthz reports whether the scheduler loop is alive and no cycle is stuck past its deadline
+func (u *Updater) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	if !u.scheduler.IsRunning() || u.cycleWedged(u.cfg.CycleTimeout) {
+		http.Error(w, "unhealthy", http.StatusServiceUnavailable)
+		return
+	}
+
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// readiness is the body returned by /readyz
+type readiness struct {
+	Ready       bool       `json:"ready"`
+	Reason      string     `json:"reason,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	MaxAge      string     `json:"max_age"`
+}
+
+// handleReadyz reports ready only while the last successful cycle is within the freshness window
+func (u *Updater) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	body := readiness{Ready: true, MaxAge: u.cfg.ReadyMaxAge.String()}
+	status := http.StatusOK
+
+	if last := u.lastSuccess.Load(); last == 0 {
+		body.Ready, body.Reason = false, "no successful update yet"
+	} else {
+		t := time.Unix(0, last).UTC()
+		body.LastSuccess = &t
+		if age := time.Since(t); age > u.cfg.ReadyMaxAge {
+			body.Ready, body.Reason = false, "last successful update was "+age.Round(time.Second).String()+" ago"
+		}
+	}
+
+	if !body.Ready {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,111 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// names of the update cycle jobs
+const (
+	JobReconcile = "reconcile"
+	JobCheck     = "check"
+)
+
+// jobStats serializes the jobs of an updater and counts their runs
+type jobStats struct {
+	// cycleMu serializes every job touching the record so different schedules never overlap
+	cycleMu     sync.Mutex
+	jobRuns     atomic.Int64
+	jobFailures atomic.Int64
+	// lastSuccess holds the completion time in unix nanoseconds of the last successful cycle
+	lastSuccess atomic.Int64
+	// cycleStarted holds the start time in unix nanoseconds of the cycle in flight, or zero when idle
+	cycleStarted atomic.Int64
+}
+
+// runJob wraps a scheduled job so the error it returns is logged and tracked instead of
+// being discarded by the scheduler, each run is bounded by the cycle timeout
+func (u *Updater) runJob(name string, job func(ctx context.Context) error) func() {
+	return func() {
+		u.cycleMu.Lock()
+		defer u.cycleMu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), u.cfg.CycleTimeout)
+		defer cancel()
+
+		// correlate everything the cycle logs, traces and submits
+		runID := uuid.NewString()
+		ctx = withRunID(ctx, runID)
+		ctx = withTimings(ctx)
+
+		ctx, span := startSpan(ctx, "cycle", attribute.String("job", name), attribute.String("run_id", runID))
+		start := time.Now()
+		u.cycleStarted.Store(start.UnixNano())
+		u.monitorsStarted(ctx)
+		err := job(ctx)
+		u.cycleStarted.Store(0)
+		u.jobRuns.Add(1)
+
+		// a deferred change didn't fail the cycle, but didn't publish the address either
+		if errors.Is(err, errChangeDeferred) {
+			endSpan(span, nil)
+			u.monitorsFinished(ctx, nil, time.Since(start))
+			u.observeCycle(name, err)
+			u.logger.Log(ctx, u.steadyStateLevel, "job skipped", "job", name, "duration", time.Since(start).Seconds(), timingsAttr(ctx), "reason", err)
+			return
+		}
+		endSpan(span, err)
+		u.monitorsFinished(ctx, err, time.Since(start))
+
+		if err == nil {
+			u.logger.Log(ctx, u.steadyStateLevel, "job completed", "job", name, "duration", time.Since(start).Seconds(), timingsAttr(ctx))
+		} else {
+			u.jobFailures.Add(1)
+			u.recordRecentError(name, err)
+			u.logger.ErrorContext(ctx, "job failed", "job", name, "duration", time.Since(start).Seconds(),
+				timingsAttr(ctx), "failed_runs", u.jobFailures.Load(), "runs", u.jobRuns.Load(), "error_category", ErrorCause(err), "error", err)
+		}
+
+		u.observeCycle(name, err)
+		u.trackResult(ctx, err)
+		if err == nil {
+			now := u.clock()
+			u.lastSuccess.Store(now.UnixNano())
+			u.setLastSuccess(now)
+			u.notifyReady()
+		}
+	}
+}
+
+// reconcileJob returns the reconciliation, whose first run only compares the detected address to
+// the published one when state was restored, so a restart doesn't read every record again. drift
+// is corrected by the run after
+func (u *Updater) reconcileJob() func(ctx context.Context) error {
+	if !u.stateRestored {
+		return u.getIPAndUpdate
+	}
+
+	var ran atomic.Bool
+	return func(ctx context.Context) error {
+		if ran.Swap(true) {
+			return u.getIPAndUpdate(ctx)
+		}
+		return u.checkIPAndUpdate(ctx)
+	}
+}
+
+// cycleWedged reports whether the cycle in flight has been running for longer than limit
+func (u *Updater) cycleWedged(limit time.Duration) bool {
+	started := u.cycleStarted.Load()
+	if started == 0 {
+		return false
+	}
+
+	return time.Since(time.Unix(0, started)) > limit
+}
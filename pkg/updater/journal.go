@@ -0,0 +1,77 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// journal event types
+const (
+	JournalEventDetected  = "ip-detected"
+	JournalEventSubmitted = "route53-submission"
+	JournalEventDeleted   = "route53-deletion"
+)
+
+// journal submission results
+const (
+	JournalResultSubmitted = "submitted"
+	JournalResultFailed    = "failed"
+)
+
+// JournalEntry is one line of the append-only change journal
+type JournalEntry struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"`
+	FQDN     string    `json:"fqdn"`
+	OldIP    string    `json:"old_ip,omitempty"`
+	NewIP    string    `json:"new_ip"`
+	ChangeID string    `json:"change_id,omitempty"`
+	Result   string    `json:"result,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// appendJournal appends entry to the journal, failures are logged rather than failing the cycle
+func (u *Updater) appendJournal(entry JournalEntry) {
+	if u.cfg.JournalFile == "" {
+		return
+	}
+
+	entry.Time = u.clock().UTC()
+	line, err := json.Marshal(entry)
+	if err != nil {
+		u.logger.Error("unable to encode journal entry", "error", err)
+		return
+	}
+
+	u.journalMu.Lock()
+	defer u.journalMu.Unlock()
+
+	f, err := os.OpenFile(u.cfg.JournalFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		u.logger.Error("unable to open journal", "path", u.cfg.JournalFile, "error", err)
+		return
+	}
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		u.logger.Error("unable to write journal", "path", u.cfg.JournalFile, "error", err)
+	}
+	if err := f.Close(); err != nil {
+		u.logger.Error("unable to close journal", "path", u.cfg.JournalFile, "error", err)
+	}
+}
+
+// journalLifecycle journals detected addresses and submitted changes
+func (u *Updater) journalLifecycle(_ context.Context, event lifecycleEvent) {
+	switch e := event.(type) {
+	case ipChangedEvent:
+		u.appendJournal(JournalEntry{Event: JournalEventDetected, FQDN: u.fqdn, OldIP: e.PreviousIP, NewIP: e.IP})
+	case updateSucceededEvent:
+		u.appendJournal(JournalEntry{Event: JournalEventSubmitted, FQDN: e.Record.key, OldIP: e.OldIP, NewIP: e.NewIP,
+			ChangeID: e.ChangeID, Result: JournalResultSubmitted})
+	case updateFailedEvent:
+		u.appendJournal(JournalEntry{Event: JournalEventSubmitted, FQDN: e.Record.key, OldIP: e.OldIP, NewIP: e.NewIP,
+			Result: JournalResultFailed, Error: e.Err.Error()})
+	}
+}
@@ -0,0 +1,177 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rgravlin/route53ddns/pkg/kube"
+	"net"
+	"strings"
+	"time"
+)
+
+// annotations of services and ingresses records are published for, the hostname annotation holds
+// records in the syntax of the records variable
+const (
+	KubernetesHostnameAnnotation = "route53ddns/hostname"
+	KubernetesTTLAnnotation      = "route53ddns/ttl"
+)
+
+// kinds of objects the kubernetes watcher publishes records for
+const (
+	KubernetesServices  = "services"
+	KubernetesIngresses = "ingresses"
+)
+
+// KubernetesConfig configures the watcher publishing records for annotated kubernetes objects
+type KubernetesConfig struct {
+	// Watch lists the kinds of objects watched, services and ingresses, the watcher is disabled when empty
+	Watch []string
+	// Operator reconciles DDNSRecord resources into their providers
+	Operator bool
+	// Namespace limits the objects watched to a namespace, every namespace is watched when empty
+	Namespace string
+	// APIURL is the api server to watch, e.g. kubectl proxy, with Token. the api server of the cluster
+	// the daemon runs in is watched with its service account when empty
+	APIURL string
+	Token  string
+}
+
+// client returns the client of the configured api server
+func (c KubernetesConfig) client() (*kube.Client, error) {
+	if c.APIURL != "" {
+		return kube.New(c.APIURL, c.Token, nil), nil
+	}
+
+	return kube.NewInCluster()
+}
+
+// kubernetesWatcher publishes records for the load balancer services and ingresses annotated with a
+// hostname at the addresses of their load balancers
+type kubernetesWatcher struct {
+	*targetPublisher
+	client    *kube.Client
+	namespace string
+	kinds     []string
+}
+
+// newKubernetesWatcher returns a watcher of kinds in namespace, or every namespace when empty
+func (u *Updater) newKubernetesWatcher(client *kube.Client, namespace string, kinds []string) (*kubernetesWatcher, error) {
+	for _, kind := range kinds {
+		if kind != KubernetesServices && kind != KubernetesIngresses {
+			return nil, fmt.Errorf("%s: %s", "not a kind of object that can be watched", kind)
+		}
+	}
+
+	return &kubernetesWatcher{targetPublisher: u.newTargetPublisher("kubernetes"), client: client, namespace: namespace, kinds: kinds}, nil
+}
+
+// start watches every kind in the background until ctx is done
+func (w *kubernetesWatcher) start(ctx context.Context) {
+	for _, kind := range w.kinds {
+		go w.watch(ctx, kind)
+	}
+}
+
+// path returns the api path of the collection of kind
+func (w *kubernetesWatcher) path(kind string) string {
+	prefix := "/api/v1"
+	if kind == KubernetesIngresses {
+		prefix = "/apis/networking.k8s.io/v1"
+	}
+	if w.namespace != "" {
+		prefix += "/namespaces/" + w.namespace
+	}
+
+	return prefix + "/" + kind
+}
+
+// watch lists the objects of kind and follows their changes, listing again whenever the watch ends
+func (w *kubernetesWatcher) watch(ctx context.Context, kind string) {
+	path := w.path(kind)
+	for ctx.Err() == nil {
+		list, err := w.client.List(ctx, path)
+		if err != nil {
+			w.updater.logger.Error("unable to list kubernetes objects", "kind", kind, "error", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(watchRetryDelay):
+			}
+			continue
+		}
+
+		targets := map[string]*publishTarget{}
+		for _, item := range list.Items {
+			key, target := w.target(kind, item)
+			targets[key] = target
+		}
+		w.replace(kind+"/", targets)
+		w.sync(ctx)
+
+		err = w.client.Watch(ctx, path, list.Metadata.ResourceVersion, func(event kube.Event) error {
+			key, target := w.target(kind, event.Object)
+			switch event.Type {
+			case "ADDED", "MODIFIED":
+				w.set(key, target)
+			case "DELETED":
+				w.set(key, nil)
+			default:
+				return nil
+			}
+			w.sync(ctx)
+			return nil
+		})
+		switch {
+		case errors.Is(err, kube.ErrExpired):
+			w.updater.logger.Debug("kubernetes watch expired, listing again", "kind", kind)
+		case err != nil && ctx.Err() == nil:
+			w.updater.logger.Warn("kubernetes watch failed, listing again", "kind", kind, "error", err)
+		}
+	}
+}
+
+// target returns the key of an object and what it asks to be published, nil when it isn't annotated,
+// has no load balancer address yet or can't be read
+func (w *kubernetesWatcher) target(kind string, object json.RawMessage) (string, *publishTarget) {
+	var meta kube.ObjectMeta
+	var status kube.LoadBalancerStatus
+	switch kind {
+	case KubernetesServices:
+		var service kube.Service
+		if err := json.Unmarshal(object, &service); err != nil {
+			return "", nil
+		}
+		meta, status = service.Metadata, service.Status.LoadBalancer
+		if service.Spec.Type != "LoadBalancer" {
+			status = kube.LoadBalancerStatus{}
+		}
+	default:
+		var ingress kube.Ingress
+		if err := json.Unmarshal(object, &ingress); err != nil {
+			return "", nil
+		}
+		meta, status = ingress.Metadata, ingress.Status.LoadBalancer
+	}
+
+	key := kind + "/" + meta.Namespace + "/" + meta.Name
+	value := meta.Annotations[KubernetesHostnameAnnotation]
+	if value == "" || len(status.Ingress) == 0 {
+		return key, nil
+	}
+
+	target, err := w.updater.newPublishTarget(value, meta.Annotations[KubernetesTTLAnnotation])
+	if err != nil {
+		w.updater.logger.Warn("ignoring kubernetes object", "kind", kind, "object", key, "error", err)
+		return key, nil
+	}
+	for _, ingress := range status.Ingress {
+		if ip := net.ParseIP(ingress.IP); ip != nil {
+			target.ips = append(target.ips, ip.String())
+		} else if ingress.Hostname != "" {
+			target.hostnames = append(target.hostnames, strings.TrimSuffix(ingress.Hostname, "."))
+		}
+	}
+
+	return key, target
+}
@@ -0,0 +1,117 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/google/uuid"
+	"github.com/rgravlin/route53ddns/pkg/ipsource"
+	"net"
+	"strings"
+	"time"
+)
+
+// lambdaEvent is the event a lambda invocation is driven by, an eventbridge schedule passes its input
+// as is so both fields are optional
+type lambdaEvent struct {
+	// IP is published instead of detecting the address with the ip source
+	IP string `json:"ip"`
+	// Records replace the configured records for this invocation, each a hostname optionally followed
+	// by @ and the profile whose credentials update it
+	Records []string `json:"records"`
+}
+
+// lambdaResponse reports what an invocation published
+type lambdaResponse struct {
+	IP      string   `json:"ip"`
+	Records []string `json:"records"`
+	RunID   string   `json:"run_id"`
+}
+
+// ServeLambda sets the updater up and serves lambda invocations until the runtime stops the
+// function, configuration is read once per cold start
+func (u *Updater) ServeLambda(ctx context.Context) error {
+	if err := u.setup(ctx); err != nil {
+		return err
+	}
+	defer u.shutdownTracing()
+
+	lambda.StartWithOptions(u.handleLambdaEvent, lambda.WithContext(ctx))
+
+	return nil
+}
+
+// handleLambdaEvent runs a single cycle publishing the address of the event, or the detected address
+// when it has none, to the records of the event or the configured records
+func (u *Updater) handleLambdaEvent(ctx context.Context, event lambdaEvent) (lambdaResponse, error) {
+	runID := uuid.NewString()
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		runID = lc.AwsRequestID
+	}
+	ctx = withTimings(withRunID(ctx, runID))
+	ctx, cancel := context.WithTimeout(ctx, u.cfg.CycleTimeout)
+	defer cancel()
+
+	// the function is frozen once it returns, so queued notifications and spans must be sent first
+	defer u.flushNotifications(notificationTimeout)
+	defer u.flushTracing()
+
+	start := time.Now()
+	response, err := u.publishLambdaEvent(ctx, event)
+	response.RunID = runID
+	u.observeCycle("lambda", err)
+	if err != nil {
+		u.recordRecentError("lambda", err)
+		u.logger.ErrorContext(ctx, "job failed", "job", "lambda", "duration", time.Since(start).Seconds(),
+			timingsAttr(ctx), "error_category", ErrorCause(err), "error", err)
+		return response, err
+	}
+	u.logger.Log(ctx, u.steadyStateLevel, "job completed", "job", "lambda", "duration", time.Since(start).Seconds(), timingsAttr(ctx))
+	now := u.clock()
+	u.lastSuccess.Store(now.UnixNano())
+	u.setLastSuccess(now)
+
+	return response, nil
+}
+
+// publishLambdaEvent resolves the address and records of event and updates the records
+func (u *Updater) publishLambdaEvent(ctx context.Context, event lambdaEvent) (lambdaResponse, error) {
+	targets := u.records
+	if len(event.Records) > 0 {
+		parsed, err := parseRecords(strings.Join(event.Records, ","))
+		if err != nil {
+			return lambdaResponse{}, withCause(CauseConfig, err)
+		}
+		if err := u.setupRecordClients(ctx, parsed, u.cfg.AWS, u.dnsClient); err != nil {
+			return lambdaResponse{}, withCause(CauseConfig, err)
+		}
+		targets = parsed
+	}
+
+	names := make([]string, 0, len(targets))
+	for _, record := range targets {
+		names = append(names, record.key)
+	}
+
+	var ip string
+	if event.IP != "" {
+		parsed := net.ParseIP(strings.TrimSpace(event.IP))
+		if parsed == nil || parsed.To4() == nil {
+			return lambdaResponse{Records: names}, withCause(CauseConfig, fmt.Errorf("%s %w: %q", "event ip is", ipsource.ErrInvalidIP, event.IP))
+		}
+		ip = parsed.String()
+	} else {
+		if u.cfg.IPSource == nil {
+			return lambdaResponse{Records: names}, withCause(CauseConfig, errors.New("event has no ip and no ip source is configured"))
+		}
+		detected, err := u.detectIP(ctx)
+		if err != nil {
+			return lambdaResponse{Records: names}, err
+		}
+		ip = detected
+	}
+
+	return lambdaResponse{IP: ip, Records: names}, u.updateRecords(ctx, ip, targets)
+}
@@ -0,0 +1,329 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rgravlin/route53ddns/pkg/kube"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLeaseDuration is how long a leader holds the lock without renewing it before a standby
+// takes over
+const DefaultLeaseDuration = 30 * time.Second
+
+// LeaderConfig configures the election of the replica updating the records, every replica updates
+// them when Lock is empty
+type LeaderConfig struct {
+	// Lock is a dynamodb://table/name url or a kubernetes://namespace/name url naming the lease the
+	// replicas compete for
+	Lock string
+	// Identity names the replica in the lock, its hostname when empty
+	Identity string
+	// LeaseDuration is how long the lock is held without renewal, renewed every third of it
+	LeaseDuration time.Duration
+}
+
+// leaderLock is a lock held by a single replica until it is released or its lease runs out
+type leaderLock interface {
+	// Acquire takes the lock for identity, or renews it when identity holds it, for ttl, reporting
+	// whether identity holds the lock
+	Acquire(ctx context.Context, identity string, ttl time.Duration) (bool, error)
+	// Release gives up the lock when identity holds it
+	Release(ctx context.Context, identity string) error
+	// String describes the lock in logs
+	String() string
+}
+
+// errNotLeader is returned for updates asked of a standby, which leaves the records to the leader
+var errNotLeader = errors.New("not the leader, records are updated by another replica")
+
+// isLeader reports whether this replica updates the records
+func (u *Updater) isLeader() bool {
+	return u.elector == nil || u.elector.leader.Load()
+}
+
+// openLeaderLock returns the lock named by location, dynamodb locks are called with the aws
+// configuration of the updater and kubernetes leases held in the api server of its kubernetes client
+func (u *Updater) openLeaderLock(location string) (leaderLock, error) {
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimPrefix(parsed.Path, "/")
+	if parsed.Host == "" || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("%s: %s", "not a dynamodb://table/name or kubernetes://namespace/name url", location)
+	}
+
+	switch parsed.Scheme {
+	case "dynamodb":
+		return &dynamoDBLeaderLock{client: dynamodb.NewFromConfig(u.cfg.AWS), table: parsed.Host, name: name, clock: u.clock}, nil
+	case "kubernetes":
+		client, err := u.cfg.Kubernetes.client()
+		if err != nil {
+			return nil, err
+		}
+		return &kubernetesLeaseLock{client: client, namespace: parsed.Host, name: name, clock: u.clock}, nil
+	}
+
+	return nil, fmt.Errorf("%s: %s", "not a dynamodb://table/name or kubernetes://namespace/name url", location)
+}
+
+// leaderElector keeps trying to hold the lock, so a standby takes over once the leader stops renewing
+// it
+type leaderElector struct {
+	// updater brings the records up to date once this replica is elected
+	updater  *Updater
+	lock     leaderLock
+	identity string
+	ttl      time.Duration
+	leader   atomic.Bool
+	// renewed is when the lock was last known to be held
+	renewed time.Time
+}
+
+// newLeaderElector returns an elector competing for lock as identity
+func (u *Updater) newLeaderElector(lock leaderLock, identity string, ttl time.Duration) *leaderElector {
+	if identity == "" {
+		identity = stateInstance()
+	}
+	if ttl <= 0 {
+		ttl = DefaultLeaseDuration
+	}
+
+	return &leaderElector{updater: u, lock: lock, identity: identity, ttl: ttl}
+}
+
+// campaign tries to take or renew the lock once and updates the leadership of the replica. a leader
+// unable to reach the lock steps down once its lease would have run out, since a standby may hold it
+func (e *leaderElector) campaign(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, e.ttl/3)
+	defer cancel()
+
+	held, err := e.lock.Acquire(ctx, e.identity, e.ttl)
+	switch {
+	case err != nil:
+		e.updater.logger.Warn("unable to reach the leader lock", "lock", e.lock.String(), "error", err)
+		if e.leader.Load() && e.updater.clock().Sub(e.renewed) < e.ttl {
+			return
+		}
+		held = false
+	case held:
+		e.renewed = e.updater.clock()
+	}
+
+	if e.leader.Swap(held) == held {
+		return
+	}
+	leaderGauge.Set(boolGauge(held))
+	if held {
+		e.updater.logger.Info("elected leader, updating records", "lock", e.lock.String(), "identity", e.identity)
+		// the records are brought up to date right away instead of at the next scheduled run
+		if e.updater.scheduler.IsRunning() {
+			if err := e.updater.scheduler.Trigger(JobReconcile); err != nil {
+				e.updater.logger.Warn("unable to trigger reconciliation", "error", err)
+			}
+		}
+		// published records are taken over from the previous leader
+		go e.updater.resyncPublishers(context.WithoutCancel(ctx))
+		return
+	}
+	e.updater.logger.Warn("lost leadership, standing by", "lock", e.lock.String(), "identity", e.identity)
+}
+
+// run renews or competes for the lock every third of the lease until ctx is done
+func (e *leaderElector) run(ctx context.Context) {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.campaign(ctx)
+		}
+	}
+}
+
+// release gives up the lock so a standby takes over without waiting for the lease to run out
+func (e *leaderElector) release() {
+	if !e.leader.Swap(false) {
+		return
+	}
+	leaderGauge.Set(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+	defer cancel()
+	if err := e.lock.Release(ctx, e.identity); err != nil {
+		e.updater.logger.Warn("unable to release the leader lock", "lock", e.lock.String(), "error", err)
+	}
+}
+
+// boolGauge returns 1 for true and 0 for false
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// dynamoDBLeaderLock is an item of a dynamodb table whose partition key is a string named id, taken
+// with a conditional write while it is free, held by the replica or expired
+type dynamoDBLeaderLock struct {
+	client *dynamodb.Client
+	table  string
+	name   string
+	// clock returns the time leases are taken and renewed at
+	clock func() time.Time
+}
+
+func (l *dynamoDBLeaderLock) Acquire(ctx context.Context, identity string, ttl time.Duration) (bool, error) {
+	now := l.clock()
+	_, err := l.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(l.table),
+		Item: map[string]dynamodbtypes.AttributeValue{
+			"id":      &dynamodbtypes.AttributeValueMemberS{Value: "leader#" + l.name},
+			"holder":  &dynamodbtypes.AttributeValueMemberS{Value: identity},
+			"expires": &dynamodbtypes.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(ttl).UnixMilli(), 10)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(id) OR holder = :holder OR expires < :now"),
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":holder": &dynamodbtypes.AttributeValueMemberS{Value: identity},
+			":now":    &dynamodbtypes.AttributeValueMemberN{Value: strconv.FormatInt(now.UnixMilli(), 10)},
+		},
+	})
+	var conditionErr *dynamodbtypes.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return false, nil
+	}
+	if err != nil {
+		return false, withCause(awsErrorCause(err), err)
+	}
+
+	return true, nil
+}
+
+func (l *dynamoDBLeaderLock) Release(ctx context.Context, identity string) error {
+	_, err := l.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(l.table),
+		Key:                 map[string]dynamodbtypes.AttributeValue{"id": &dynamodbtypes.AttributeValueMemberS{Value: "leader#" + l.name}},
+		ConditionExpression: aws.String("holder = :holder"),
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":holder": &dynamodbtypes.AttributeValueMemberS{Value: identity},
+		},
+	})
+	var conditionErr *dynamodbtypes.ConditionalCheckFailedException
+	if err != nil && !errors.As(err, &conditionErr) {
+		return err
+	}
+
+	return nil
+}
+
+func (l *dynamoDBLeaderLock) String() string {
+	return "dynamodb://" + l.table + "/" + l.name
+}
+
+// kubernetesLeaseLock is a coordination v1 lease, updated at the resource version it was read at so
+// two replicas never both take it
+type kubernetesLeaseLock struct {
+	client    *kube.Client
+	namespace string
+	name      string
+	// clock returns the time leases are taken and renewed at
+	clock func() time.Time
+}
+
+// path returns the api path of the lease, or of the collection of leases when name is empty
+func (l *kubernetesLeaseLock) path(name string) string {
+	path := "/apis/coordination.k8s.io/v1/namespaces/" + l.namespace + "/leases"
+	if name != "" {
+		path += "/" + name
+	}
+
+	return path
+}
+
+func (l *kubernetesLeaseLock) Acquire(ctx context.Context, identity string, ttl time.Duration) (bool, error) {
+	now := l.clock().UTC()
+	spec := kube.LeaseSpec{
+		HolderIdentity:       identity,
+		LeaseDurationSeconds: int32(ttl.Seconds()),
+		AcquireTime:          now.Format(kube.MicroTimeFormat),
+		RenewTime:            now.Format(kube.MicroTimeFormat),
+	}
+
+	var lease kube.Lease
+	err := l.client.Do(ctx, http.MethodGet, l.path(l.name), nil, &lease)
+	var statusErr *kube.StatusError
+	if errors.As(err, &statusErr) && statusErr.Status == http.StatusNotFound {
+		lease = kube.Lease{APIVersion: "coordination.k8s.io/v1", Kind: "Lease",
+			Metadata: kube.ObjectMeta{Name: l.name, Namespace: l.namespace}, Spec: spec}
+		return l.write(ctx, http.MethodPost, l.path(""), lease)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case lease.Spec.HolderIdentity == identity:
+		spec.AcquireTime, spec.LeaseTransitions = lease.Spec.AcquireTime, lease.Spec.LeaseTransitions
+	case lease.Spec.HolderIdentity == "" || leaseExpired(lease.Spec, now):
+		spec.LeaseTransitions = lease.Spec.LeaseTransitions + 1
+	default:
+		return false, nil
+	}
+	lease.Spec = spec
+
+	return l.write(ctx, http.MethodPut, l.path(l.name), lease)
+}
+
+// write creates or replaces the lease, reporting false when another replica changed it first
+func (l *kubernetesLeaseLock) write(ctx context.Context, method, path string, lease kube.Lease) (bool, error) {
+	err := l.client.Do(ctx, method, path, lease, nil)
+	var statusErr *kube.StatusError
+	if errors.As(err, &statusErr) && statusErr.Status == http.StatusConflict {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (l *kubernetesLeaseLock) Release(ctx context.Context, identity string) error {
+	var lease kube.Lease
+	if err := l.client.Do(ctx, http.MethodGet, l.path(l.name), nil, &lease); err != nil {
+		return err
+	}
+	if lease.Spec.HolderIdentity != identity {
+		return nil
+	}
+
+	lease.Spec.HolderIdentity = ""
+	_, err := l.write(ctx, http.MethodPut, l.path(l.name), lease)
+
+	return err
+}
+
+func (l *kubernetesLeaseLock) String() string {
+	return "kubernetes://" + l.namespace + "/" + l.name
+}
+
+// leaseExpired reports whether the duration of the lease has passed since it was renewed
+func leaseExpired(spec kube.LeaseSpec, now time.Time) bool {
+	renewed, err := time.Parse(kube.MicroTimeFormat, spec.RenewTime)
+	if err != nil {
+		return true
+	}
+
+	return now.After(renewed.Add(time.Duration(spec.LeaseDurationSeconds) * time.Second))
+}
@@ -0,0 +1,60 @@
+package updater
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LevelCritical is logged when the failure policy fires
+const LevelCritical = slog.LevelError + 4
+
+// runIDKey is the context key holding the correlation id of the cycle in flight
+type runIDKey struct{}
+
+// withRunID returns a context carrying the correlation id of a cycle
+func withRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, runID)
+}
+
+// runIDFrom returns the correlation id carried by ctx, or an empty string outside of a cycle
+func runIDFrom(ctx context.Context) string {
+	runID, _ := ctx.Value(runIDKey{}).(string)
+	return runID
+}
+
+// runIDHandler adds the correlation id of the cycle in flight to every record logged with its context
+type runIDHandler struct {
+	slog.Handler
+}
+
+func (h runIDHandler) Handle(ctx context.Context, r slog.Record) error {
+	if runID := runIDFrom(ctx); runID != "" {
+		r.AddAttrs(slog.String("run_id", runID))
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h runIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return runIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h runIDHandler) WithGroup(name string) slog.Handler {
+	return runIDHandler{h.Handler.WithGroup(name)}
+}
+
+// logAWSRequest logs every completed AWS API call at debug level
+func (u *Updater) logAWSRequest(ctx context.Context, call awsCall) {
+	if !u.logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+
+	u.logger.DebugContext(ctx, "aws request completed", "service", call.service,
+		"operation", call.operation, "request_id", call.requestID, "status", call.status,
+		"retries", call.retries, "duration", call.duration.Seconds(), "error", call.err)
+}
+
+// logCritical logs a message at the critical level
+func (u *Updater) logCritical(ctx context.Context, msg string, args ...any) {
+	u.logger.Log(ctx, LevelCritical, msg, args...)
+}
@@ -0,0 +1,64 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// matrixNotifier posts events as text messages to a matrix room
+type matrixNotifier struct {
+	client     *http.Client
+	homeserver string
+	token      string
+	roomID     string
+}
+
+// matrixMessage is an m.room.message event
+type matrixMessage struct {
+	MsgType string `json:"msgtype"`
+	Body    string `json:"body"`
+}
+
+// MatrixConfig is the homeserver, account and room notifications are posted to
+type MatrixConfig struct {
+	// Homeserver is the homeserver url, matrix is disabled when empty
+	Homeserver  string
+	AccessToken string
+	RoomID      string
+}
+
+// newMatrixNotifier validates the homeserver url and requires an access token and room id
+func newMatrixNotifier(client *http.Client, homeserver, token, roomID string) (*matrixNotifier, error) {
+	if _, err := url.ParseRequestURI(homeserver); err != nil {
+		return nil, err
+	}
+	if token == "" || roomID == "" {
+		return nil, errors.New("both an access token and a room id are required")
+	}
+
+	return &matrixNotifier{client: client, homeserver: strings.TrimSuffix(homeserver, "/"), token: token, roomID: roomID}, nil
+}
+
+func (n *matrixNotifier) name() string {
+	return "matrix"
+}
+
+func (n *matrixNotifier) notify(ctx context.Context, event notificationEvent) error {
+	body, err := json.Marshal(matrixMessage{MsgType: "m.text", Body: event.title() + "\n\n" + event.message()})
+	if err != nil {
+		return err
+	}
+
+	// a fresh transaction id per message, the homeserver deduplicates retries of the same id
+	target := n.homeserver + "/_matrix/client/v3/rooms/" + url.PathEscape(n.roomID) + "/send/m.room.message/" + uuid.NewString()
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+n.token)
+
+	return sendNotification(ctx, n.client, http.MethodPut, target, "application/json", body, header)
+}
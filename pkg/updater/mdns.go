@@ -0,0 +1,209 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	mdns "github.com/miekg/dns"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mdnsTTL is how long mdns answers are cached, the recommended ttl of host name records
+const mdnsTTL = 120
+
+// top bit of the class, in answers telling caches to replace what they hold and in questions asking
+// for a unicast answer
+const (
+	mdnsCacheFlush      = 1 << 15
+	mdnsUnicastResponse = 1 << 15
+)
+
+// mdnsGroup is the address mdns queries and announcements are multicast to
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// MDNSConfig configures the announcement of records on the local network over multicast dns
+type MDNSConfig struct {
+	// Enabled answers mdns queries and announces address changes
+	Enabled bool
+	// Names are announced, the names of the records when empty. most clients only resolve .local
+	// names over mdns
+	Names []string
+	// Interface names the interface announcements are sent on, the system default when empty
+	Interface string
+}
+
+// mdnsResponder answers mdns queries for its names with the detected address and announces the
+// address when it changes, so the names resolve on the local network while the public records
+// propagate or the internet is down
+type mdnsResponder struct {
+	// updater detects the address announced
+	updater *Updater
+	names   []string
+	conn    *net.UDPConn
+
+	mu sync.Mutex
+	ip string
+}
+
+// newMDNSResponder joins the mdns group on the interface named iface, or the default interface when
+// empty, to answer for names
+func (u *Updater) newMDNSResponder(names []string, iface string) (*mdnsResponder, error) {
+	var ifi *net.Interface
+	if iface != "" {
+		found, err := net.InterfaceByName(iface)
+		if err != nil {
+			return nil, err
+		}
+		ifi = found
+	}
+
+	conn, err := net.ListenMulticastUDP("udp4", ifi, mdnsGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &mdnsResponder{updater: u, conn: conn}
+	for _, name := range names {
+		r.names = append(r.names, mdns.Fqdn(strings.ToLower(name)))
+	}
+
+	return r, nil
+}
+
+// start answers queries in the background until ctx is done, then says goodbye so caches drop the
+// names, and announces every address change
+func (r *mdnsResponder) start(ctx context.Context) {
+	r.updater.lifecycle.subscribe(func(_ context.Context, event lifecycleEvent) {
+		if changed, ok := event.(ipChangedEvent); ok {
+			r.announce(changed.IP)
+		}
+	})
+	if ip := r.updater.getState().DetectedIP; ip != "" {
+		r.announce(ip)
+	}
+
+	go func() {
+		<-ctx.Done()
+		r.mu.Lock()
+		ip := r.ip
+		r.mu.Unlock()
+		if ip != "" {
+			_ = r.send(r.response(ip, 0), mdnsGroup)
+		}
+		_ = r.conn.Close()
+	}()
+	go r.serve()
+}
+
+// announce sends unsolicited answers for the new address, twice a second apart as mdns asks
+func (r *mdnsResponder) announce(ip string) {
+	r.mu.Lock()
+	r.ip = ip
+	r.mu.Unlock()
+
+	r.updater.logger.Info("announcing address over mdns", "names", r.names, "ip", ip)
+	go func() {
+		for i := 0; i < 2; i++ {
+			if err := r.send(r.response(ip, mdnsTTL), mdnsGroup); err != nil {
+				r.updater.logger.Warn("unable to announce address over mdns", "error", err)
+				return
+			}
+			time.Sleep(time.Second)
+		}
+	}()
+}
+
+// serve answers queries until the connection is closed
+func (r *mdnsResponder) serve() {
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				r.updater.logger.Error("mdns responder failed", "error", err)
+			}
+			return
+		}
+
+		var query mdns.Msg
+		if err := query.Unpack(buf[:n]); err != nil || query.Response || query.Opcode != mdns.OpcodeQuery {
+			continue
+		}
+		r.answer(&query, from)
+	}
+}
+
+// answer replies to the questions of query asking for one of the names, multicast unless the query
+// asks for a unicast answer or comes from a plain resolver
+func (r *mdnsResponder) answer(query *mdns.Msg, from *net.UDPAddr) {
+	r.mu.Lock()
+	ip := r.ip
+	r.mu.Unlock()
+	if ip == "" {
+		return
+	}
+
+	var asked []mdns.Question
+	unicast := from.Port != mdnsGroup.Port
+	for _, question := range query.Question {
+		if !containsString(r.names, strings.ToLower(question.Name)) {
+			continue
+		}
+		if question.Qtype != mdns.TypeA && question.Qtype != mdns.TypeANY {
+			continue
+		}
+		asked = append(asked, question)
+		unicast = unicast || question.Qclass&mdnsUnicastResponse != 0
+	}
+	if len(asked) == 0 {
+		return
+	}
+
+	reply := &mdns.Msg{MsgHdr: mdns.MsgHdr{Response: true, Authoritative: true}}
+	for _, question := range asked {
+		reply.Answer = append(reply.Answer, r.record(question.Name, ip, mdnsTTL))
+	}
+	to := mdnsGroup
+	if unicast {
+		to = from
+	}
+	// plain resolvers querying from another port expect their id and question back
+	if from.Port != mdnsGroup.Port {
+		reply.Id, reply.Question = query.Id, asked
+	}
+
+	if err := r.send(reply, to); err != nil {
+		r.updater.logger.Warn("unable to answer mdns query", "client", from.String(), "error", err)
+	}
+}
+
+// response returns an unsolicited answer for every name
+func (r *mdnsResponder) response(ip string, ttl uint32) *mdns.Msg {
+	msg := &mdns.Msg{MsgHdr: mdns.MsgHdr{Response: true, Authoritative: true}}
+	for _, name := range r.names {
+		msg.Answer = append(msg.Answer, r.record(name, ip, ttl))
+	}
+
+	return msg
+}
+
+// record returns the address record of name
+func (r *mdnsResponder) record(name, ip string, ttl uint32) mdns.RR {
+	return &mdns.A{
+		Hdr: mdns.RR_Header{Name: name, Rrtype: mdns.TypeA, Class: mdns.ClassINET | mdnsCacheFlush, Ttl: ttl},
+		A:   net.ParseIP(ip).To4(),
+	}
+}
+
+// send writes msg to addr
+func (r *mdnsResponder) send(msg *mdns.Msg, addr *net.UDPAddr) error {
+	packed, err := msg.Pack()
+	if err != nil {
+		return err
+	}
+	_, err = r.conn.WriteToUDP(packed, addr)
+
+	return err
+}
@@ -0,0 +1,198 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsNamespace = "route53ddns"
+
+var (
+	cyclesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "update_cycles_total",
+		Help:      "Update cycles run, by job.",
+	}, []string{"job"})
+	cycleSuccessesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "update_successes_total",
+		Help:      "Update cycles that completed without error, by job.",
+	}, []string{"job"})
+	cycleFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "update_failures_total",
+		Help:      "Update cycles that failed, by job and error category.",
+	}, []string{"job", "cause"})
+	cycleSkipsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "update_skips_total",
+		Help:      "Update cycles that deferred a change instead of publishing it, by job.",
+	}, []string{"job"})
+	changesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "changes_submitted_total",
+		Help:      "Route53 changes submitted.",
+	})
+	deduplicatedChangesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "changes_deduplicated_total",
+		Help:      "Changes not submitted since another instance sharing the state store claimed them.",
+	})
+	lastSuccessTimestamp = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time of the last update cycle that completed without error.",
+	})
+	consecutiveFailuresGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "consecutive_failures",
+		Help:      "Update cycles failed in a row.",
+	})
+	leaderGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "leader",
+		Help:      "1 while this replica holds the leader lock and updates the records, 0 while standing by.",
+	})
+	currentIPInfo = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "current_ip_info",
+		Help:      "Always 1, labelled with the most recently detected ip address.",
+	}, []string{"ip"})
+	route53Duration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "route53_request_duration_seconds",
+		Help:      "Route53 API call latency including retries, by operation and result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "result"})
+	phaseDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "phase_duration_seconds",
+		Help:      "Duration of each phase of an update cycle: ip_fetch, zone_lookup, record_list and change_submit.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"phase"})
+	ipSourceDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "ip_source_duration_seconds",
+		Help:      "IP address source request latency, by result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"result"})
+)
+
+// resultLabel returns the result label value for err
+func resultLabel(err error) string {
+	if err != nil {
+		return "failure"
+	}
+
+	return "success"
+}
+
+// observeCycle records the outcome of an update cycle
+func (u *Updater) observeCycle(job string, err error) {
+	cyclesTotal.WithLabelValues(job).Inc()
+	if u.statsd != nil {
+		u.statsd.count("update_cycles", 1, "job", job)
+	}
+
+	if errors.Is(err, errChangeDeferred) {
+		cycleSkipsTotal.WithLabelValues(job).Inc()
+		if u.statsd != nil {
+			u.statsd.count("update_skips", 1, "job", job)
+		}
+		return
+	}
+	if err != nil {
+		cycleFailuresTotal.WithLabelValues(job, ErrorCause(err)).Inc()
+		if u.statsd != nil {
+			u.statsd.count("update_failures", 1, "job", job, "cause", ErrorCause(err))
+		}
+		u.publishCloudWatch("UpdateFailure", 1)
+		return
+	}
+
+	cycleSuccessesTotal.WithLabelValues(job).Inc()
+	lastSuccessTimestamp.SetToCurrentTime()
+	if u.statsd != nil {
+		u.statsd.count("update_successes", 1, "job", job)
+		u.statsd.gauge("last_success_timestamp_seconds", float64(time.Now().Unix()))
+	}
+	u.publishCloudWatch("UpdateSuccess", 1)
+}
+
+// observeFailureStreak records the number of consecutive failed cycles
+func (u *Updater) observeFailureStreak(streak int) {
+	consecutiveFailuresGauge.Set(float64(streak))
+	if u.statsd != nil {
+		u.statsd.gauge("consecutive_failures", float64(streak))
+	}
+}
+
+// observeDetectedIP replaces the current ip label
+func (u *Updater) observeDetectedIP(ip string) {
+	currentIPInfo.Reset()
+	currentIPInfo.WithLabelValues(ip).Set(1)
+	if u.statsd != nil {
+		u.statsd.gauge("current_ip_info", 1, "ip", ip)
+	}
+}
+
+// observeIPSource records how long the ip source took to answer
+func (u *Updater) observeIPSource(d time.Duration, err error) {
+	ipSourceDuration.WithLabelValues(resultLabel(err)).Observe(d.Seconds())
+	if u.statsd != nil {
+		u.statsd.timing("ip_source_duration", d, "result", resultLabel(err))
+	}
+}
+
+// observePhase records how long a phase of a cycle took
+func (u *Updater) observePhase(phase string, d time.Duration) {
+	phaseDuration.WithLabelValues(phase).Observe(d.Seconds())
+	if u.statsd != nil {
+		u.statsd.timing("phase_duration", d, "phase", phase)
+	}
+}
+
+// observeChange records a submitted route53 change
+func (u *Updater) observeChange() {
+	changesTotal.Inc()
+	if u.statsd != nil {
+		u.statsd.count("changes_submitted", 1)
+	}
+}
+
+// observeIPChanged records a detected address differing from the one detected before
+func (u *Updater) observeIPChanged() {
+	u.publishCloudWatch("IPChanged", 1)
+}
+
+// observeDeduplicatedChange records a change left to another instance
+func (u *Updater) observeDeduplicatedChange() {
+	deduplicatedChangesTotal.Inc()
+	if u.statsd != nil {
+		u.statsd.count("changes_deduplicated", 1)
+	}
+}
+
+// observeAWSRequest records the latency of every completed Route53 call
+func (u *Updater) observeAWSRequest(call awsCall) {
+	route53Duration.WithLabelValues(call.operation, resultLabel(call.err)).Observe(call.duration.Seconds())
+	if u.statsd != nil {
+		u.statsd.timing("route53_request_duration", call.duration, "operation", call.operation, "result", resultLabel(call.err))
+	}
+}
+
+// metricsLifecycle records detected addresses and submitted changes
+func (u *Updater) metricsLifecycle(_ context.Context, event lifecycleEvent) {
+	switch e := event.(type) {
+	case ipDetectedEvent:
+		u.observeDetectedIP(e.IP)
+	case ipChangedEvent:
+		u.observeIPChanged()
+	case updateSucceededEvent:
+		u.observeChange()
+	}
+}
@@ -0,0 +1,205 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// monitorTimeout bounds a single ping to an external monitor
+const monitorTimeout = 10 * time.Second
+
+// cycleMonitor reports the outcome of every cycle to an external dead man's switch
+type cycleMonitor interface {
+	// started is called when a cycle begins
+	started(ctx context.Context)
+	// finished is called with the result of a cycle
+	finished(ctx context.Context, err error, duration time.Duration)
+}
+
+// MonitorsConfig configures the external dead man's switches pinged around every cycle
+type MonitorsConfig struct {
+	// HealthchecksURL is the ping url of a healthchecks.io check, disabled when empty
+	HealthchecksURL string
+	// UptimeKumaURL is the push url of an uptime kuma monitor, disabled when empty
+	UptimeKumaURL string
+}
+
+// newMonitors creates every monitor configured in cfg
+func (u *Updater) newMonitors(cfg MonitorsConfig) ([]cycleMonitor, error) {
+	var configured []cycleMonitor
+	if cfg.HealthchecksURL != "" {
+		monitor, err := u.newHealthchecksMonitor(cfg.HealthchecksURL)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", "healthchecks url is not valid", err)
+		}
+		configured = append(configured, monitor)
+	}
+	if cfg.UptimeKumaURL != "" {
+		monitor, err := u.newUptimeKumaMonitor(cfg.UptimeKumaURL)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", "uptime kuma url is not valid", err)
+		}
+		configured = append(configured, monitor)
+	}
+
+	return configured, nil
+}
+
+// monitorsStarted tells every monitor a cycle began
+func (u *Updater) monitorsStarted(ctx context.Context) {
+	for _, monitor := range u.monitors {
+		monitor.started(ctx)
+	}
+}
+
+// monitorsFinished tells every monitor how a cycle ended
+func (u *Updater) monitorsFinished(ctx context.Context, err error, duration time.Duration) {
+	for _, monitor := range u.monitors {
+		monitor.finished(ctx, err, duration)
+	}
+}
+
+// monitorPing is a queued request to an external monitor
+type monitorPing struct {
+	ctx    context.Context
+	method string
+	target string
+	body   string
+}
+
+// monitorQueue sends pings one at a time in the order they were queued, so a finish ping never
+// overtakes the start ping of the same cycle
+type monitorQueue struct {
+	pings  chan monitorPing
+	client *http.Client
+	logger *slog.Logger
+}
+
+// newMonitorQueue starts the goroutine draining a new queue sending pings with client
+func newMonitorQueue(client *http.Client, logger *slog.Logger) *monitorQueue {
+	q := &monitorQueue{pings: make(chan monitorPing, 16), client: client, logger: logger}
+	go func() {
+		for ping := range q.pings {
+			q.send(ping.ctx, ping.method, ping.target, ping.body)
+		}
+	}()
+
+	return q
+}
+
+// push queues a ping, dropping it when the monitor has fallen too far behind
+func (q *monitorQueue) push(ctx context.Context, method, target, body string) {
+	select {
+	case q.pings <- monitorPing{ctx: context.WithoutCancel(ctx), method: method, target: target, body: body}:
+	default:
+		q.logger.WarnContext(ctx, "monitor queue is full, dropping ping")
+	}
+}
+
+// healthchecksMonitor pings a healthchecks.io compatible check url
+type healthchecksMonitor struct {
+	url   string
+	queue *monitorQueue
+}
+
+// newHealthchecksMonitor creates a monitor for the check at pingURL
+func (u *Updater) newHealthchecksMonitor(pingURL string) (*healthchecksMonitor, error) {
+	if _, err := url.ParseRequestURI(pingURL); err != nil {
+		return nil, err
+	}
+
+	return &healthchecksMonitor{url: strings.TrimSuffix(pingURL, "/"), queue: newMonitorQueue(u.monitorClient, u.logger)}, nil
+}
+
+func (m *healthchecksMonitor) started(ctx context.Context) {
+	m.ping(ctx, "/start", "")
+}
+
+func (m *healthchecksMonitor) finished(ctx context.Context, err error, _ time.Duration) {
+	if err != nil {
+		m.ping(ctx, "/fail", "["+ErrorCause(err)+"] "+err.Error())
+		return
+	}
+
+	m.ping(ctx, "", "")
+}
+
+// ping posts body to the check url with suffix in the background, the cycle run id lets
+// healthchecks pair start and finish pings
+func (m *healthchecksMonitor) ping(ctx context.Context, suffix, body string) {
+	target := m.url + suffix
+	if runID := runIDFrom(ctx); runID != "" {
+		target += "?rid=" + url.QueryEscape(runID)
+	}
+
+	m.queue.push(ctx, http.MethodPost, target, body)
+}
+
+// send sends a request to target, logging rather than returning failures
+func (q *monitorQueue) send(parent context.Context, method, target, body string) {
+	ctx, cancel := context.WithTimeout(parent, monitorTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, target, strings.NewReader(body))
+	if err != nil {
+		q.logger.WarnContext(ctx, "unable to create monitor ping", "error", err)
+		return
+	}
+
+	resp, err := q.client.Do(req)
+	if err != nil {
+		q.logger.WarnContext(ctx, "unable to ping monitor", "host", req.URL.Host, "error", err)
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		q.logger.WarnContext(ctx, "monitor rejected ping", "host", req.URL.Host, "status", resp.StatusCode)
+		return
+	}
+
+	q.logger.DebugContext(ctx, "pinged monitor", "host", req.URL.Host, "status", resp.StatusCode)
+}
+
+// uptimeKumaMonitor reports to an uptime kuma push monitor
+type uptimeKumaMonitor struct {
+	url   *url.URL
+	queue *monitorQueue
+}
+
+// newUptimeKumaMonitor creates a monitor for the push url, any status, msg or ping parameters the
+// url was copied with are replaced on every push
+func (u *Updater) newUptimeKumaMonitor(pushURL string) (*uptimeKumaMonitor, error) {
+	parsed, err := url.ParseRequestURI(pushURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &uptimeKumaMonitor{url: parsed, queue: newMonitorQueue(u.monitorClient, u.logger)}, nil
+}
+
+func (m *uptimeKumaMonitor) started(context.Context) {}
+
+func (m *uptimeKumaMonitor) finished(ctx context.Context, err error, duration time.Duration) {
+	status, msg := "up", "OK"
+	if err != nil {
+		status, msg = "down", "["+ErrorCause(err)+"] "+err.Error()
+	}
+
+	u := *m.url
+	query := u.Query()
+	query.Set("status", status)
+	query.Set("msg", msg)
+	query.Set("ping", strconv.FormatInt(duration.Milliseconds(), 10))
+	u.RawQuery = query.Encode()
+
+	m.queue.push(ctx, http.MethodGet, u.String(), "")
+}
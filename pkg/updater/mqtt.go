@@ -0,0 +1,108 @@
+package updater
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"os"
+	"time"
+)
+
+// mqttNotifier publishes events to <topic>/event/<event> and keeps the published address retained on
+// <topic>/ip, so dashboards always show the current address
+type mqttNotifier struct {
+	client mqtt.Client
+	topic  string
+	qos    byte
+}
+
+// MQTTConfig is the broker events are published to
+type MQTTConfig struct {
+	// Broker is the broker url, mqtt is disabled when empty
+	Broker string
+	// Topic prefixes the topics published to, route53ddns when empty
+	Topic    string
+	QoS      int
+	Username string
+	Password string
+	// CAFile holds the certificates the broker's certificate is verified with, the system's when empty
+	CAFile string
+}
+
+// newMQTTNotifier configures a client for broker, connecting in the background so an unreachable
+// broker never blocks startup
+func (u *Updater) newMQTTNotifier(broker, topic string, qos int, username, password, caFile string) (*mqttNotifier, error) {
+	if qos < 0 || qos > 2 {
+		return nil, fmt.Errorf("%s: %d", "qos must be 0, 1 or 2", qos)
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID("route53ddns-" + u.fqdn).
+		SetUsername(username).
+		SetPassword(password).
+		SetConnectRetry(true).
+		SetAutoReconnect(true).
+		SetConnectTimeout(notificationTimeout)
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: %s", "no certificates found", caFile)
+		}
+		opts.SetTLSConfig(&tls.Config{RootCAs: pool})
+	}
+
+	client := mqtt.NewClient(opts)
+	client.Connect()
+
+	return &mqttNotifier{client: client, topic: topic, qos: byte(qos)}, nil
+}
+
+func (n *mqttNotifier) name() string {
+	return "mqtt"
+}
+
+func (n *mqttNotifier) notify(ctx context.Context, event notificationEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if err := n.publish(ctx, n.topic+"/event/"+event.Type, false, payload); err != nil {
+		return err
+	}
+
+	if event.Type == EventIPChanged {
+		return n.publish(ctx, n.topic+"/ip", true, []byte(event.NewIP))
+	}
+
+	return nil
+}
+
+// publish sends payload to topic and waits for the broker to accept it
+func (n *mqttNotifier) publish(ctx context.Context, topic string, retained bool, payload []byte) error {
+	timeout := notificationTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	token := n.client.Publish(topic, n.qos, retained, payload)
+	if !token.WaitTimeout(timeout) {
+		return fmt.Errorf("%s: %s", "timed out publishing", topic)
+	}
+
+	return token.Error()
+}
+
+// close disconnects from the broker once in-flight messages had a moment to complete
+func (n *mqttNotifier) close() {
+	n.client.Disconnect(250)
+}
@@ -0,0 +1,548 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// notification event types
+const (
+	EventIPChanged     = "ip-changed"
+	EventUpdateFailed  = "update-failed"
+	EventRecovered     = "recovered"
+	EventDriftDetected = "drift-detected"
+	EventDaemonStarted = "daemon-started"
+	EventDaemonStopped = "daemon-stopped"
+)
+
+// notificationTimeout bounds delivery of a single notification to a single target
+const notificationTimeout = 10 * time.Second
+
+// notificationEvent describes something worth telling a human about
+type notificationEvent struct {
+	Type   string    `json:"event"`
+	Time   time.Time `json:"timestamp"`
+	RunID  string    `json:"run_id,omitempty"`
+	FQDN   string    `json:"fqdn"`
+	ZoneID string    `json:"zone_id,omitempty"`
+	// Provider names the provider hosting the zone
+	Provider string `json:"provider,omitempty"`
+	OldIP    string `json:"old_ip,omitempty"`
+	NewIP    string `json:"new_ip,omitempty"`
+	ChangeID string `json:"change_id,omitempty"`
+	// ExpectedIP is what the record was last published as when it drifted
+	ExpectedIP string `json:"expected_ip,omitempty"`
+	Failures   int    `json:"consecutive_failures,omitempty"`
+	Category   string `json:"error_category,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// Suppressed counts similar events dropped by the rate limit since this one was last sent
+	Suppressed int `json:"suppressed,omitempty"`
+
+	// customTitle and customBody are rendered from user templates and replace the defaults
+	customTitle string
+	customBody  string
+}
+
+// title is a one line summary of the event
+func (e notificationEvent) title() string {
+	if e.customTitle != "" {
+		return e.customTitle
+	}
+
+	switch e.Type {
+	case EventIPChanged:
+		return fmt.Sprintf("%s now points to %s", e.FQDN, e.NewIP)
+	case EventUpdateFailed:
+		return fmt.Sprintf("%s failed to update %d times in a row", e.FQDN, e.Failures)
+	case EventRecovered:
+		return fmt.Sprintf("%s is updating again", e.FQDN)
+	case EventDriftDetected:
+		return fmt.Sprintf("%s was changed outside route53ddns", e.FQDN)
+	case EventDaemonStarted:
+		return fmt.Sprintf("route53ddns started for %s", e.FQDN)
+	case EventDaemonStopped:
+		return fmt.Sprintf("route53ddns stopped for %s", e.FQDN)
+	}
+
+	return fmt.Sprintf("%s: %s", e.FQDN, e.Type)
+}
+
+// message describes the event in a few lines of plain text
+func (e notificationEvent) message() string {
+	if e.customBody != "" {
+		return e.customBody
+	}
+
+	var b bytes.Buffer
+	switch e.Type {
+	case EventIPChanged:
+		old := e.OldIP
+		if old == "" {
+			old = "(none)"
+		}
+		fmt.Fprintf(&b, "%s changed from %s to %s", e.FQDN, old, e.NewIP)
+		if e.Provider != "" {
+			fmt.Fprintf(&b, "\nprovider: %s", e.Provider)
+		}
+		if e.ChangeID != "" {
+			fmt.Fprintf(&b, "\nchange: %s", e.ChangeID)
+		}
+	case EventUpdateFailed:
+		fmt.Fprintf(&b, "%s failed to update %d times in a row", e.FQDN, e.Failures)
+		if e.Category != "" {
+			fmt.Fprintf(&b, "\ncategory: %s", e.Category)
+		}
+		if e.Error != "" {
+			fmt.Fprintf(&b, "\nerror: %s", e.Error)
+		}
+	case EventRecovered:
+		fmt.Fprintf(&b, "%s is updating again after %d consecutive failures", e.FQDN, e.Failures)
+	case EventDriftDetected:
+		current := e.OldIP
+		if current == "" {
+			current = "(missing)"
+		}
+		fmt.Fprintf(&b, "%s was published as %s but is now %s", e.FQDN, e.ExpectedIP, current)
+		if e.Provider != "" {
+			fmt.Fprintf(&b, " in %s", e.Provider)
+		}
+	default:
+		b.WriteString(e.title())
+	}
+	if e.Suppressed > 0 {
+		fmt.Fprintf(&b, "\n%d similar notifications were suppressed", e.Suppressed)
+	}
+
+	return b.String()
+}
+
+// notifier delivers events to a single notification target
+type notifier interface {
+	// name identifies the target in logs
+	name() string
+	// notify delivers event, returning an error when the target rejected it
+	notify(ctx context.Context, event notificationEvent) error
+}
+
+// notificationHub holds the notifiers of an updater with what decides which events they receive
+type notificationHub struct {
+	// notifiers receive every event their filter allows
+	notifiers []notifier
+	// notifiersMu guards the notifiers with their filters and templates, which are replaced together
+	// when the configuration is reloaded
+	notifiersMu sync.RWMutex
+	// notificationFilters holds the events each filtered notifier receives, keyed by notifier name,
+	// notifiers without a filter receive every event
+	notificationFilters map[string]map[string]bool
+	// notificationTemplates holds the templates of every notifier that has any, keyed by notifier name
+	notificationTemplates map[string]messageTemplates
+	// notifyFailureRepeat repeats the failure notification every n failures of a streak, zero
+	// disables repeats
+	notifyFailureRepeat int
+	// notifyMinInterval is the least time between two notifications of the same kind, zero disables
+	// the rate limit
+	notifyMinInterval  time.Duration
+	notificationLimits map[string]*notificationLimit
+	notificationMu     sync.Mutex
+	notificationQueue  chan notificationRequest
+	notificationsOnce  sync.Once
+	notificationsWG    sync.WaitGroup
+}
+
+// NotificationsConfig configures the targets humans are told about changes and failures through, a
+// target is disabled while its settings are empty
+type NotificationsConfig struct {
+	// WebhookURLs receive every event as json, or as rendered by WebhookPayload when set
+	WebhookURLs    []string
+	WebhookPayload string
+	// DiscordWebhookURL receives events as embeds
+	DiscordWebhookURL string
+	Telegram          TelegramConfig
+	SMTP              SMTPConfig
+	SES               SESConfig
+	// SNSTopicARN receives every event as json
+	SNSTopicARN string
+	// EventBridgeBus is the name or arn of the bus events are put on
+	EventBridgeBus string
+	// PagerDutyRoutingKey opens incidents on failures and resolves them on recovery
+	PagerDutyRoutingKey string
+	NTFY                NTFYConfig
+	Pushover            PushoverConfig
+	MQTT                MQTTConfig
+	Gotify              GotifyConfig
+	Matrix              MatrixConfig
+	// TitleTemplate and BodyTemplate render the title and body of every notification, text/template
+	// executed with the event, the defaults are kept when empty
+	TitleTemplate string
+	BodyTemplate  string
+	// Templates replace the shared templates for a single notifier, keyed by notifier name, e.g. discord
+	Templates map[string]NotificationTemplates
+	// Events limits the events a notifier receives, keyed by notifier name, e.g.
+	// pagerduty: update-failed, recovered. notifiers without a list receive every event
+	Events map[string][]string
+	// FailureRepeat repeats the failure notification every n failures of a streak, zero disables repeats
+	FailureRepeat int
+	// MinInterval is the least time between two notifications of the same kind, zero disables the
+	// rate limit
+	MinInterval time.Duration
+}
+
+// NotificationTemplates render the title and body of the notifications of a single notifier
+type NotificationTemplates struct {
+	Title string
+	Body  string
+}
+
+// closingNotifier is a notifier holding a connection that must be released once it is replaced
+type closingNotifier interface {
+	close()
+}
+
+// newNotifiers creates every notifier configured in cfg, aws targets are called with awsCfg
+func (u *Updater) newNotifiers(cfg NotificationsConfig, awsCfg aws.Config) ([]notifier, error) {
+	var configured []notifier
+	for _, target := range cfg.WebhookURLs {
+		n, err := newWebhookNotifier(u.notificationClient, target, cfg.WebhookPayload)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", "unable to configure webhook", err)
+		}
+		configured = append(configured, n)
+	}
+	if cfg.DiscordWebhookURL != "" {
+		n, err := newDiscordNotifier(u.notificationClient, cfg.DiscordWebhookURL)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", "discord webhook is not a valid url", err)
+		}
+		configured = append(configured, n)
+	}
+	if cfg.Telegram.BotToken != "" || cfg.Telegram.ChatID != "" {
+		n, err := newTelegramNotifier(u.notificationClient, cfg.Telegram.BotToken, cfg.Telegram.ChatID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", "unable to configure telegram", err)
+		}
+		configured = append(configured, n)
+	}
+	if cfg.SMTP.Address != "" {
+		security := cfg.SMTP.Security
+		if security == "" {
+			security = DefaultSMTPSecurity
+		}
+		n, err := newSMTPNotifier(cfg.SMTP.Address, security, cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.From, cfg.SMTP.To)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", "unable to configure smtp", err)
+		}
+		configured = append(configured, n)
+	}
+	if cfg.SES.From != "" {
+		n, err := newSESNotifier(awsCfg, cfg.SES.From, cfg.SES.To)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", "unable to configure ses", err)
+		}
+		configured = append(configured, n)
+	}
+	if cfg.SNSTopicARN != "" {
+		n, err := newSNSNotifier(awsCfg, cfg.SNSTopicARN)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", "sns topic is not a valid arn", err)
+		}
+		configured = append(configured, n)
+	}
+	if cfg.EventBridgeBus != "" {
+		configured = append(configured, newEventBridgeNotifier(awsCfg, cfg.EventBridgeBus))
+	}
+	if cfg.PagerDutyRoutingKey != "" {
+		n, err := newPagerDutyNotifier(u.notificationClient, cfg.PagerDutyRoutingKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", "unable to configure pagerduty", err)
+		}
+		configured = append(configured, n)
+	}
+	if cfg.NTFY.URL != "" {
+		n, err := newNTFYNotifier(u.notificationClient, cfg.NTFY.URL, cfg.NTFY.Token, cfg.NTFY.Priority, cfg.NTFY.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", "unable to configure ntfy", err)
+		}
+		configured = append(configured, n)
+	}
+	if cfg.Pushover.Token != "" || cfg.Pushover.User != "" {
+		n, err := newPushoverNotifier(u.notificationClient, cfg.Pushover.Token, cfg.Pushover.User, cfg.Pushover.Priority)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", "unable to configure pushover", err)
+		}
+		configured = append(configured, n)
+	}
+	if cfg.MQTT.Broker != "" {
+		topic := cfg.MQTT.Topic
+		if topic == "" {
+			topic = DefaultMQTTTopic
+		}
+		n, err := u.newMQTTNotifier(cfg.MQTT.Broker, topic, cfg.MQTT.QoS, cfg.MQTT.Username, cfg.MQTT.Password, cfg.MQTT.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", "unable to configure mqtt", err)
+		}
+		configured = append(configured, n)
+	}
+	if cfg.Gotify.URL != "" {
+		n, err := newGotifyNotifier(u.notificationClient, cfg.Gotify.URL, cfg.Gotify.Token, cfg.Gotify.Priority)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", "unable to configure gotify", err)
+		}
+		configured = append(configured, n)
+	}
+	if cfg.Matrix.Homeserver != "" {
+		n, err := newMatrixNotifier(u.notificationClient, cfg.Matrix.Homeserver, cfg.Matrix.AccessToken, cfg.Matrix.RoomID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", "unable to configure matrix", err)
+		}
+		configured = append(configured, n)
+	}
+
+	return configured, nil
+}
+
+// configureNotifications creates the notifiers of cfg with their filters and templates, replacing any
+// configured before
+func (u *Updater) configureNotifications(cfg NotificationsConfig, awsCfg aws.Config) error {
+	if cfg.FailureRepeat < 0 || cfg.MinInterval < 0 {
+		return errors.New("failure repeat and minimum interval must not be negative")
+	}
+	configured, err := u.newNotifiers(cfg, awsCfg)
+	if err != nil {
+		return err
+	}
+	templates, err := loadNotificationTemplates(configured, cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", "unable to parse notification template", err)
+	}
+	filters, err := loadNotificationFilters(configured, cfg.Events)
+	if err != nil {
+		return fmt.Errorf("%s: %w", "unable to configure notification events", err)
+	}
+
+	u.notifiersMu.Lock()
+	replaced := u.notifiers
+	u.notifiers, u.notificationTemplates, u.notificationFilters = configured, templates, filters
+	u.notifiersMu.Unlock()
+	u.failureMu.Lock()
+	u.notifyFailureRepeat = cfg.FailureRepeat
+	u.failureMu.Unlock()
+	u.notificationMu.Lock()
+	u.notifyMinInterval = cfg.MinInterval
+	u.notificationMu.Unlock()
+
+	for _, n := range replaced {
+		if c, ok := n.(closingNotifier); ok {
+			c.close()
+		}
+	}
+
+	return nil
+}
+
+// notificationEvents lists every event type a filter may name
+var notificationEvents = []string{EventIPChanged, EventUpdateFailed, EventRecovered, EventDriftDetected,
+	EventDaemonStarted, EventDaemonStopped}
+
+// loadNotificationFilters builds the filter of every notifier of configured listed in events
+func loadNotificationFilters(configured []notifier, events map[string][]string) (map[string]map[string]bool, error) {
+	filters := map[string]map[string]bool{}
+	for _, n := range configured {
+		if len(events[n.name()]) == 0 {
+			continue
+		}
+
+		filter := map[string]bool{}
+		for _, event := range events[n.name()] {
+			if !containsString(notificationEvents, event) {
+				return nil, fmt.Errorf("%s: %s, must be one of %s", n.name(), event, strings.Join(notificationEvents, ", "))
+			}
+			filter[event] = true
+		}
+		filters[n.name()] = filter
+	}
+
+	return filters, nil
+}
+
+// wantsEvent reports whether the filter of notifier allows event
+func (u *Updater) wantsEvent(notifier, event string) bool {
+	filter, ok := u.notificationFilters[notifier]
+
+	return !ok || filter[event]
+}
+
+// notificationRequest is a queued event
+type notificationRequest struct {
+	ctx   context.Context
+	event notificationEvent
+}
+
+// notificationLimit tracks when a kind of event was last sent and how many were dropped since
+type notificationLimit struct {
+	sent       time.Time
+	suppressed int
+}
+
+// rateLimitNotification reports whether event may be sent, counting it as suppressed when a similar
+// event was sent within notifyMinInterval. events are similar when their type, record, provider and
+// error category match, so a changing error message doesn't defeat the limit
+func (u *Updater) rateLimitNotification(event *notificationEvent) bool {
+	u.notificationMu.Lock()
+	defer u.notificationMu.Unlock()
+
+	if u.notifyMinInterval <= 0 {
+		return true
+	}
+
+	key := event.Type + "|" + event.FQDN + "|" + event.Provider + "|" + event.Category
+	limit, ok := u.notificationLimits[key]
+	if !ok {
+		limit = &notificationLimit{}
+		u.notificationLimits[key] = limit
+	}
+
+	if !limit.sent.IsZero() && event.Time.Sub(limit.sent) < u.notifyMinInterval {
+		limit.suppressed++
+		return false
+	}
+
+	event.Suppressed = limit.suppressed
+	limit.sent, limit.suppressed = event.Time, 0
+
+	return true
+}
+
+// notify queues event for every notifier, delivery happens in the background so a slow target never
+// delays a cycle
+func (u *Updater) notify(ctx context.Context, event notificationEvent) {
+	u.notifiersMu.RLock()
+	configured := len(u.notifiers) > 0
+	u.notifiersMu.RUnlock()
+	if !configured {
+		return
+	}
+
+	u.notificationsOnce.Do(func() {
+		u.notificationQueue = make(chan notificationRequest, 64)
+		go func() {
+			for req := range u.notificationQueue {
+				u.deliverNotification(req.ctx, req.event)
+				u.notificationsWG.Done()
+			}
+		}()
+	})
+
+	event.Time = u.clock().UTC()
+	event.RunID = runIDFrom(ctx)
+	if !u.rateLimitNotification(&event) {
+		u.logger.DebugContext(ctx, "rate limited notification", "event", event.Type)
+		return
+	}
+
+	u.notificationsWG.Add(1)
+	select {
+	case u.notificationQueue <- notificationRequest{ctx: context.WithoutCancel(ctx), event: event}:
+	default:
+		u.notificationsWG.Done()
+		u.logger.WarnContext(ctx, "notification queue is full, dropping event", "event", event.Type)
+	}
+}
+
+// deliverNotification sends event to every notifier in turn
+func (u *Updater) deliverNotification(ctx context.Context, event notificationEvent) {
+	u.notifiersMu.RLock()
+	defer u.notifiersMu.RUnlock()
+
+	for _, n := range u.notifiers {
+		if !u.wantsEvent(n.name(), event.Type) {
+			continue
+		}
+
+		sendCtx, cancel := context.WithTimeout(ctx, notificationTimeout)
+		err := n.notify(sendCtx, u.applyTemplates(ctx, n.name(), event))
+		cancel()
+		if err != nil {
+			u.logger.WarnContext(ctx, "unable to deliver notification", "notifier", n.name(), "event", event.Type, "error", err)
+			continue
+		}
+		u.logger.DebugContext(ctx, "delivered notification", "notifier", n.name(), "event", event.Type)
+	}
+}
+
+// flushNotifications waits up to timeout for queued events to be delivered, used before exiting
+func (u *Updater) flushNotifications(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		u.notificationsWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+// postNotification posts body to target with client, treating any non-2xx response as a failure
+func postNotification(ctx context.Context, client *http.Client, target, contentType string, body []byte, header http.Header) error {
+	return sendNotification(ctx, client, http.MethodPost, target, contentType, body, header)
+}
+
+// sendNotification sends body to target with method and client, treating any non-2xx response as a
+// failure
+func sendNotification(ctx context.Context, client *http.Client, method, target, contentType string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s %s", "unexpected response", resp.Status, bytes.TrimSpace(detail))
+	}
+
+	return nil
+}
+
+// postJSON sends payload encoded as json to target with client
+func postJSON(ctx context.Context, client *http.Client, target string, payload any, header http.Header) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return postNotification(ctx, client, target, "application/json", body, header)
+}
+
+// notifyLifecycle tells humans about changed and drifted records
+func (u *Updater) notifyLifecycle(ctx context.Context, event lifecycleEvent) {
+	switch e := event.(type) {
+	case updateSucceededEvent:
+		u.notify(ctx, notificationEvent{Type: EventIPChanged, FQDN: e.Record.fqdn, ZoneID: e.ZoneID, Provider: e.Record.provider.Name(),
+			OldIP: e.OldIP, NewIP: e.NewIP, ChangeID: e.ChangeID})
+	case driftDetectedEvent:
+		u.notify(ctx, notificationEvent{Type: EventDriftDetected, FQDN: e.Record.fqdn, ZoneID: e.ZoneID, Provider: e.Record.provider.Name(),
+			OldIP: e.RecordIP, NewIP: e.NewIP, ExpectedIP: e.PublishedIP})
+	}
+}
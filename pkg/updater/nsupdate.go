@@ -0,0 +1,348 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	mdns "github.com/miekg/dns"
+	"github.com/rgravlin/route53ddns/pkg/dns"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// nsupdateTimeout bounds applying a single update message to route53
+const nsupdateTimeout = 30 * time.Second
+
+// nsupdateTypes are the record types updates may change, those route53 holds and dhcp servers update.
+// dhcid is not among them, so dhcp servers must be set to keep conflict resolution records in txt
+var nsupdateTypes = []uint16{mdns.TypeA, mdns.TypeAAAA, mdns.TypeCNAME, mdns.TypePTR, mdns.TypeTXT, mdns.TypeMX, mdns.TypeSRV}
+
+// nsupdateListener accepts rfc 2136 updates signed with its tsig key and applies them to the route53
+// zones it is allowed to change
+type nsupdateListener struct {
+	address   string
+	keyName   string
+	algorithm string
+	secret    string
+	// zones are the zones updates may change, without the trailing dot
+	zones    []string
+	provider *dns.Route53
+	servers  []*mdns.Server
+	logger   *slog.Logger
+	// mu serializes updates, each reads the record sets it changes before submitting the change
+	mu sync.Mutex
+}
+
+// newNSUpdateListener returns a listener on address for updates to zones signed with the base64
+// secret of the tsig key keyName using algorithm, hmac-sha256 when empty, logging to logger
+func newNSUpdateListener(address, keyName, algorithm, secret string, zones []string, logger *slog.Logger) (*nsupdateListener, error) {
+	if keyName == "" || secret == "" {
+		return nil, errors.New("updates must be signed, a tsig key and secret are required")
+	}
+	if len(zones) == 0 {
+		return nil, errors.New("no zones may be updated")
+	}
+	if algorithm == "" {
+		algorithm = dns.DefaultTSIGAlgorithm
+	}
+
+	l := &nsupdateListener{address: address, keyName: mdns.Fqdn(strings.ToLower(keyName)), algorithm: mdns.Fqdn(strings.ToLower(algorithm)), secret: secret,
+		logger: logger}
+	for _, zone := range zones {
+		l.zones = append(l.zones, strings.ToLower(strings.TrimSuffix(zone, ".")))
+	}
+
+	return l, nil
+}
+
+// start serves updates over udp and tcp in the background, applying them with provider
+func (l *nsupdateListener) start(provider *dns.Route53) {
+	l.provider = provider
+	for _, network := range []string{"udp", "tcp"} {
+		server := &mdns.Server{
+			Addr:       l.address,
+			Net:        network,
+			Handler:    mdns.HandlerFunc(l.serve),
+			TsigSecret: map[string]string{l.keyName: l.secret},
+		}
+		l.servers = append(l.servers, server)
+
+		go func() {
+			l.logger.Info("accepting dynamic updates", "address", server.Addr, "network", server.Net, "zones", l.zones)
+			if err := server.ListenAndServe(); err != nil {
+				l.logger.Error("dynamic update listener failed", "address", server.Addr, "network", server.Net, "error", err)
+			}
+		}()
+	}
+}
+
+// stop stops accepting updates
+func (l *nsupdateListener) stop() {
+	for _, server := range l.servers {
+		_ = server.Shutdown()
+	}
+}
+
+// serve answers an update message, signing the answer when the request was signed
+func (l *nsupdateListener) serve(w mdns.ResponseWriter, req *mdns.Msg) {
+	resp := new(mdns.Msg)
+	resp.SetRcode(req, l.handle(w, req))
+
+	if tsig := req.IsTsig(); tsig != nil && w.TsigStatus() == nil {
+		resp.SetTsig(tsig.Hdr.Name, tsig.Algorithm, 300, time.Now().Unix())
+	}
+	_ = w.WriteMsg(resp)
+}
+
+// handle applies req and returns the rcode to answer with
+func (l *nsupdateListener) handle(w mdns.ResponseWriter, req *mdns.Msg) int {
+	client := w.RemoteAddr().String()
+	if req.Opcode != mdns.OpcodeUpdate {
+		return mdns.RcodeNotImplemented
+	}
+
+	tsig := req.IsTsig()
+	if tsig == nil || w.TsigStatus() != nil || !strings.EqualFold(tsig.Hdr.Name, l.keyName) || !strings.EqualFold(tsig.Algorithm, l.algorithm) {
+		l.logger.Warn("refused unsigned or badly signed update", "client", client, "tsig_error", w.TsigStatus())
+		return mdns.RcodeNotAuth
+	}
+
+	if len(req.Question) != 1 || req.Question[0].Qtype != mdns.TypeSOA {
+		return mdns.RcodeFormatError
+	}
+	zone := strings.ToLower(strings.TrimSuffix(req.Question[0].Name, "."))
+	if !slices.Contains(l.zones, zone) {
+		l.logger.Warn("refused update to a zone not allowed", "client", client, "zone", zone)
+		return mdns.RcodeNotAuth
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), nsupdateTimeout)
+	defer cancel()
+
+	// every name is changed in the zone of the question, however deep below it
+	zoneID, err := zoneIDOf(ctx, l.provider, zone)
+	if err != nil {
+		l.logger.Error("unable to look up the updated zone", "client", client, "zone", zone, "error", err)
+		return mdns.RcodeServerFailure
+	}
+
+	// every name must be inside the zone
+	for _, rr := range append(append([]mdns.RR{}, req.Answer...), req.Ns...) {
+		if !mdns.IsSubDomain(mdns.Fqdn(zone), strings.ToLower(rr.Header().Name)) {
+			return mdns.RcodeNotZone
+		}
+	}
+
+	// the names of the message are read once, prerequisites are checked and updates applied to what
+	// was read, and the result is submitted as a single batch so the update is applied all or nothing
+	held, err := l.load(ctx, zoneID, append(append([]mdns.RR{}, req.Answer...), req.Ns...))
+	if err != nil {
+		l.logger.Error("unable to read the updated records", "client", client, "zone", zone, "error", err)
+		return mdns.RcodeServerFailure
+	}
+	for _, rr := range req.Answer {
+		if rcode := held.checkPrerequisite(rr); rcode != mdns.RcodeSuccess {
+			return rcode
+		}
+	}
+
+	desired := held.clone()
+	for _, rr := range req.Ns {
+		if err := desired.apply(rr); err != nil {
+			l.logger.Warn("refused dynamic update", "client", client, "zone", zone, "update", rr.String(), "error", err)
+			var unsupported *unsupportedTypeError
+			if errors.As(err, &unsupported) {
+				return mdns.RcodeNotImplemented
+			}
+			return mdns.RcodeFormatError
+		}
+	}
+	if changes := held.changes(desired); len(changes) > 0 {
+		if _, err := l.provider.ChangeRecords(ctx, zoneID, changes, "route53ddns dynamic update"); err != nil {
+			l.logger.Error("unable to apply dynamic update", "client", client, "zone", zone, "changes", len(changes), "error", err)
+			return mdns.RcodeServerFailure
+		}
+	}
+	for _, rr := range req.Ns {
+		l.logger.Info("applied dynamic update", "client", client, "zone", zone, "update", rr.String())
+	}
+
+	return mdns.RcodeSuccess
+}
+
+// unsupportedTypeError reports an update to a record type route53 doesn't hold
+type unsupportedTypeError struct {
+	rrType uint16
+}
+
+func (e *unsupportedTypeError) Error() string {
+	return fmt.Sprintf("%s: %s", "unsupported record type", mdns.TypeToString[e.rrType])
+}
+
+// nsupdateSets holds the record sets updates may change by name, without the trailing dot, and type
+type nsupdateSets map[string]map[uint16]dns.Record
+
+// load reads the record sets of every name of rrs, listing each name once
+func (l *nsupdateListener) load(ctx context.Context, zoneID string, rrs []mdns.RR) (nsupdateSets, error) {
+	held := nsupdateSets{}
+	for _, rr := range rrs {
+		name := rrName(rr)
+		if _, ok := held[name]; ok {
+			continue
+		}
+
+		records, err := l.provider.ListRecords(ctx, zoneID, name)
+		if err != nil {
+			return nil, err
+		}
+		held[name] = map[uint16]dns.Record{}
+		for _, record := range records {
+			rrType := mdns.StringToType[record.Type]
+			if _, ok := held[name][rrType]; !ok && slices.Contains(nsupdateTypes, rrType) {
+				held[name][rrType] = record
+			}
+		}
+	}
+
+	return held, nil
+}
+
+// checkPrerequisite returns the rcode an unmet prerequisite fails the update with, or success
+func (s nsupdateSets) checkPrerequisite(rr mdns.RR) int {
+	header := rr.Header()
+	sets := s[rrName(rr)]
+
+	switch {
+	case header.Class == mdns.ClassANY && header.Rrtype == mdns.TypeANY:
+		if len(sets) == 0 {
+			return mdns.RcodeNameError
+		}
+		return mdns.RcodeSuccess
+	case header.Class == mdns.ClassNONE && header.Rrtype == mdns.TypeANY:
+		if len(sets) > 0 {
+			return mdns.RcodeYXDomain
+		}
+		return mdns.RcodeSuccess
+	}
+
+	current, exists := sets[header.Rrtype]
+	switch header.Class {
+	case mdns.ClassANY:
+		if !exists {
+			return mdns.RcodeNXRrset
+		}
+	case mdns.ClassNONE:
+		if exists {
+			return mdns.RcodeYXRrset
+		}
+	default:
+		if !exists || !slices.Contains(current.Values, rrValue(rr)) {
+			return mdns.RcodeNXRrset
+		}
+	}
+
+	return mdns.RcodeSuccess
+}
+
+// apply applies a single update to the sets, adding, deleting or replacing values of a record set.
+// added values replace the ttl of the set unless they have none
+func (s nsupdateSets) apply(rr mdns.RR) error {
+	header := rr.Header()
+	name := rrName(rr)
+
+	// delete every record set of the name
+	if header.Class == mdns.ClassANY && header.Rrtype == mdns.TypeANY {
+		clear(s[name])
+		return nil
+	}
+	if !slices.Contains(nsupdateTypes, header.Rrtype) {
+		return &unsupportedTypeError{rrType: header.Rrtype}
+	}
+
+	sets := s[name]
+	current, exists := sets[header.Rrtype]
+	switch header.Class {
+	case mdns.ClassANY:
+		delete(sets, header.Rrtype)
+	case mdns.ClassNONE:
+		current.Values = slices.DeleteFunc(current.Values, func(value string) bool { return value == rrValue(rr) })
+		if len(current.Values) == 0 {
+			delete(sets, header.Rrtype)
+		} else {
+			sets[header.Rrtype] = current
+		}
+	default:
+		if !exists {
+			current = dns.Record{Name: name, Type: mdns.TypeToString[header.Rrtype], Routing: "simple"}
+		}
+		if header.Ttl != 0 {
+			current.TTL = int64(header.Ttl)
+		}
+		if !slices.Contains(current.Values, rrValue(rr)) {
+			current.Values = append(current.Values, rrValue(rr))
+		}
+		sets[header.Rrtype] = current
+	}
+
+	return nil
+}
+
+// clone returns a copy of the sets that can be changed without changing s
+func (s nsupdateSets) clone() nsupdateSets {
+	cloned := make(nsupdateSets, len(s))
+	for name, sets := range s {
+		cloned[name] = make(map[uint16]dns.Record, len(sets))
+		for rrType, record := range sets {
+			record.Values = slices.Clone(record.Values)
+			cloned[name][rrType] = record
+		}
+	}
+
+	return cloned
+}
+
+// changes returns the changes turning s into desired, ordered by name and type
+func (s nsupdateSets) changes(desired nsupdateSets) []dns.Change {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var changes []dns.Change
+	for _, name := range names {
+		for _, rrType := range nsupdateTypes {
+			current, held := s[name][rrType]
+			wanted, kept := desired[name][rrType]
+			switch {
+			case held && !kept:
+				changes = append(changes, dns.Change{Delete: true, Record: current})
+			case kept && (!held || current.TTL != wanted.TTL || !slices.Equal(current.Values, wanted.Values)):
+				changes = append(changes, dns.Change{Record: wanted})
+			}
+		}
+	}
+
+	return changes
+}
+
+// rrName returns the name of rr as updates are keyed, lowercased without the trailing dot
+func rrName(rr mdns.RR) string {
+	return strings.ToLower(strings.TrimSuffix(rr.Header().Name, "."))
+}
+
+// zoneIDOf returns the id of the zone named zone. providers resolve the zone holding a record, so the
+// zone is resolved through a name right below it
+func zoneIDOf(ctx context.Context, provider dns.Provider, zone string) (string, error) {
+	return findZoneID(ctx, provider, "_nsupdate."+zone)
+}
+
+// rrValue returns the data of rr in presentation format, as route53 holds it
+func rrValue(rr mdns.RR) string {
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
@@ -0,0 +1,93 @@
+package updater
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	mdns "github.com/miekg/dns"
+	"github.com/rgravlin/route53ddns/pkg/dns"
+)
+
+// fakeResponseWriter is a dns response writer keeping the answer, with the tsig status the server
+// would have verified the request with
+type fakeResponseWriter struct {
+	tsigStatus error
+	answer     *mdns.Msg
+}
+
+func (w *fakeResponseWriter) LocalAddr() net.Addr {
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53}
+}
+
+func (w *fakeResponseWriter) RemoteAddr() net.Addr {
+	return &net.UDPAddr{IP: net.IPv4(192, 0, 2, 10), Port: 5353}
+}
+
+func (w *fakeResponseWriter) WriteMsg(m *mdns.Msg) error {
+	w.answer = m
+	return nil
+}
+
+func (w *fakeResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *fakeResponseWriter) Close() error        { return nil }
+func (w *fakeResponseWriter) TsigStatus() error   { return w.tsigStatus }
+func (w *fakeResponseWriter) TsigTimersOnly(bool) {}
+func (w *fakeResponseWriter) Hijack()             {}
+
+func TestNSUpdateAuthorization(t *testing.T) {
+	l, err := newNSUpdateListener("127.0.0.1:0", "dhcp-key", "", "c2VjcmV0", []string{"lan.example.com."},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("newNSUpdateListener() error = %v", err)
+	}
+	l.provider = dns.NewRoute53(dns.NewFakeRoute53("lan.example.com"))
+
+	// update returns an update of zone adding an address, signed with key and algorithm unless key is empty
+	update := func(zone, key, algorithm string) *mdns.Msg {
+		m := new(mdns.Msg)
+		m.SetUpdate(zone)
+		rr, _ := mdns.NewRR("printer." + zone + " 300 IN A 192.0.2.20")
+		m.Insert([]mdns.RR{rr})
+		if key != "" {
+			m.SetTsig(key, algorithm, 300, time.Now().Unix())
+		}
+		return m
+	}
+
+	for _, tc := range []struct {
+		name       string
+		req        *mdns.Msg
+		tsigStatus error
+		want       int
+	}{
+		{name: "signed", req: update("lan.example.com.", "DHCP-Key.", mdns.HmacSHA256), want: mdns.RcodeSuccess},
+		{name: "unsigned", req: update("lan.example.com.", "", ""), want: mdns.RcodeNotAuth},
+		{name: "bad signature", req: update("lan.example.com.", "dhcp-key.", mdns.HmacSHA256), tsigStatus: mdns.ErrSig, want: mdns.RcodeNotAuth},
+		{name: "expired signature", req: update("lan.example.com.", "dhcp-key.", mdns.HmacSHA256), tsigStatus: mdns.ErrTime, want: mdns.RcodeNotAuth},
+		{name: "other key", req: update("lan.example.com.", "other-key.", mdns.HmacSHA256), want: mdns.RcodeNotAuth},
+		{name: "other algorithm", req: update("lan.example.com.", "dhcp-key.", mdns.HmacSHA512), want: mdns.RcodeNotAuth},
+		{name: "zone not allowed", req: update("example.com.", "dhcp-key.", mdns.HmacSHA256), want: mdns.RcodeNotAuth},
+		{name: "query", req: new(mdns.Msg).SetQuestion("printer.lan.example.com.", mdns.TypeA), want: mdns.RcodeNotImplemented},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := &fakeResponseWriter{tsigStatus: tc.tsigStatus}
+			l.serve(w, tc.req)
+
+			if w.answer == nil {
+				t.Fatal("no answer written")
+			}
+			if w.answer.Rcode != tc.want {
+				t.Errorf("rcode = %s, want %s", mdns.RcodeToString[w.answer.Rcode], mdns.RcodeToString[tc.want])
+			}
+			if tc.tsigStatus != nil && w.answer.IsTsig() != nil {
+				t.Error("answer to a badly signed update is signed")
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ntfyNotifier publishes events to an ntfy topic on ntfy.sh or a self-hosted server
+type ntfyNotifier struct {
+	client   *http.Client
+	url      string
+	token    string
+	priority string
+	tags     []string
+}
+
+// NTFYConfig is the ntfy topic notifications are published to
+type NTFYConfig struct {
+	// URL is the topic url, ntfy is disabled when empty
+	URL   string
+	Token string
+	// Priority is 1-5 or one of min, low, default, high or max, the server default when empty
+	Priority string
+	Tags     []string
+}
+
+// newNTFYNotifier validates the topic url and priority, which is 1-5 or one of min, low, default,
+// high or max
+func newNTFYNotifier(client *http.Client, topicURL, token, priority string, tags []string) (*ntfyNotifier, error) {
+	u, err := url.ParseRequestURI(topicURL)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Trim(u.Path, "/") == "" {
+		return nil, fmt.Errorf("%s: %s", "url has no topic", topicURL)
+	}
+
+	switch priority {
+	case "", "1", "2", "3", "4", "5", "min", "low", "default", "high", "max", "urgent":
+	default:
+		return nil, fmt.Errorf("%s: %s", "not a valid priority", priority)
+	}
+
+	return &ntfyNotifier{client: client, url: topicURL, token: token, priority: priority, tags: tags}, nil
+}
+
+func (n *ntfyNotifier) name() string {
+	return "ntfy"
+}
+
+func (n *ntfyNotifier) notify(ctx context.Context, event notificationEvent) error {
+	header := http.Header{}
+	header.Set("Title", event.title())
+	header.Set("Tags", strings.Join(append([]string{event.Type}, n.tags...), ","))
+	if n.priority != "" {
+		header.Set("Priority", n.priority)
+	}
+	if n.token != "" {
+		header.Set("Authorization", "Bearer "+n.token)
+	}
+
+	return postNotification(ctx, n.client, n.url, "text/plain; charset=utf-8", []byte(event.message()), header)
+}
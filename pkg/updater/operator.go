@@ -0,0 +1,547 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rgravlin/route53ddns/pkg/dns"
+	"github.com/rgravlin/route53ddns/pkg/ipsource"
+	"github.com/rgravlin/route53ddns/pkg/kube"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the DDNSRecord custom resource reconciled by the operator
+const (
+	DDNSRecordGroup     = "route53ddns.rgravlin.github.io"
+	DDNSRecordVersion   = "v1alpha1"
+	DDNSRecordPlural    = "ddnsrecords"
+	DDNSRecordFinalizer = DDNSRecordGroup + "/record"
+)
+
+// sources of the address a DDNSRecord is pointed at
+const (
+	DDNSSourceDetected = "detected"
+	DDNSSourceStatic   = "static"
+	DDNSSourceURL      = "url"
+)
+
+// reasons of the Ready condition of a DDNSRecord
+const (
+	DDNSReasonPublished         = "Published"
+	DDNSReasonInvalid           = "InvalidSpec"
+	DDNSReasonWaitingForAddress = "WaitingForAddress"
+	DDNSReasonSourceFailed      = "SourceFailed"
+	DDNSReasonPublishFailed     = "PublishFailed"
+)
+
+// ddnsRecord is a DDNSRecord custom resource
+type ddnsRecord struct {
+	Metadata struct {
+		kube.ObjectMeta
+		Finalizers []string `json:"finalizers,omitempty"`
+	} `json:"metadata"`
+	Spec   ddnsRecordSpec   `json:"spec"`
+	Status ddnsRecordStatus `json:"status"`
+}
+
+// ddnsRecordSpec is the record a DDNSRecord asks for
+type ddnsRecordSpec struct {
+	FQDN string `json:"fqdn"`
+	// Provider names the provider hosting the zone, route53 when empty
+	Provider string `json:"provider,omitempty"`
+	// Profile is the aws profile whose credentials update a route53 record
+	Profile string `json:"profile,omitempty"`
+	// Source is where the address comes from, the address detected by the daemon when empty
+	Source string `json:"source,omitempty"`
+	// IP is the address of the static source
+	IP string `json:"ip,omitempty"`
+	// URL answers with the address of the url source
+	URL string `json:"url,omitempty"`
+	TTL int64  `json:"ttl,omitempty"`
+	// RoutingPolicy is simple or weighted, weighted records need a set identifier and route53
+	RoutingPolicy string `json:"routingPolicy,omitempty"`
+	SetIdentifier string `json:"setIdentifier,omitempty"`
+	Weight        *int64 `json:"weight,omitempty"`
+}
+
+// ddnsRecordStatus reports what was last published for a DDNSRecord
+type ddnsRecordStatus struct {
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+	PublishedIP        string `json:"publishedIP,omitempty"`
+	// PublishedFQDN, PublishedType and PublishedSetIdentifier name the record set published, so it is
+	// deleted when the spec moves the record elsewhere
+	PublishedFQDN          string          `json:"publishedFQDN,omitempty"`
+	PublishedType          string          `json:"publishedType,omitempty"`
+	PublishedSetIdentifier string          `json:"publishedSetIdentifier,omitempty"`
+	PublishedProvider      string          `json:"publishedProvider,omitempty"`
+	ZoneID                 string          `json:"zoneID,omitempty"`
+	LastPublished          *time.Time      `json:"lastPublished,omitempty"`
+	Conditions             []ddnsCondition `json:"conditions,omitempty"`
+}
+
+// ddnsCondition is a status condition of a DDNSRecord
+type ddnsCondition struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"`
+	Reason             string    `json:"reason"`
+	Message            string    `json:"message,omitempty"`
+	ObservedGeneration int64     `json:"observedGeneration,omitempty"`
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+}
+
+// ddnsOperator reconciles DDNSRecord resources into their providers, publishing them again whenever
+// the detected address changes
+type ddnsOperator struct {
+	// updater publishes the records
+	updater   *Updater
+	client    *kube.Client
+	namespace string
+	// resync asks for every resource to be reconciled again
+	resync chan struct{}
+
+	// mu serializes reconciliations and guards objects
+	mu      sync.Mutex
+	objects map[string]ddnsRecord
+}
+
+// newDDNSOperator returns an operator of the DDNSRecord resources in namespace, or every namespace
+// when empty
+func (u *Updater) newDDNSOperator(client *kube.Client, namespace string) *ddnsOperator {
+	return &ddnsOperator{updater: u, client: client, namespace: namespace, resync: make(chan struct{}, 1), objects: map[string]ddnsRecord{}}
+}
+
+// start reconciles resources in the background until ctx is done, as they change, when the detected
+// address changes and every reconcile interval
+func (o *ddnsOperator) start(ctx context.Context) {
+	o.updater.lifecycle.subscribe(func(_ context.Context, event lifecycleEvent) {
+		if _, ok := event.(ipChangedEvent); ok {
+			o.requestResync()
+		}
+	})
+
+	go o.watch(ctx)
+	go func() {
+		ticker := time.NewTicker(o.updater.cfg.ReconcileInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-o.resync:
+			}
+			o.reconcileAll(ctx)
+		}
+	}()
+}
+
+// requestResync asks for every resource to be reconciled, without waiting
+func (o *ddnsOperator) requestResync() {
+	select {
+	case o.resync <- struct{}{}:
+	default:
+	}
+}
+
+// collection returns the api path of the resources watched
+func (o *ddnsOperator) collection() string {
+	path := "/apis/" + DDNSRecordGroup + "/" + DDNSRecordVersion
+	if o.namespace != "" {
+		path += "/namespaces/" + o.namespace
+	}
+
+	return path + "/" + DDNSRecordPlural
+}
+
+// resource returns the api path of a resource
+func (o *ddnsOperator) resource(object ddnsRecord) string {
+	return "/apis/" + DDNSRecordGroup + "/" + DDNSRecordVersion + "/namespaces/" + object.Metadata.Namespace + "/" +
+		DDNSRecordPlural + "/" + object.Metadata.Name
+}
+
+// watch lists the resources and follows their changes, listing again whenever the watch ends
+func (o *ddnsOperator) watch(ctx context.Context) {
+	path := o.collection()
+	for ctx.Err() == nil {
+		list, err := o.client.List(ctx, path)
+		if err != nil {
+			o.updater.logger.Error("unable to list ddns records", "error", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(watchRetryDelay):
+			}
+			continue
+		}
+
+		objects := map[string]ddnsRecord{}
+		for _, item := range list.Items {
+			var object ddnsRecord
+			if err := json.Unmarshal(item, &object); err == nil {
+				objects[object.Metadata.Namespace+"/"+object.Metadata.Name] = object
+			}
+		}
+		o.mu.Lock()
+		o.objects = objects
+		o.mu.Unlock()
+		o.reconcileAll(ctx)
+
+		err = o.client.Watch(ctx, path, list.Metadata.ResourceVersion, func(event kube.Event) error {
+			var object ddnsRecord
+			if err := json.Unmarshal(event.Object, &object); err != nil {
+				return nil
+			}
+			key := object.Metadata.Namespace + "/" + object.Metadata.Name
+
+			o.mu.Lock()
+			defer o.mu.Unlock()
+			switch event.Type {
+			case "ADDED", "MODIFIED":
+				o.objects[key] = object
+				o.reconcile(ctx, key)
+			case "DELETED":
+				delete(o.objects, key)
+			}
+			return nil
+		})
+		switch {
+		case errors.Is(err, kube.ErrExpired):
+			o.updater.logger.Debug("ddns record watch expired, listing again")
+		case err != nil && ctx.Err() == nil:
+			o.updater.logger.Warn("ddns record watch failed, listing again", "error", err)
+		}
+	}
+}
+
+// reconcileAll reconciles every known resource
+func (o *ddnsOperator) reconcileAll(ctx context.Context) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for key := range o.objects {
+		o.reconcile(ctx, key)
+	}
+}
+
+// reconcile publishes the record of the resource named key and reports the outcome in its status, or
+// deletes the record once the resource is being deleted. a standby leaves resources to the leader
+// and reconciles them at the first resync after it is elected
+func (o *ddnsOperator) reconcile(ctx context.Context, key string) {
+	if !o.updater.isLeader() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, o.updater.cfg.CycleTimeout)
+	defer cancel()
+
+	object := o.objects[key]
+	if object.Metadata.DeletionTimestamp != nil {
+		if !containsString(object.Metadata.Finalizers, DDNSRecordFinalizer) {
+			return
+		}
+		if err := o.unpublish(ctx, object.Status); err != nil {
+			o.updater.logger.ErrorContext(ctx, "unable to delete ddns record", "resource", key, "error_category", ErrorCause(err), "error", err)
+			return
+		}
+		finalizers := slices.DeleteFunc(slices.Clone(object.Metadata.Finalizers), func(f string) bool { return f == DDNSRecordFinalizer })
+		if err := o.patchFinalizers(ctx, object, finalizers); err != nil {
+			o.updater.logger.ErrorContext(ctx, "unable to remove finalizer", "resource", key, "error", err)
+		}
+		return
+	}
+
+	if !containsString(object.Metadata.Finalizers, DDNSRecordFinalizer) {
+		if err := o.patchFinalizers(ctx, object, append(slices.Clone(object.Metadata.Finalizers), DDNSRecordFinalizer)); err != nil {
+			o.updater.logger.ErrorContext(ctx, "unable to add finalizer", "resource", key, "error", err)
+			return
+		}
+	}
+
+	status := object.Status
+	reason, err := o.publish(ctx, object, &status)
+	if err != nil {
+		o.updater.logger.ErrorContext(ctx, "unable to reconcile ddns record", "resource", key, "reason", reason, "error_category", ErrorCause(err), "error", err)
+		o.setDDNSCondition(&status, object.Metadata.Generation, "False", reason, err.Error())
+	} else {
+		o.setDDNSCondition(&status, object.Metadata.Generation, "True", reason, "")
+	}
+	status.ObservedGeneration = object.Metadata.Generation
+
+	if statusJSON, _ := json.Marshal(status); string(statusJSON) != mustJSON(object.Status) {
+		if err := o.client.Do(ctx, http.MethodPatch, o.resource(object)+"/status", map[string]any{"status": status}, nil); err != nil {
+			o.updater.logger.ErrorContext(ctx, "unable to update ddns record status", "resource", key, "error", err)
+			return
+		}
+		object.Status = status
+		o.objects[key] = object
+	}
+}
+
+// publish points the record of object at the address of its source, recording what was published in
+// status, and returns the reason of the Ready condition
+func (o *ddnsOperator) publish(ctx context.Context, object ddnsRecord, status *ddnsRecordStatus) (string, error) {
+	spec := object.Spec
+	record, err := o.ddnsRecordTarget(ctx, spec)
+	if err != nil {
+		return DDNSReasonInvalid, withCause(CauseConfig, err)
+	}
+
+	var ip string
+	switch spec.Source {
+	case "", DDNSSourceDetected:
+		if ip = o.updater.getState().DetectedIP; ip == "" {
+			return DDNSReasonWaitingForAddress, errors.New("no address detected yet")
+		}
+	case DDNSSourceStatic:
+		parsed := net.ParseIP(strings.TrimSpace(spec.IP))
+		if parsed == nil {
+			return DDNSReasonInvalid, withCause(CauseConfig, fmt.Errorf("%s %w: %q", "static source", ipsource.ErrInvalidIP, spec.IP))
+		}
+		ip = parsed.String()
+	case DDNSSourceURL:
+		if ip, err = ipsource.NewURL(spec.URL, nil).IP(ctx); err != nil {
+			return DDNSReasonSourceFailed, withCause(detectionCause(err), err)
+		}
+	default:
+		return DDNSReasonInvalid, withCause(CauseConfig, fmt.Errorf("%s: %s", "not a valid source", spec.Source))
+	}
+
+	desired := dns.Record{Name: record.fqdn, Type: "A", TTL: spec.TTL, Values: []string{ip}, Routing: "simple"}
+	if net.ParseIP(ip).To4() == nil {
+		desired.Type = "AAAA"
+	}
+	if desired.TTL == 0 {
+		desired.TTL = TTL
+	}
+	if spec.RoutingPolicy == "weighted" {
+		desired.Routing, desired.SetIdentifier, desired.Weight = "weighted", spec.SetIdentifier, spec.Weight
+	}
+
+	// a record moved elsewhere by the spec is deleted from where it was published
+	if status.PublishedFQDN != "" && (status.PublishedFQDN != desired.Name || status.PublishedType != desired.Type ||
+		status.PublishedSetIdentifier != desired.SetIdentifier || status.PublishedProvider != record.provider.Name()) {
+		if err := o.unpublish(ctx, *status); err != nil {
+			return DDNSReasonPublishFailed, err
+		}
+		*status = ddnsRecordStatus{Conditions: status.Conditions}
+	}
+
+	zoneID, err := findZoneID(ctx, record.provider, record.fqdn)
+	if err != nil {
+		return DDNSReasonPublishFailed, err
+	}
+	if status.PublishedIP == ip && status.ObservedGeneration == object.Metadata.Generation && ddnsRecordHolds(ctx, record.provider, zoneID, desired) {
+		return DDNSReasonPublished, nil
+	}
+
+	if o.updater.cfg.DryRun {
+		o.updater.logger.InfoContext(ctx, "dry run, not publishing ddns record", "record", desired.Name, "type", desired.Type, "ip", ip)
+		return DDNSReasonPublished, nil
+	}
+	changeID, err := record.provider.UpsertRecord(ctx, zoneID, desired, "route53ddns operator")
+	if err != nil {
+		return DDNSReasonPublishFailed, withCause(dnsErrorCause(err), err)
+	}
+	o.updater.logger.InfoContext(ctx, "published ddns record", "record", desired.Name, "provider", record.provider.Name(), "zone_id", zoneID,
+		"type", desired.Type, "ip", ip, "change_id", changeID)
+
+	now := o.updater.clock().UTC()
+	status.PublishedIP, status.PublishedFQDN, status.PublishedType = ip, desired.Name, desired.Type
+	status.PublishedSetIdentifier, status.PublishedProvider = desired.SetIdentifier, record.provider.Name()
+	status.ZoneID, status.LastPublished = zoneID, &now
+
+	return DDNSReasonPublished, nil
+}
+
+// ddnsRecordHolds reports whether the provider holds desired, weighted sets are trusted to hold what
+// was published since only the first set of a name is read
+func ddnsRecordHolds(ctx context.Context, provider dns.Provider, zoneID string, desired dns.Record) bool {
+	if desired.SetIdentifier != "" {
+		return true
+	}
+	current, err := provider.GetRecord(ctx, zoneID, desired.Name, desired.Type)
+
+	return err == nil && current != nil && current.TTL == desired.TTL && slices.Equal(current.Values, desired.Values)
+}
+
+// ddnsRecordTarget validates spec and returns the record it names, with its provider
+func (o *ddnsOperator) ddnsRecordTarget(ctx context.Context, spec ddnsRecordSpec) (*dnsRecord, error) {
+	if strings.ContainsAny(spec.FQDN+spec.Provider+spec.Profile, ",:@+") {
+		return nil, fmt.Errorf("%s: %q", "not a valid record", spec.FQDN)
+	}
+	entry := strings.TrimSuffix(strings.ToLower(spec.FQDN), ".")
+	if spec.Provider != "" {
+		entry = spec.Provider + ":" + entry
+	}
+	if spec.Profile != "" {
+		entry += "@" + spec.Profile
+	}
+	parsed, err := parseRecords(entry)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed) != 1 {
+		return nil, fmt.Errorf("%s: %q", "not a valid record", spec.FQDN)
+	}
+	if spec.TTL < 0 {
+		return nil, fmt.Errorf("%s: %d", "ttl must not be negative", spec.TTL)
+	}
+
+	switch spec.RoutingPolicy {
+	case "", "simple":
+	case "weighted":
+		if spec.SetIdentifier == "" || spec.Weight == nil {
+			return nil, errors.New("weighted records need a set identifier and a weight")
+		}
+		if parsed[0].providerName != "" {
+			return nil, errors.New("weighted records are only supported by route53")
+		}
+	default:
+		return nil, fmt.Errorf("%s: %s", "routing policy must be simple or weighted, not", spec.RoutingPolicy)
+	}
+
+	if err := o.updater.setupRecordClients(ctx, parsed, o.updater.cfg.AWS, o.updater.dnsClient); err != nil {
+		return nil, err
+	}
+
+	return parsed[0], nil
+}
+
+// unpublish deletes the record set status says was published, when it still holds the address
+// published
+func (o *ddnsOperator) unpublish(ctx context.Context, status ddnsRecordStatus) error {
+	if status.PublishedFQDN == "" || status.PublishedIP == "" {
+		return nil
+	}
+
+	entry := status.PublishedFQDN
+	if status.PublishedProvider != "" && status.PublishedProvider != ProviderRoute53 {
+		entry = status.PublishedProvider + ":" + entry
+	}
+	parsed, err := parseRecords(entry)
+	if err != nil {
+		return err
+	}
+	if err := o.updater.setupRecordClients(ctx, parsed, o.updater.cfg.AWS, o.updater.dnsClient); err != nil {
+		return err
+	}
+	provider := parsed[0].provider
+
+	zoneID, err := findZoneID(ctx, provider, status.PublishedFQDN)
+	if err != nil {
+		return err
+	}
+	published := dns.Record{Name: status.PublishedFQDN, Type: status.PublishedType, Values: []string{status.PublishedIP}, Routing: "simple"}
+	if status.PublishedSetIdentifier == "" {
+		current, err := provider.GetRecord(ctx, zoneID, status.PublishedFQDN, status.PublishedType)
+		if err != nil {
+			return withCause(dnsErrorCause(err), err)
+		}
+		if current == nil || !slices.Equal(current.Values, published.Values) {
+			return nil
+		}
+		published = *current
+	} else {
+		// a weighted set is deleted as published, it is gone already when that fails for not matching
+		published.Routing, published.SetIdentifier = "weighted", status.PublishedSetIdentifier
+	}
+
+	if o.updater.cfg.DryRun {
+		o.updater.logger.InfoContext(ctx, "dry run, not deleting ddns record", "record", published.Name, "type", published.Type)
+		return nil
+	}
+	if _, err := provider.DeleteRecord(ctx, zoneID, published, "route53ddns operator"); err != nil {
+		return withCause(dnsErrorCause(err), err)
+	}
+	o.updater.logger.InfoContext(ctx, "deleted ddns record", "record", published.Name, "provider", provider.Name(), "type", published.Type)
+
+	return nil
+}
+
+// patchFinalizers replaces the finalizers of object, failing when it changed since it was read
+func (o *ddnsOperator) patchFinalizers(ctx context.Context, object ddnsRecord, finalizers []string) error {
+	metadata := map[string]any{"finalizers": finalizers}
+	if object.Metadata.ResourceVersion != "" {
+		metadata["resourceVersion"] = object.Metadata.ResourceVersion
+	}
+
+	return o.client.Do(ctx, http.MethodPatch, o.resource(object), map[string]any{"metadata": metadata}, nil)
+}
+
+// setDDNSCondition sets the Ready condition of status, keeping its transition time while its status
+// is unchanged
+func (o *ddnsOperator) setDDNSCondition(status *ddnsRecordStatus, generation int64, value, reason, message string) {
+	condition := ddnsCondition{Type: "Ready", Status: value, Reason: reason, Message: message,
+		ObservedGeneration: generation, LastTransitionTime: o.updater.clock().UTC().Truncate(time.Second)}
+	for i, existing := range status.Conditions {
+		if existing.Type != condition.Type {
+			continue
+		}
+		if existing.Status == condition.Status {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		}
+		status.Conditions[i] = condition
+		return
+	}
+	status.Conditions = append(status.Conditions, condition)
+}
+
+// mustJSON returns the json encoding of v
+func mustJSON(v any) string {
+	data, _ := json.Marshal(v)
+
+	return string(data)
+}
+
+// DDNSRecordCRD is the definition of the DDNSRecord resource, printed by the crd command
+const DDNSRecordCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: ddnsrecords.route53ddns.rgravlin.github.io
+spec:
+  group: route53ddns.rgravlin.github.io
+  names:
+    kind: DDNSRecord
+    listKind: DDNSRecordList
+    plural: ddnsrecords
+    singular: ddnsrecord
+    shortNames: [ddns]
+  scope: Namespaced
+  versions:
+    - name: v1alpha1
+      served: true
+      storage: true
+      subresources:
+        status: {}
+      additionalPrinterColumns:
+        - {name: FQDN, type: string, jsonPath: .spec.fqdn}
+        - {name: IP, type: string, jsonPath: .status.publishedIP}
+        - {name: Ready, type: string, jsonPath: '.status.conditions[?(@.type=="Ready")].status'}
+        - {name: Age, type: date, jsonPath: .metadata.creationTimestamp}
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              required: [fqdn]
+              properties:
+                fqdn: {type: string}
+                provider: {type: string}
+                profile: {type: string}
+                source: {type: string, enum: [detected, static, url]}
+                ip: {type: string}
+                url: {type: string}
+                ttl: {type: integer, minimum: 0}
+                routingPolicy: {type: string, enum: [simple, weighted]}
+                setIdentifier: {type: string}
+                weight: {type: integer, minimum: 0, maximum: 255}
+            status:
+              type: object
+              x-kubernetes-preserve-unknown-fields: true
+`
@@ -0,0 +1,115 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"github.com/rgravlin/route53ddns/pkg/dns"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// OwnerMarkerPrefix starts the TXT value marking the instance that owns the record of the same name,
+// followed by the owner id, e.g. "owner=route53ddns/home-gw"
+const OwnerMarkerPrefix = "owner=route53ddns/"
+
+// ownerIDRegex matches the ids instances can be given
+var ownerIDRegex = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// ownershipError is returned for records marked as owned by another instance, which are left alone
+type ownershipError struct {
+	fqdn  string
+	owner string
+}
+
+func (e *ownershipError) Error() string {
+	return fmt.Sprintf("%s %s %s %s", "record", e.fqdn, "is owned by", e.owner)
+}
+
+// ownerMarker returns the TXT value marking the records of the instance id, quoted as route53 holds it
+func ownerMarker(id string) string {
+	return `"` + OwnerMarkerPrefix + id + `"`
+}
+
+// ownedByThisInstance reports whether the TXT value is the marker of this instance, quoted or not as
+// providers other than route53 return TXT values unquoted
+func (u *Updater) ownedByThisInstance(value string) bool {
+	return recordOwner(value) == strings.TrimPrefix(OwnerMarkerPrefix, "owner=")+u.cfg.OwnerID
+}
+
+// recordOwner returns the owner a TXT value marks, route53ddns/id or another tool's, or an empty
+// string when the value is no ownership marker
+func recordOwner(value string) string {
+	owner, found := strings.CutPrefix(strings.Trim(value, `"`), "owner=")
+	if !found {
+		return ""
+	}
+
+	return owner
+}
+
+// checkOwnership returns the TXT record of fqdn holding the ownership markers, failing when another
+// owner marked the record. records nobody marked are claimed once they are changed
+func (u *Updater) checkOwnership(ctx context.Context, provider dns.Provider, zoneID, fqdn string) (*dns.Record, error) {
+	if u.cfg.OwnerID == "" {
+		return nil, nil
+	}
+
+	txt, err := provider.GetRecord(ctx, zoneID, fqdn, "TXT")
+	if err != nil {
+		return nil, withCause(dnsErrorCause(err), err)
+	}
+	if txt == nil {
+		return nil, nil
+	}
+	for _, value := range txt.Values {
+		if owner := recordOwner(value); owner != "" && !u.ownedByThisInstance(value) {
+			return nil, withCause(CauseOwnership, &ownershipError{fqdn: fqdn, owner: owner})
+		}
+	}
+
+	return txt, nil
+}
+
+// claimOwnership adds the marker of this instance to the TXT record of fqdn, keeping its other values,
+// and returns the TXT record it wrote or nil when the record was marked already
+func (u *Updater) claimOwnership(ctx context.Context, provider dns.Provider, zoneID, fqdn string, txt *dns.Record) (*dns.Record, error) {
+	if u.cfg.OwnerID == "" || (txt != nil && slices.ContainsFunc(txt.Values, u.ownedByThisInstance)) {
+		return nil, nil
+	}
+
+	desired := dns.Record{Name: fqdn, Type: "TXT", TTL: TTL, Values: []string{ownerMarker(u.cfg.OwnerID)}, Routing: "simple"}
+	if txt != nil {
+		desired.TTL = txt.TTL
+		desired.Values = append(slices.Clone(txt.Values), ownerMarker(u.cfg.OwnerID))
+	}
+	if _, err := provider.UpsertRecord(ctx, zoneID, desired, "route53ddns ownership"); err != nil {
+		return nil, withCause(dnsErrorCause(err), fmt.Errorf("%s: %w", "unable to mark record as owned", err))
+	}
+	u.logger.InfoContext(ctx, "claimed ownership of record", "record", fqdn, "provider", provider.Name(), "zone_id", zoneID, "owner", u.cfg.OwnerID)
+
+	return &desired, nil
+}
+
+// releaseOwnership removes the marker of this instance from the TXT record of fqdn, deleting the
+// record when nothing else remains in it
+func (u *Updater) releaseOwnership(ctx context.Context, provider dns.Provider, zoneID, fqdn string, txt *dns.Record) error {
+	if u.cfg.OwnerID == "" || txt == nil || !slices.ContainsFunc(txt.Values, u.ownedByThisInstance) {
+		return nil
+	}
+
+	var err error
+	remaining := slices.DeleteFunc(slices.Clone(txt.Values), u.ownedByThisInstance)
+	if len(remaining) == 0 {
+		_, err = provider.DeleteRecord(ctx, zoneID, *txt, "route53ddns ownership")
+	} else {
+		desired := *txt
+		desired.Values = remaining
+		_, err = provider.UpsertRecord(ctx, zoneID, desired, "route53ddns ownership")
+	}
+	if err != nil {
+		return withCause(dnsErrorCause(err), fmt.Errorf("%s: %w", "unable to remove ownership marker", err))
+	}
+
+	return nil
+}
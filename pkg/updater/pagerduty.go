@@ -0,0 +1,66 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// pagerDutyEventsURL is the events api v2 endpoint
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// pagerDutyNotifier triggers an incident when the failure streak crosses the threshold and resolves it
+// on recovery, other events are not paged
+type pagerDutyNotifier struct {
+	client     *http.Client
+	routingKey string
+}
+
+// pagerDutyPayload describes a triggered incident
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Component     string            `json:"component,omitempty"`
+	Class         string            `json:"class,omitempty"`
+	CustomDetails notificationEvent `json:"custom_details"`
+}
+
+// pagerDutyEvent is the body of an events api v2 request
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+// newPagerDutyNotifier requires an integration routing key
+func newPagerDutyNotifier(client *http.Client, routingKey string) (*pagerDutyNotifier, error) {
+	if routingKey == "" {
+		return nil, errors.New("a routing key is required")
+	}
+
+	return &pagerDutyNotifier{client: client, routingKey: routingKey}, nil
+}
+
+func (n *pagerDutyNotifier) name() string {
+	return "pagerduty"
+}
+
+func (n *pagerDutyNotifier) notify(ctx context.Context, event notificationEvent) error {
+	// one incident per record, so a recovery resolves the incident its failure opened
+	pdEvent := pagerDutyEvent{RoutingKey: n.routingKey, DedupKey: "route53ddns/" + event.FQDN}
+
+	switch event.Type {
+	case EventUpdateFailed:
+		pdEvent.EventAction = "trigger"
+		pdEvent.Payload = &pagerDutyPayload{Summary: event.title(), Source: event.FQDN, Severity: "error",
+			Component: "route53ddns", Class: event.Category, CustomDetails: event}
+	case EventRecovered:
+		pdEvent.EventAction = "resolve"
+	default:
+		return nil
+	}
+
+	return postJSON(ctx, n.client, pagerDutyEventsURL, pdEvent, nil)
+}
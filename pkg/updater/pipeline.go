@@ -0,0 +1,260 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rgravlin/route53ddns/pkg/dns"
+	"github.com/rgravlin/route53ddns/pkg/ipsource"
+	"go.opentelemetry.io/otel/attribute"
+	"time"
+)
+
+func (u *Updater) getIPAndUpdate(ctx context.Context) error {
+	u.refreshRecordStates(ctx, u.stateStore, u.recordNames())
+
+	// retrieve current ip address
+	ip, err := u.detectIP(ctx)
+	if err != nil {
+		return err
+	}
+
+	// create or update every record
+	return u.scheduledUpdate(ctx, ip, u.records)
+}
+
+// checkIPAndUpdate compares the detected ip to the cached record and only calls route53 when they differ
+func (u *Updater) checkIPAndUpdate(ctx context.Context) error {
+	u.refreshRecordStates(ctx, u.stateStore, u.recordNames())
+
+	ip, err := u.detectIP(ctx)
+	if err != nil {
+		return err
+	}
+
+	var stale []*dnsRecord
+	for _, record := range u.records {
+		if ip != u.getPublishedIP(record.key) {
+			stale = append(stale, record)
+		}
+	}
+
+	return u.scheduledUpdate(ctx, ip, stale)
+}
+
+// scheduledUpdate updates records like updateRecords, a standby skipping the cycle without failing it
+func (u *Updater) scheduledUpdate(ctx context.Context, ip string, records []*dnsRecord) error {
+	err := u.updateRecords(ctx, ip, records)
+	if errors.Is(err, errNotLeader) {
+		u.logger.Log(ctx, u.steadyStateLevel, "standing by, not updating records", "ip", ip, "records", len(records))
+		return nil
+	}
+
+	return err
+}
+
+// updateRecords points every record at ip, a failing record does not stop the others from being
+// updated and the cycle fails with the category of the first failure. a cycle without failures
+// returns errChangeDeferred when a change was held back
+func (u *Updater) updateRecords(ctx context.Context, ip string, records []*dnsRecord) error {
+	if !u.isLeader() {
+		return errNotLeader
+	}
+
+	var errs []error
+	var deferred bool
+	for _, record := range records {
+		err := u.upsertRecord(ctx, ip, record)
+		if errors.Is(err, errChangeDeferred) {
+			deferred = true
+			continue
+		}
+		if err != nil {
+			errs = append(errs, withCause(ErrorCause(err), fmt.Errorf("%s %s: %w", "could not update record", record.key, err)))
+		}
+	}
+
+	if len(errs) == 0 && deferred {
+		return errChangeDeferred
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return withCause(ErrorCause(errs[0]), errors.Join(errs...))
+}
+
+// detectIP retrieves the current ip address and records it
+func (u *Updater) detectIP(ctx context.Context) (string, error) {
+	ctx, span := startSpan(ctx, "detect_ip", attribute.String("source", u.cfg.IPSource.Name()))
+	start := time.Now()
+	ip, err := u.cfg.IPSource.IP(ctx)
+	u.observeIPSource(time.Since(start), err)
+	u.timePhase(ctx, PhaseIPFetch, start)
+	endSpan(span, err)
+	if err != nil {
+		return "", withCause(detectionCause(err), fmt.Errorf("%s: %w", "unable to determine ip address", err))
+	}
+
+	previous := u.getState().DetectedIP
+	u.setDetectedIP(ip)
+	u.lifecycle.publish(ctx, ipDetectedEvent{IP: ip, PreviousIP: previous})
+	if previous != ip {
+		u.lifecycle.publish(ctx, ipChangedEvent{IP: ip, PreviousIP: previous})
+	}
+
+	return ip, nil
+}
+
+// detectionCause returns the failure category of an ip source error
+func detectionCause(err error) string {
+	var sourceErr *ipsource.Error
+	if !errors.As(err, &sourceErr) {
+		return awsErrorCause(err)
+	}
+
+	switch sourceErr.Kind {
+	case ipsource.KindNetwork:
+		return CauseDetectionNetwork
+	case ipsource.KindInvalidResponse:
+		return CauseDetectionInvalidResponse
+	}
+
+	return CauseConfig
+}
+
+// upsertRecord points record at ip through its provider, unless it already holds ip
+func (u *Updater) upsertRecord(ctx context.Context, ip string, record *dnsRecord) error {
+	fqdn, key, provider := record.fqdn, record.key, record.provider
+
+	// extract domain
+	tokens := domainRegex.FindStringSubmatch(fqdn)
+	if tokens == nil {
+		return withCause(CauseConfig, fmt.Errorf("%s: %s", "hostname has no domain", fqdn))
+	}
+	domain := tokens[2]
+
+	// the zone resolved before, possibly by a previous run, is used until reading the record fails
+	zoneID := u.cachedZoneID(key, provider.Name())
+	if zoneID == "" {
+		spanCtx, span := startSpan(ctx, "zone_lookup", attribute.String("domain", domain), attribute.String("provider", provider.Name()))
+		start := time.Now()
+		var err error
+		zoneID, err = findZoneID(spanCtx, provider, fqdn)
+		u.timePhase(ctx, PhaseZoneLookup, start)
+		endSpan(span, err)
+
+		if err != nil {
+			return err
+		}
+		u.logger.DebugContext(ctx, "resolved hosted zone", "domain", domain, "provider", provider.Name(), "zone_id", zoneID)
+		u.setZoneID(key, provider.Name(), zoneID)
+	}
+
+	// list records
+	spanCtx, span := startSpan(ctx, "record_diff", attribute.String("record", fqdn), attribute.String("zone_id", zoneID))
+	start := time.Now()
+	current, err := provider.GetRecord(spanCtx, zoneID, fqdn, RecordType)
+	u.timePhase(ctx, PhaseRecordList, start)
+	if err != nil {
+		endSpan(span, err)
+		// the zone may have been deleted or the record moved to another, so it is looked up again
+		if dnsErrorCause(err) == CauseAWSNotFound {
+			u.setZoneID(key, "", "")
+		}
+		return withCause(dnsErrorCause(err), fmt.Errorf("%s (%s): %w", "error listing records", domain, err))
+	}
+
+	var oldIP string
+	if current != nil {
+		for _, value := range current.Values {
+			if value == ip {
+				span.SetAttributes(attribute.String("old_ip", ip), attribute.String("new_ip", ip))
+				endSpan(span, nil)
+				u.setPublishedIP(key, ip)
+				u.logger.Log(ctx, u.steadyStateLevel, "already registered", "record", fqdn, "provider", provider.Name(), "zone_id", zoneID, "ip", ip)
+				return nil
+			}
+			oldIP = value
+		}
+	}
+	span.SetAttributes(attribute.String("old_ip", oldIP), attribute.String("new_ip", ip))
+	endSpan(span, nil)
+
+	// records another instance marked as its own are left to it
+	ownership, err := u.checkOwnership(ctx, provider, zoneID, fqdn)
+	if err != nil {
+		return err
+	}
+
+	// the record no longer holds what was last published, so something else changed or removed it
+	if published := u.getPublishedIP(key); published != "" && published != oldIP {
+		u.logger.WarnContext(ctx, "record drifted from published value", "record", fqdn, "provider", provider.Name(), "zone_id", zoneID, "published_ip", published, "record_ip", oldIP)
+		u.lifecycle.publish(ctx, driftDetectedEvent{Record: record, ZoneID: zoneID, RecordIP: oldIP, PublishedIP: published, NewIP: ip})
+	}
+
+	// initialize A record
+	desired := dns.Record{Name: fqdn, Type: RecordType, TTL: TTL, Values: []string{ip}, Routing: "simple"}
+
+	// show the planned change before anything is submitted
+	if u.cfg.DryRun {
+		fmt.Print(formatRecordDiff(zoneID, current, &desired))
+		u.logger.InfoContext(ctx, "dry run, not registering change", "record", fqdn, "provider", provider.Name(), "zone_id", zoneID, "old_ip", oldIP, "new_ip", ip)
+		return nil
+	}
+	u.logger.DebugContext(ctx, "planned change", "record", fqdn, "diff", formatRecordDiff(zoneID, current, &desired))
+
+	// detect but do not publish changes while paused or during maintenance
+	if u.paused.Load() {
+		u.logger.InfoContext(ctx, "updates paused, not registering change", "record", fqdn, "provider", provider.Name(), "zone_id", zoneID, "old_ip", oldIP, "new_ip", ip)
+		return errChangeDeferred
+	}
+	if u.inQuietWindow(u.clock().In(u.scheduler.Location())) {
+		u.logger.InfoContext(ctx, "quiet window active, not registering change", "record", fqdn, "provider", provider.Name(), "zone_id", zoneID, "old_ip", oldIP, "new_ip", ip)
+		return errChangeDeferred
+	}
+
+	// instances sharing the state store submit the change once, whichever claims it first
+	previous := u.getRecordState(key)
+	if !u.claimChange(ctx, key, ip) {
+		u.observeDeduplicatedChange()
+		return nil
+	}
+
+	// a failing pre update hook vetoes the change, which is no longer claimed
+	if err := u.runHook(ctx, "pre-update", u.cfg.Hooks.PreUpdate, fqdn, oldIP, ip, "", "", nil); err != nil {
+		u.restoreRecordState(key, previous)
+		return withCause(CauseHook, err)
+	}
+	claimed, err := u.claimOwnership(ctx, provider, zoneID, fqdn, ownership)
+	if err != nil {
+		u.restoreRecordState(key, previous)
+		return err
+	}
+
+	// attempt change
+	spanCtx, span = startSpan(ctx, "upsert_record", attribute.String("record", fqdn), attribute.String("zone_id", zoneID),
+		attribute.String("provider", provider.Name()))
+	start = time.Now()
+	changeID, err := provider.UpsertRecord(spanCtx, zoneID, desired, "route53ddns run "+runIDFrom(ctx))
+	u.timePhase(ctx, PhaseChangeSubmit, start)
+	endSpan(span, err)
+
+	if err != nil {
+		u.restoreRecordState(key, previous)
+		// a record left unchanged isn't claimed either
+		if err := u.releaseOwnership(ctx, provider, zoneID, fqdn, claimed); err != nil {
+			u.logger.WarnContext(ctx, "unable to release ownership of record", "record", fqdn, "provider", provider.Name(), "zone_id", zoneID, "error", err)
+		}
+		cause := dnsErrorCause(err)
+		u.lifecycle.publish(ctx, updateFailedEvent{Record: record, ZoneID: zoneID, OldIP: oldIP, NewIP: ip, Err: err, Cause: cause})
+		return withCause(cause, fmt.Errorf("%s: %w", "failed to update record set", err))
+	}
+
+	u.setLastChange(key, ip, changeID)
+	u.logger.InfoContext(ctx, "submitted change", "record", fqdn, "provider", provider.Name(), "zone_id", zoneID, "old_ip", oldIP, "new_ip", ip,
+		"change_id", changeID, "duration", time.Since(start).Seconds())
+	u.lifecycle.publish(ctx, updateSucceededEvent{Record: record, ZoneID: zoneID, OldIP: oldIP, NewIP: ip, ChangeID: changeID})
+
+	return nil
+}
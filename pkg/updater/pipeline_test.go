@@ -0,0 +1,260 @@
+package updater
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rgravlin/route53ddns/pkg/dns"
+)
+
+const (
+	testRecord = "home.example.com"
+	testZone   = "FAKEZONE1"
+)
+
+// testNow is the time the pipeline tests run at
+var testNow = time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+// memoryRecordStore is a state store sharing record state between instances in memory
+type memoryRecordStore struct {
+	mu      sync.Mutex
+	data    []byte
+	records map[string]sharedRecord
+}
+
+func (s *memoryRecordStore) Load(context.Context) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.data, nil
+}
+
+func (s *memoryRecordStore) Save(_ context.Context, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data = data
+	return nil
+}
+
+func (s *memoryRecordStore) String() string {
+	return "memory"
+}
+
+func (s *memoryRecordStore) LoadRecords(_ context.Context, names []string) (map[string]sharedRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loaded := map[string]sharedRecord{}
+	for _, name := range names {
+		if record, ok := s.records[name]; ok {
+			loaded[name] = record
+		}
+	}
+
+	return loaded, nil
+}
+
+func (s *memoryRecordStore) SaveRecord(_ context.Context, name string, record sharedRecord) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.records[name].Version != record.Version {
+		return 0, errRecordConflict
+	}
+	record.Version++
+	s.records[name] = record
+
+	return record.Version, nil
+}
+
+// newTestUpdater sets up an updater keeping testRecord in an in-memory route53, with the record
+// holding value when it isn't empty, and returns it with the provider the record is read back through
+func newTestUpdater(t *testing.T, value string, opts ...Option) (*Updater, dns.Provider) {
+	t.Helper()
+
+	fake := dns.NewFakeRoute53("example.com")
+	provider := dns.NewRoute53(fake)
+	if value != "" {
+		record := dns.Record{Name: testRecord, Type: RecordType, TTL: TTL, Values: []string{value}, Routing: "simple"}
+		if _, err := provider.UpsertRecord(context.Background(), testZone, record, "test"); err != nil {
+			t.Fatalf("unable to seed record: %v", err)
+		}
+	}
+
+	opts = append([]Option{
+		WithConfig(Config{Records: []string{testRecord}}),
+		WithRoute53Client(fake),
+		WithClock(func() time.Time { return testNow }),
+		WithScheduler(newGocronScheduler(time.UTC)),
+	}, opts...)
+	u := New(opts...)
+	if err := u.setup(context.Background()); err != nil {
+		t.Fatalf("unable to set up updater: %v", err)
+	}
+
+	return u, provider
+}
+
+// recordEvents returns the events u publishes from now on
+func recordEvents(u *Updater) func() []lifecycleEvent {
+	var mu sync.Mutex
+	var events []lifecycleEvent
+	u.lifecycle.subscribe(func(_ context.Context, event lifecycleEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	})
+
+	return func() []lifecycleEvent {
+		mu.Lock()
+		defer mu.Unlock()
+		return events
+	}
+}
+
+// heldValues returns the values testRecord holds
+func heldValues(t *testing.T, provider dns.Provider) []string {
+	t.Helper()
+
+	current, err := provider.GetRecord(context.Background(), testZone, testRecord, RecordType)
+	if err != nil {
+		t.Fatalf("unable to read record: %v", err)
+	}
+	if current == nil {
+		return nil
+	}
+
+	return current.Values
+}
+
+func TestUpsertRecordAlreadyRegistered(t *testing.T) {
+	u, provider := newTestUpdater(t, "192.0.2.1")
+	events := recordEvents(u)
+
+	if err := u.upsertRecord(context.Background(), "192.0.2.1", u.records[0]); err != nil {
+		t.Fatalf("upsertRecord() error = %v", err)
+	}
+
+	if values := heldValues(t, provider); len(values) != 1 || values[0] != "192.0.2.1" {
+		t.Errorf("record values = %v, want [192.0.2.1]", values)
+	}
+	if got := u.getRecordState(testRecord); got.PublishedIP != "192.0.2.1" || got.LastChangeID != "" {
+		t.Errorf("record state = %+v, want published 192.0.2.1 without a change", got)
+	}
+	if got := events(); len(got) != 0 {
+		t.Errorf("events = %v, want none", got)
+	}
+}
+
+func TestUpsertRecordSubmitsChange(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		value string
+	}{
+		{name: "missing record", value: ""},
+		{name: "stale record", value: "192.0.2.1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			u, provider := newTestUpdater(t, tc.value)
+			events := recordEvents(u)
+
+			if err := u.upsertRecord(context.Background(), "192.0.2.2", u.records[0]); err != nil {
+				t.Fatalf("upsertRecord() error = %v", err)
+			}
+
+			if values := heldValues(t, provider); len(values) != 1 || values[0] != "192.0.2.2" {
+				t.Errorf("record values = %v, want [192.0.2.2]", values)
+			}
+			got := u.getRecordState(testRecord)
+			if got.PublishedIP != "192.0.2.2" || got.LastChangeID == "" || !got.LastChangeTime.Equal(testNow) {
+				t.Errorf("record state = %+v, want a change publishing 192.0.2.2 at %v", got, testNow)
+			}
+			var succeeded bool
+			for _, event := range events() {
+				if e, ok := event.(updateSucceededEvent); ok {
+					succeeded = e.OldIP == tc.value && e.NewIP == "192.0.2.2" && e.ZoneID == testZone
+				}
+			}
+			if !succeeded {
+				t.Errorf("events = %v, want an update from %q to 192.0.2.2", events(), tc.value)
+			}
+		})
+	}
+}
+
+func TestUpsertRecordDetectsDrift(t *testing.T) {
+	u, provider := newTestUpdater(t, "192.0.2.9")
+	u.setPublishedIP(testRecord, "192.0.2.1")
+	events := recordEvents(u)
+
+	if err := u.upsertRecord(context.Background(), "192.0.2.2", u.records[0]); err != nil {
+		t.Fatalf("upsertRecord() error = %v", err)
+	}
+
+	var drift *driftDetectedEvent
+	for _, event := range events() {
+		if e, ok := event.(driftDetectedEvent); ok {
+			drift = &e
+		}
+	}
+	if drift == nil {
+		t.Fatalf("events = %v, want a drift", events())
+	}
+	if drift.RecordIP != "192.0.2.9" || drift.PublishedIP != "192.0.2.1" || drift.NewIP != "192.0.2.2" {
+		t.Errorf("drift = %+v, want record 192.0.2.9, published 192.0.2.1 and new 192.0.2.2", *drift)
+	}
+	if values := heldValues(t, provider); len(values) != 1 || values[0] != "192.0.2.2" {
+		t.Errorf("record values = %v, want the drift corrected to [192.0.2.2]", values)
+	}
+}
+
+func TestUpsertRecordDeduplicatesClaimedChange(t *testing.T) {
+	store := &memoryRecordStore{records: map[string]sharedRecord{
+		testRecord: {
+			State:     recordState{PublishedIP: "192.0.2.2", LastChangeID: "/change/OTHER", LastChangeTime: testNow.Add(-time.Second)},
+			Version:   1,
+			UpdatedBy: "other",
+		},
+	}}
+	u, provider := newTestUpdater(t, "192.0.2.1", WithStateStore(store))
+	events := recordEvents(u)
+
+	if err := u.upsertRecord(context.Background(), "192.0.2.2", u.records[0]); err != nil {
+		t.Fatalf("upsertRecord() error = %v", err)
+	}
+
+	if values := heldValues(t, provider); len(values) != 1 || values[0] != "192.0.2.1" {
+		t.Errorf("record values = %v, want the change left to the other instance", values)
+	}
+	if got := u.getRecordState(testRecord); got.PublishedIP != "192.0.2.2" || got.LastChangeID != "/change/OTHER" {
+		t.Errorf("record state = %+v, want the state of the other instance", got)
+	}
+	for _, event := range events() {
+		if _, ok := event.(updateSucceededEvent); ok {
+			t.Errorf("events = %v, want no update", events())
+		}
+	}
+	if store.records[testRecord].UpdatedBy != "other" {
+		t.Errorf("shared record updated by %q, want other", store.records[testRecord].UpdatedBy)
+	}
+}
+
+func TestUpsertRecordClaimsChange(t *testing.T) {
+	store := &memoryRecordStore{records: map[string]sharedRecord{}}
+	u, provider := newTestUpdater(t, "192.0.2.1", WithStateStore(store))
+
+	if err := u.upsertRecord(context.Background(), "192.0.2.2", u.records[0]); err != nil {
+		t.Fatalf("upsertRecord() error = %v", err)
+	}
+
+	if values := heldValues(t, provider); len(values) != 1 || values[0] != "192.0.2.2" {
+		t.Errorf("record values = %v, want [192.0.2.2]", values)
+	}
+	shared := store.records[testRecord]
+	if shared.State.PublishedIP != "192.0.2.2" || shared.State.LastChangeID == "" || shared.UpdatedBy != stateInstance() {
+		t.Errorf("shared record = %+v, want the submitted change of this instance", shared)
+	}
+}
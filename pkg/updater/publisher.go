@@ -0,0 +1,340 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"github.com/rgravlin/route53ddns/pkg/dns"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// watchRetryDelay is how long watchers wait before listing again after a failure
+const watchRetryDelay = 10 * time.Second
+
+// publishTarget is what a watched object asks to be published, its records pointed at its addresses
+type publishTarget struct {
+	records []*dnsRecord
+	ips     []string
+	// hostnames are published as a cname to the first when there is no ip
+	hostnames []string
+	// detected points the records at the detected address instead of ips and hostnames
+	detected bool
+	ttl      int64
+}
+
+// publishedSet is a record set a publisher published, with the record it belongs to
+type publishedSet struct {
+	record *dnsRecord
+	set    dns.Record
+}
+
+// targetPublisher keeps the records asked for by watched objects published, like external-dns does,
+// and deletes them again when the objects go away
+type targetPublisher struct {
+	// source names where the targets come from in logs and change comments
+	source string
+	// updater publishes the targets, the records it updates itself are left alone
+	updater *Updater
+
+	mu sync.Mutex
+	// targets are the watched objects asking for records by key
+	targets map[string]publishTarget
+	// published are the record sets last published by record key and type
+	published map[string]publishedSet
+	// zones caches the zone holding each record by record key, so a sync only resolves zones once
+	zones map[string]string
+}
+
+// newTargetPublisher returns a publisher of the targets of source
+func (u *Updater) newTargetPublisher(source string) *targetPublisher {
+	p := &targetPublisher{source: source, updater: u, targets: map[string]publishTarget{}, published: map[string]publishedSet{}, zones: map[string]string{}}
+
+	u.targetPublishersMu.Lock()
+	u.targetPublishers = append(u.targetPublishers, p)
+	u.targetPublishersMu.Unlock()
+
+	return p
+}
+
+// resyncPublishers checks what every publisher asks for against the provider and publishes it,
+// deleting the sets the previous leader published for targets that have since gone away
+func (u *Updater) resyncPublishers(ctx context.Context) {
+	u.targetPublishersMu.Lock()
+	publishers := slices.Clone(u.targetPublishers)
+	u.targetPublishersMu.Unlock()
+
+	for _, p := range publishers {
+		p.resync(ctx)
+	}
+}
+
+// newPublishTarget returns a target publishing the records of value, in the syntax of the records
+// variable, with ttl in seconds or the default ttl when empty
+func (u *Updater) newPublishTarget(value, ttl string) (*publishTarget, error) {
+	target := &publishTarget{ttl: TTL}
+	if ttl != "" {
+		seconds, err := strconv.ParseInt(ttl, 10, 64)
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("%s: %q", "not a valid ttl", ttl)
+		}
+		target.ttl = seconds
+	}
+
+	parsed, err := parseRecords(value)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.setupRecordClients(context.Background(), parsed, u.cfg.AWS, u.dnsClient); err != nil {
+		return nil, err
+	}
+	target.records = parsed
+
+	return target, nil
+}
+
+// set replaces the target of key, a nil target removes it
+func (p *targetPublisher) set(key string, target *publishTarget) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if target == nil {
+		delete(p.targets, key)
+		return
+	}
+	p.targets[key] = *target
+}
+
+// replace replaces the targets whose keys start with prefix
+func (p *targetPublisher) replace(prefix string, targets map[string]*publishTarget) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key := range p.targets {
+		if strings.HasPrefix(key, prefix) {
+			delete(p.targets, key)
+		}
+	}
+	for key, target := range targets {
+		if target != nil {
+			p.targets[key] = *target
+		}
+	}
+}
+
+// desired returns the record sets the targets ask for by record key and type, the addresses of
+// targets sharing a record are merged and records the daemon updates itself are left alone
+func (p *targetPublisher) desired() map[string]publishedSet {
+	keys := make([]string, 0, len(p.targets))
+	for key := range p.targets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	managed := p.updater.recordNames()
+	for _, record := range p.updater.dyndnsRecords {
+		managed = append(managed, record.key)
+	}
+
+	desired := map[string]publishedSet{}
+	for _, key := range keys {
+		target := p.targets[key]
+		ips, hostnames := target.ips, target.hostnames
+		if target.detected {
+			ips, hostnames = nil, nil
+			if detected := p.updater.getState().DetectedIP; detected != "" {
+				ips = []string{detected}
+			}
+		}
+
+		sets := map[string][]string{}
+		for _, ip := range ips {
+			if net.ParseIP(ip).To4() != nil {
+				sets["A"] = append(sets["A"], ip)
+			} else {
+				sets["AAAA"] = append(sets["AAAA"], ip)
+			}
+		}
+		if len(ips) == 0 && len(hostnames) > 0 {
+			sets["CNAME"] = hostnames[:1]
+		}
+
+		for _, record := range target.records {
+			if containsString(managed, record.key) {
+				p.updater.logger.Warn("record is updated by the daemon, not publishing it", "source", p.source, "record", record.key, "object", key)
+				continue
+			}
+
+			for recordType, values := range sets {
+				setKey := record.key + "/" + recordType
+				existing, ok := desired[setKey]
+				if ok && recordType == "CNAME" {
+					p.updater.logger.Warn("record is claimed by several objects, keeping the first", "source", p.source, "record", record.key, "object", key)
+					continue
+				}
+				if !ok {
+					existing = publishedSet{record: record, set: dns.Record{Name: record.fqdn, Type: recordType, TTL: target.ttl, Routing: "simple"}}
+				}
+				for _, value := range values {
+					if !containsString(existing.set.Values, value) {
+						existing.set.Values = append(existing.set.Values, value)
+					}
+				}
+				sort.Strings(existing.set.Values)
+				desired[setKey] = existing
+			}
+		}
+	}
+
+	return desired
+}
+
+// sync publishes the record sets the targets ask for and deletes those no longer asked for, deletes
+// come first so a name can change between an address and a cname. a standby publishes nothing, it
+// remembers every set the leader publishes so they are checked once it is elected
+func (p *targetPublisher) sync(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.updater.isLeader() {
+		// sets no longer asked for are kept, the leader may not have deleted them yet
+		for key, wanted := range p.desired() {
+			p.published[key] = wanted
+		}
+		return
+	}
+	p.publish(ctx)
+}
+
+// resync publishes like sync, checking every set asked for against the provider instead of trusting
+// what was last published
+func (p *targetPublisher) resync(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key := range p.desired() {
+		delete(p.published, key)
+	}
+	p.publish(ctx)
+}
+
+// publish brings the provider in line with the targets, the caller holding mu
+func (p *targetPublisher) publish(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, p.updater.cfg.CycleTimeout)
+	defer cancel()
+
+	desired := p.desired()
+	for key, published := range p.published {
+		if _, ok := desired[key]; ok {
+			continue
+		}
+		if err := p.delete(ctx, published); err != nil {
+			p.updater.logger.Error("unable to delete record", "source", p.source, "record", published.record.key, "type", published.set.Type,
+				"error_category", ErrorCause(err), "error", err)
+			continue
+		}
+		delete(p.published, key)
+	}
+
+	for key, wanted := range desired {
+		if published, ok := p.published[key]; ok && published.set.TTL == wanted.set.TTL && slices.Equal(published.set.Values, wanted.set.Values) {
+			continue
+		}
+		if err := p.upsert(ctx, wanted); err != nil {
+			p.updater.logger.Error("unable to publish record", "source", p.source, "record", wanted.record.key, "type", wanted.set.Type,
+				"error_category", ErrorCause(err), "error", err)
+			continue
+		}
+		p.published[key] = wanted
+	}
+}
+
+// upsert publishes a record set unless the provider already holds it
+func (p *targetPublisher) upsert(ctx context.Context, wanted publishedSet) error {
+	provider := wanted.record.provider
+	zoneID, err := p.zoneID(ctx, wanted.record)
+	if err != nil {
+		return err
+	}
+	current, err := provider.GetRecord(ctx, zoneID, wanted.record.fqdn, wanted.set.Type)
+	if err != nil {
+		return withCause(dnsErrorCause(err), err)
+	}
+	if current != nil && current.TTL == wanted.set.TTL && slices.Equal(sortedValues(current.Values), wanted.set.Values) {
+		return nil
+	}
+
+	if p.updater.cfg.DryRun {
+		p.updater.logger.InfoContext(ctx, "dry run, not publishing record", "source", p.source, "record", wanted.record.fqdn, "type", wanted.set.Type, "values", wanted.set.Values)
+		return nil
+	}
+	changeID, err := provider.UpsertRecord(ctx, zoneID, wanted.set, "route53ddns "+p.source)
+	if err != nil {
+		return withCause(dnsErrorCause(err), err)
+	}
+	p.updater.logger.InfoContext(ctx, "published record", "source", p.source, "record", wanted.record.fqdn, "provider", provider.Name(),
+		"type", wanted.set.Type, "values", wanted.set.Values, "change_id", changeID)
+
+	return nil
+}
+
+// delete deletes a record set the publisher published when it still holds what was published, a
+// record something else has since changed is left alone
+func (p *targetPublisher) delete(ctx context.Context, published publishedSet) error {
+	provider := published.record.provider
+	zoneID, err := p.zoneID(ctx, published.record)
+	if err != nil {
+		return err
+	}
+	current, err := provider.GetRecord(ctx, zoneID, published.record.fqdn, published.set.Type)
+	if err != nil {
+		return withCause(dnsErrorCause(err), err)
+	}
+	if current == nil {
+		return nil
+	}
+	if !slices.Equal(sortedValues(current.Values), published.set.Values) {
+		p.updater.logger.WarnContext(ctx, "record changed since it was published, not deleting it", "source", p.source, "record", published.record.fqdn, "type", published.set.Type)
+		return nil
+	}
+
+	if p.updater.cfg.DryRun {
+		p.updater.logger.InfoContext(ctx, "dry run, not deleting record", "source", p.source, "record", published.record.fqdn, "type", published.set.Type)
+		return nil
+	}
+	changeID, err := provider.DeleteRecord(ctx, zoneID, *current, "route53ddns "+p.source)
+	if err != nil {
+		return withCause(dnsErrorCause(err), err)
+	}
+	p.updater.logger.InfoContext(ctx, "deleted record", "source", p.source, "record", published.record.fqdn, "provider", provider.Name(),
+		"type", published.set.Type, "change_id", changeID)
+
+	return nil
+}
+
+// zoneID returns the id of the zone holding record, resolved once per record
+func (p *targetPublisher) zoneID(ctx context.Context, record *dnsRecord) (string, error) {
+	if zoneID, ok := p.zones[record.key]; ok {
+		return zoneID, nil
+	}
+
+	zoneID, err := findZoneID(ctx, record.provider, record.fqdn)
+	if err != nil {
+		return "", err
+	}
+	p.zones[record.key] = zoneID
+
+	return zoneID, nil
+}
+
+// sortedValues returns a sorted copy of values
+func sortedValues(values []string) []string {
+	sorted := append([]string{}, values...)
+	sort.Strings(sorted)
+
+	return sorted
+}
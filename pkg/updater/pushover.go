@@ -0,0 +1,72 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// pushoverMessagesURL is the message api endpoint
+const pushoverMessagesURL = "https://api.pushover.net/1/messages.json"
+
+// emergency priority pushes repeat every retry seconds until acknowledged or expired
+const (
+	pushoverEmergency = 2
+	pushoverRetry     = 60
+	pushoverExpire    = 3600
+)
+
+// pushoverNotifier sends events as pushover push notifications
+type pushoverNotifier struct {
+	client   *http.Client
+	token    string
+	user     string
+	priority int
+}
+
+// pushoverMessage is the body of a message api request
+type pushoverMessage struct {
+	Token     string `json:"token"`
+	User      string `json:"user"`
+	Title     string `json:"title"`
+	Message   string `json:"message"`
+	Priority  int    `json:"priority"`
+	Retry     int    `json:"retry,omitempty"`
+	Expire    int    `json:"expire,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// PushoverConfig is the application and user notifications are pushed with
+type PushoverConfig struct {
+	Token string
+	User  string
+	// Priority ranges from -2 to 2, emergency pushes repeat until acknowledged
+	Priority int
+}
+
+// newPushoverNotifier requires the application token and user key, priority ranges from -2 to 2
+func newPushoverNotifier(client *http.Client, token, user string, priority int) (*pushoverNotifier, error) {
+	if token == "" || user == "" {
+		return nil, errors.New("both an application token and a user key are required")
+	}
+	if priority < -2 || priority > pushoverEmergency {
+		return nil, fmt.Errorf("%s: %d", "priority must be between -2 and 2", priority)
+	}
+
+	return &pushoverNotifier{client: client, token: token, user: user, priority: priority}, nil
+}
+
+func (n *pushoverNotifier) name() string {
+	return "pushover"
+}
+
+func (n *pushoverNotifier) notify(ctx context.Context, event notificationEvent) error {
+	message := pushoverMessage{Token: n.token, User: n.user, Title: event.title(), Message: event.message(),
+		Priority: n.priority, Timestamp: event.Time.Unix()}
+	if n.priority == pushoverEmergency {
+		message.Retry, message.Expire = pushoverRetry, pushoverExpire
+	}
+
+	return postJSON(ctx, n.client, pushoverMessagesURL, message, nil)
+}
@@ -0,0 +1,80 @@
+package updater
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// quietWindow is a daily time range, in minutes since midnight, during which no route53 changes are made
+type quietWindow struct {
+	start int
+	end   int
+}
+
+// parseQuietWindows parses a comma separated list of HH:MM-HH:MM ranges, a range whose end is before
+// its start wraps past midnight and a range ending when it starts is refused
+func parseQuietWindows(value string) ([]quietWindow, error) {
+	var windows []quietWindow
+
+	for _, token := range strings.Split(value, ",") {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+
+		bounds := strings.Split(token, "-")
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("%s: %s", "quiet window must be formatted as HH:MM-HH:MM", token)
+		}
+
+		start, err := parseClock(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+
+		end, err := parseClock(bounds[1])
+		if err != nil {
+			return nil, err
+		}
+
+		if start == end {
+			return nil, fmt.Errorf("%s: %s", "quiet window must not start and end at the same time", token)
+		}
+
+		windows = append(windows, quietWindow{start: start, end: end})
+	}
+
+	return windows, nil
+}
+
+// parseClock converts HH:MM into minutes since midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %s", "not a valid HH:MM time", value)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains reports whether the wall clock time of t falls within the window
+func (w quietWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.start <= w.end {
+		return minute >= w.start && minute < w.end
+	}
+
+	return minute >= w.start || minute < w.end
+}
+
+// inQuietWindow reports whether t falls within any configured quiet window
+func (u *Updater) inQuietWindow(t time.Time) bool {
+	for _, window := range u.quietWindows {
+		if window.contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
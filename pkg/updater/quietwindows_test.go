@@ -0,0 +1,73 @@
+package updater
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseQuietWindows(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		value   string
+		want    []quietWindow
+		wantErr bool
+	}{
+		{name: "empty", value: ""},
+		{name: "single window", value: "02:00-04:30", want: []quietWindow{{start: 120, end: 270}}},
+		{name: "wraps past midnight", value: "23:00-01:00", want: []quietWindow{{start: 1380, end: 60}}},
+		{
+			name:  "several windows",
+			value: " 02:00 - 03:00 ,, 12:15-12:45",
+			want:  []quietWindow{{start: 120, end: 180}, {start: 735, end: 765}},
+		},
+		{name: "missing end", value: "02:00", wantErr: true},
+		{name: "too many bounds", value: "02:00-03:00-04:00", wantErr: true},
+		{name: "not a time", value: "2am-3am", wantErr: true},
+		{name: "out of range", value: "24:00-01:00", wantErr: true},
+		{name: "empty window", value: "05:00-05:00", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			windows, err := parseQuietWindows(tc.value)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("parseQuietWindows(%q) = %v, want an error", tc.value, windows)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseQuietWindows(%q) error = %v", tc.value, err)
+			}
+			if !slices.Equal(windows, tc.want) {
+				t.Errorf("parseQuietWindows(%q) = %v, want %v", tc.value, windows, tc.want)
+			}
+		})
+	}
+}
+
+func TestQuietWindowContains(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 10, 15, hour, minute, 0, 0, time.UTC)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		window quietWindow
+		t      time.Time
+		want   bool
+	}{
+		{name: "before", window: quietWindow{start: 120, end: 240}, t: at(1, 59), want: false},
+		{name: "at start", window: quietWindow{start: 120, end: 240}, t: at(2, 0), want: true},
+		{name: "inside", window: quietWindow{start: 120, end: 240}, t: at(3, 0), want: true},
+		{name: "at end", window: quietWindow{start: 120, end: 240}, t: at(4, 0), want: false},
+		{name: "wrapped before midnight", window: quietWindow{start: 1380, end: 60}, t: at(23, 30), want: true},
+		{name: "wrapped after midnight", window: quietWindow{start: 1380, end: 60}, t: at(0, 30), want: true},
+		{name: "outside wrapped", window: quietWindow{start: 1380, end: 60}, t: at(12, 0), want: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.window.contains(tc.t); got != tc.want {
+				t.Errorf("contains(%v) = %v, want %v", tc.t.Format("15:04"), got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/rgravlin/route53ddns/pkg/dns"
+	"slices"
+	"strings"
+)
+
+// dnsRecord is a record kept pointed at the detected address, with the client for the account
+// holding its zone
+type dnsRecord struct {
+	fqdn string
+	// key is the name the record's state is kept under, the fqdn for the first provider a record is
+	// published to and the provider and fqdn for the others it is fanned out to
+	key string
+	// providerName names the provider hosting the record's zone, route53 when empty
+	providerName string
+	// profile is the shared config profile the record's credentials come from, the daemon's own
+	// credentials are used when empty
+	profile string
+	// cfg is the aws configuration the record's client was created from
+	cfg    aws.Config
+	client *route53.Client
+	// provider submits the record's changes
+	provider dns.Provider
+}
+
+// parseRecords parses comma separated records, each a hostname optionally prefixed with the providers
+// hosting its zone joined by + and a colon, and for route53 optionally followed by @ and the shared
+// config profile whose credentials update it, e.g.
+// home.example.com,office.example.com@work,cloudflare:blog.example.org,route53+cloudflare:www.example.net,
+// a record published to several providers is parsed into a record per provider
+func parseRecords(value string) ([]*dnsRecord, error) {
+	var parsed []*dnsRecord
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		providerNames := []string{ProviderRoute53}
+		if prefix, rest, found := strings.Cut(entry, ":"); found {
+			providerNames, entry = strings.Split(strings.ToLower(prefix), "+"), rest
+		}
+		name, profile, _ := strings.Cut(entry, "@")
+		if name == "" || (strings.Contains(entry, "@") && profile == "") {
+			return nil, fmt.Errorf("%s: %q", "not a valid record", entry)
+		}
+		if profile != "" && !containsString(providerNames, ProviderRoute53) {
+			return nil, fmt.Errorf("%s: %q", "profiles only apply to route53 records", entry)
+		}
+		if domainRegex.FindStringSubmatch(name) == nil {
+			return nil, fmt.Errorf("%s: %s", "hostname has no domain", name)
+		}
+
+		for i, providerName := range providerNames {
+			if providerName == "" || containsString(providerNames[:i], providerName) {
+				return nil, fmt.Errorf("%s: %q", "not a valid list of providers", entry)
+			}
+
+			record := &dnsRecord{fqdn: name, key: name}
+			if i > 0 {
+				record.key = providerName + ":" + name
+			}
+			if providerName == ProviderRoute53 {
+				record.profile = profile
+			} else {
+				record.providerName = providerName
+			}
+			parsed = append(parsed, record)
+		}
+	}
+
+	return parsed, nil
+}
+
+// RecordEntry is a record as it is configured, before a provider is given to it
+type RecordEntry struct {
+	FQDN string
+	// Provider names the provider hosting the record's zone
+	Provider string
+	// Profile is the shared config profile whose credentials update a route53 record, the default
+	// credentials when empty
+	Profile string
+}
+
+// ParseRecords parses entries in the syntax of Config.Records, a record published to several
+// providers is parsed into an entry per provider
+func ParseRecords(entries []string) ([]RecordEntry, error) {
+	parsed, err := parseRecords(strings.Join(entries, ","))
+	if err != nil {
+		return nil, err
+	}
+
+	var parsedEntries []RecordEntry
+	for _, record := range parsed {
+		provider := record.providerName
+		if provider == "" {
+			provider = ProviderRoute53
+		}
+		parsedEntries = append(parsedEntries, RecordEntry{FQDN: record.fqdn, Provider: provider, Profile: record.profile})
+	}
+
+	return parsedEntries, nil
+}
+
+// newRecords parses entries, each a record name optionally followed by @ and the aws profile of the
+// account holding its zone, refusing records listed more than once
+func newRecords(entries []string) ([]*dnsRecord, error) {
+	parsed, err := parseRecords(strings.Join(entries, ","))
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed) == 0 {
+		return nil, errors.New("no records configured")
+	}
+
+	seen := map[string]bool{}
+	for _, record := range parsed {
+		if seen[record.key] {
+			return nil, fmt.Errorf("%s: %s", "record is listed more than once", record.key)
+		}
+		seen[record.key] = true
+	}
+
+	return parsed, nil
+}
+
+// reloadRecords replaces the records with those of entries, between cycles so a cycle never sees
+// records change under it
+func (u *Updater) reloadRecords(ctx context.Context, entries []string) error {
+	reloaded, err := newRecords(entries)
+	if err != nil {
+		return err
+	}
+	if err := u.setupRecordClients(ctx, reloaded, u.cfg.AWS, u.dnsClient); err != nil {
+		return err
+	}
+	if u.cfg.VerifyPermissions {
+		if err := u.verifyPermissions(ctx, reloaded); err != nil {
+			return err
+		}
+	}
+
+	u.cycleMu.Lock()
+	defer u.cycleMu.Unlock()
+	u.records = reloaded
+	u.fqdn = u.records[0].fqdn
+
+	return nil
+}
+
+// newRoute53Client creates a route53 client from cfg following the configured retry policy
+func (u *Updater) newRoute53Client(cfg aws.Config) *route53.Client {
+	return dns.NewRoute53Client(cfg, u.cfg.Retry)
+}
+
+// setupRecordClients gives every record of records its provider, route53 records get a route53
+// client, records without a profile share the daemon's client and records sharing a profile share a
+// client
+func (u *Updater) setupRecordClients(ctx context.Context, records []*dnsRecord, defaultCfg aws.Config, defaultClient dns.Route53API) error {
+	configs := map[string]aws.Config{"": defaultCfg}
+	clients := map[string]dns.Route53API{"": defaultClient}
+	for _, record := range records {
+		if record.providerName != "" {
+			provider, ok := u.cfg.Providers[record.providerName]
+			if !ok {
+				return fmt.Errorf("%s %s: %s", "no provider configured for record", record.fqdn, record.providerName)
+			}
+			record.provider = provider
+			continue
+		}
+
+		client, ok := clients[record.profile]
+		if !ok {
+			options := append(slices.Clone(u.cfg.AWSOptions), config.WithSharedConfigProfile(record.profile))
+			cfg, err := config.LoadDefaultConfig(ctx, options...)
+			if err != nil {
+				return fmt.Errorf("%s %s: %w", "unable to load aws profile", record.profile, err)
+			}
+			u.instrumentAWSConfig(&cfg)
+
+			client = u.newRoute53Client(cfg)
+			configs[record.profile] = cfg
+			clients[record.profile] = client
+		}
+		record.cfg = configs[record.profile]
+		// permissions and dnssec are only checked with real route53 clients
+		record.client, _ = client.(*route53.Client)
+		record.provider = dns.NewRoute53(client)
+	}
+
+	return nil
+}
+
+// findZoneID returns the id of the zone holding the record named fqdn
+func findZoneID(ctx context.Context, provider dns.Provider, fqdn string) (string, error) {
+	zoneID, err := provider.ResolveZone(ctx, fqdn)
+	if err != nil {
+		return "", withCause(zoneLookupCause(err), err)
+	}
+
+	return zoneID, nil
+}
+
+// zoneLookupCause returns the failure category of a zone lookup error
+func zoneLookupCause(err error) string {
+	if errors.Is(err, dns.ErrZoneNotFound) {
+		return CauseAWSNotFound
+	}
+
+	return dnsErrorCause(err)
+}
+
+// recordNames returns the names the state of every record is kept under
+func (u *Updater) recordNames() []string {
+	names := make([]string, 0, len(u.records))
+	for _, record := range u.records {
+		names = append(names, record.key)
+	}
+
+	return names
+}
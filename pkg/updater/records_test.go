@@ -0,0 +1,96 @@
+package updater
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseRecords(t *testing.T) {
+	// parsedRecord is what a parsed record is compared by
+	type parsedRecord struct {
+		fqdn, key, provider, profile string
+	}
+
+	for _, tc := range []struct {
+		name    string
+		value   string
+		want    []parsedRecord
+		wantErr bool
+	}{
+		{name: "empty", value: ""},
+		{name: "blank entries", value: " , ,"},
+		{
+			name:  "route53 record",
+			value: "home.example.com",
+			want:  []parsedRecord{{fqdn: "home.example.com", key: "home.example.com"}},
+		},
+		{
+			name:  "several records",
+			value: "home.example.com, office.example.com",
+			want: []parsedRecord{
+				{fqdn: "home.example.com", key: "home.example.com"},
+				{fqdn: "office.example.com", key: "office.example.com"},
+			},
+		},
+		{
+			name:  "profile",
+			value: "office.example.com@work",
+			want:  []parsedRecord{{fqdn: "office.example.com", key: "office.example.com", profile: "work"}},
+		},
+		{
+			name:  "other provider",
+			value: "Cloudflare:blog.example.org",
+			want:  []parsedRecord{{fqdn: "blog.example.org", key: "blog.example.org", provider: "cloudflare"}},
+		},
+		{
+			name:  "several providers",
+			value: "route53+cloudflare:www.example.net@work",
+			want: []parsedRecord{
+				{fqdn: "www.example.net", key: "www.example.net", profile: "work"},
+				{fqdn: "www.example.net", key: "cloudflare:www.example.net", provider: "cloudflare"},
+			},
+		},
+		{name: "no domain", value: "localhost", wantErr: true},
+		{name: "no name", value: "@work", wantErr: true},
+		{name: "empty profile", value: "home.example.com@", wantErr: true},
+		{name: "profile without route53", value: "cloudflare:blog.example.org@work", wantErr: true},
+		{name: "empty provider", value: "route53+:home.example.com", wantErr: true},
+		{name: "repeated provider", value: "cloudflare+cloudflare:blog.example.org", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			records, err := parseRecords(tc.value)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("parseRecords(%q) = %v, want an error", tc.value, records)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRecords(%q) error = %v", tc.value, err)
+			}
+
+			var got []parsedRecord
+			for _, record := range records {
+				got = append(got, parsedRecord{fqdn: record.fqdn, key: record.key, provider: record.providerName, profile: record.profile})
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("parseRecords(%q) = %+v, want %+v", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseRecordsNamesProvider(t *testing.T) {
+	entries, err := ParseRecords([]string{"home.example.com@work", "cloudflare:blog.example.org"})
+	if err != nil {
+		t.Fatalf("ParseRecords() error = %v", err)
+	}
+
+	want := []RecordEntry{
+		{FQDN: "home.example.com", Provider: ProviderRoute53, Profile: "work"},
+		{FQDN: "blog.example.org", Provider: "cloudflare"},
+	}
+	if !slices.Equal(entries, want) {
+		t.Errorf("ParseRecords() = %+v, want %+v", entries, want)
+	}
+}
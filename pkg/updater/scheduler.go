@@ -0,0 +1,280 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-co-op/gocron"
+	"github.com/robfig/cron/v3"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// schedulers jobs can run on
+const (
+	SchedulerGocron = "gocron"
+	SchedulerTicker = "ticker"
+)
+
+// Scheduler runs named jobs on an interval or cron schedule and on demand, so event sources such as
+// signals can run a job the same way its schedule does. a job never overlaps with itself
+type Scheduler interface {
+	// Every runs job every interval, the first run happens right away unless wait is set
+	Every(name string, interval time.Duration, wait bool, job func()) error
+	// Cron runs job at the times matched by a five field cron expression
+	Cron(name, expression string, job func()) error
+	// Trigger runs the job named name now, outside of its schedule
+	Trigger(name string) error
+	// Start starts running jobs in the background
+	Start()
+	// Stop stops scheduling jobs
+	Stop()
+	// IsRunning reports whether jobs are being run
+	IsRunning() bool
+	// NextRun returns the earliest time a job is scheduled to run, or the zero time when none is
+	NextRun() time.Time
+	// Location is the time zone schedules are evaluated in
+	Location() *time.Location
+}
+
+// newScheduler returns the scheduler named kind evaluating schedules in location
+func newScheduler(kind string, location *time.Location, logger *slog.Logger) (Scheduler, error) {
+	switch kind {
+	case "", SchedulerGocron:
+		return newGocronScheduler(location), nil
+	case SchedulerTicker:
+		return newTickerScheduler(location, logger), nil
+	}
+
+	return nil, fmt.Errorf("%s: %s", "unknown scheduler", kind)
+}
+
+// gocronScheduler runs jobs on a gocron scheduler, tagging each with its name
+type gocronScheduler struct {
+	s *gocron.Scheduler
+}
+
+// newGocronScheduler returns a gocron scheduler skipping a tick while the previous run of the job is
+// still in flight
+func newGocronScheduler(location *time.Location) *gocronScheduler {
+	s := gocron.NewScheduler(location)
+	s.SingletonModeAll()
+
+	return &gocronScheduler{s: s}
+}
+
+func (g *gocronScheduler) Every(name string, interval time.Duration, wait bool, job func()) error {
+	s := g.s.Every(interval).Tag(name)
+	if wait {
+		s = s.WaitForSchedule()
+	}
+	_, err := s.Do(job)
+
+	return err
+}
+
+func (g *gocronScheduler) Cron(name, expression string, job func()) error {
+	_, err := g.s.Cron(expression).Tag(name).Do(job)
+	return err
+}
+
+func (g *gocronScheduler) Trigger(name string) error {
+	return g.s.RunByTag(name)
+}
+
+func (g *gocronScheduler) Start() {
+	g.s.StartAsync()
+}
+
+func (g *gocronScheduler) Stop() {
+	g.s.Stop()
+}
+
+func (g *gocronScheduler) IsRunning() bool {
+	return g.s.IsRunning()
+}
+
+func (g *gocronScheduler) NextRun() time.Time {
+	var earliest time.Time
+	for _, job := range g.s.Jobs() {
+		next := job.NextRun()
+		if next.IsZero() {
+			continue
+		}
+		if earliest.IsZero() || next.Before(earliest) {
+			earliest = next
+		}
+	}
+
+	return earliest
+}
+
+func (g *gocronScheduler) Location() *time.Location {
+	return g.s.Location()
+}
+
+// tickerJob is a job of the ticker scheduler
+type tickerJob struct {
+	name string
+	run  func()
+	// next returns when the job runs after t
+	next func(t time.Time) time.Time
+	wait bool
+	// trigger wakes the job up to run outside of its schedule
+	trigger chan struct{}
+	mu      sync.Mutex
+	nextRun time.Time
+}
+
+// tickerScheduler runs every job in a goroutine of its own sleeping until the job's next run, jobs
+// stop as soon as the scheduler does instead of at their next tick
+type tickerScheduler struct {
+	location *time.Location
+	logger   *slog.Logger
+	mu       sync.Mutex
+	jobs     map[string]*tickerJob
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// newTickerScheduler returns a ticker scheduler evaluating cron expressions in location
+func newTickerScheduler(location *time.Location, logger *slog.Logger) *tickerScheduler {
+	return &tickerScheduler{location: location, logger: logger, jobs: map[string]*tickerJob{}}
+}
+
+func (t *tickerScheduler) Every(name string, interval time.Duration, wait bool, job func()) error {
+	if interval <= 0 {
+		return fmt.Errorf("%s: %s", "interval must be positive", interval)
+	}
+
+	return t.add(&tickerJob{name: name, run: job, wait: wait, next: func(now time.Time) time.Time {
+		return now.Add(interval)
+	}})
+}
+
+func (t *tickerScheduler) Cron(name, expression string, job func()) error {
+	schedule, err := cron.ParseStandard(expression)
+	if err != nil {
+		return fmt.Errorf("%s %q: %w", "not a valid cron expression", expression, err)
+	}
+
+	return t.add(&tickerJob{name: name, run: job, wait: true, next: func(now time.Time) time.Time {
+		return schedule.Next(now.In(t.location))
+	}})
+}
+
+// add registers job, jobs are added before the scheduler starts
+func (t *tickerScheduler) add(job *tickerJob) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.jobs[job.name]; ok {
+		return fmt.Errorf("%s: %s", "job is already scheduled", job.name)
+	}
+	if t.stop != nil {
+		return errors.New("jobs can't be added to a running scheduler")
+	}
+	job.trigger = make(chan struct{}, 1)
+	t.jobs[job.name] = job
+
+	return nil
+}
+
+func (t *tickerScheduler) Trigger(name string) error {
+	t.mu.Lock()
+	job, ok := t.jobs[name]
+	t.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%s: %s", "no job scheduled", name)
+	}
+
+	// a trigger already pending covers this one
+	select {
+	case job.trigger <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (t *tickerScheduler) Start() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stop != nil {
+		return
+	}
+
+	t.stop = make(chan struct{})
+	for _, job := range t.jobs {
+		t.wg.Add(1)
+		go t.loop(job, t.stop)
+	}
+}
+
+// loop runs job on its schedule and when triggered until stop is closed
+func (t *tickerScheduler) loop(job *tickerJob, stop chan struct{}) {
+	defer t.wg.Done()
+
+	if !job.wait {
+		job.run()
+	}
+	for {
+		next := job.next(time.Now())
+		job.mu.Lock()
+		job.nextRun = next
+		job.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-job.trigger:
+			timer.Stop()
+			t.logger.Debug("job triggered", "job", job.name)
+		case <-timer.C:
+		}
+		job.run()
+	}
+}
+
+func (t *tickerScheduler) Stop() {
+	t.mu.Lock()
+	stop := t.stop
+	t.stop = nil
+	t.mu.Unlock()
+	if stop == nil {
+		return
+	}
+
+	close(stop)
+	t.wg.Wait()
+}
+
+func (t *tickerScheduler) IsRunning() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.stop != nil
+}
+
+func (t *tickerScheduler) NextRun() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var earliest time.Time
+	for _, job := range t.jobs {
+		job.mu.Lock()
+		next := job.nextRun
+		job.mu.Unlock()
+		if !next.IsZero() && (earliest.IsZero() || next.Before(earliest)) {
+			earliest = next
+		}
+	}
+
+	return earliest
+}
+
+func (t *tickerScheduler) Location() *time.Location {
+	return t.location
+}
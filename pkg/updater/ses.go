@@ -0,0 +1,51 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ses"
+	sestypes "github.com/aws/aws-sdk-go-v2/service/ses/types"
+)
+
+// sesNotifier emails events through amazon ses with the daemon's own credentials
+type sesNotifier struct {
+	client *ses.Client
+	from   string
+	to     []string
+}
+
+// SESConfig is the verified sender notifications are emailed from through ses and their recipients
+type SESConfig struct {
+	// From is the verified sender, ses is disabled when empty
+	From string
+	To   []string
+}
+
+// newSESNotifier requires a verified sender and at least one recipient
+func newSESNotifier(cfg aws.Config, from string, to []string) (*sesNotifier, error) {
+	if from == "" || len(to) == 0 {
+		return nil, errors.New("both a sender and at least one recipient are required")
+	}
+
+	return &sesNotifier{client: ses.NewFromConfig(cfg), from: from, to: to}, nil
+}
+
+func (n *sesNotifier) name() string {
+	return "ses"
+}
+
+func (n *sesNotifier) notify(ctx context.Context, event notificationEvent) error {
+	_, err := n.client.SendEmail(ctx, &ses.SendEmailInput{
+		Source:      aws.String(n.from),
+		Destination: &sestypes.Destination{ToAddresses: n.to},
+		Message: &sestypes.Message{
+			Subject: &sestypes.Content{Charset: aws.String("UTF-8"), Data: aws.String(event.title())},
+			Body: &sestypes.Body{
+				Text: &sestypes.Content{Charset: aws.String("UTF-8"), Data: aws.String(event.message())},
+			},
+		},
+	})
+
+	return err
+}
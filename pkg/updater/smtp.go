@@ -0,0 +1,148 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// smtp connection security modes
+const (
+	SMTPSecurityStartTLS = "starttls"
+	SMTPSecurityTLS      = "tls"
+	SMTPSecurityNone     = "none"
+)
+
+// smtpNotifier emails events through an smtp server
+type smtpNotifier struct {
+	address  string
+	host     string
+	security string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+// SMTPConfig is the smtp server notifications are emailed through and their recipients
+type SMTPConfig struct {
+	// Address is the host:port of the server, email is disabled when empty
+	Address string
+	// Security is starttls, tls or none, starttls when empty
+	Security string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// newSMTPNotifier validates the server address, security mode and addresses
+func newSMTPNotifier(address, security, username, password, from string, to []string) (*smtpNotifier, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	switch security {
+	case SMTPSecurityStartTLS, SMTPSecurityTLS, SMTPSecurityNone:
+	default:
+		return nil, fmt.Errorf("%s: %s", "security must be one of starttls, tls or none", security)
+	}
+
+	if from == "" || len(to) == 0 {
+		return nil, errors.New("both a sender and at least one recipient are required")
+	}
+
+	return &smtpNotifier{address: address, host: host, security: security, username: username,
+		password: password, from: from, to: to}, nil
+}
+
+func (n *smtpNotifier) name() string {
+	return "smtp"
+}
+
+func (n *smtpNotifier) notify(ctx context.Context, event notificationEvent) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.address)
+	if err != nil {
+		return err
+	}
+	defer func(conn net.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	// net/smtp has no context support, so the deadline is applied to the connection
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	tlsConfig := &tls.Config{ServerName: n.host}
+	if n.security == SMTPSecurityTLS {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		return err
+	}
+	defer func(client *smtp.Client) {
+		_ = client.Close()
+	}(client)
+
+	if n.security == SMTPSecurityStartTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	if n.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.username, n.password, n.host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(n.from); err != nil {
+		return err
+	}
+	for _, rcpt := range n.to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(formatEmail(n.from, n.to, event.title(), event.message())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// formatEmail builds a plain text rfc 5322 message
+func formatEmail(from string, to []string, subject, body string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+
+	return b.Bytes()
+}
@@ -0,0 +1,57 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// snsMaxSubject is the longest subject sns accepts
+const snsMaxSubject = 100
+
+// snsNotifier publishes events as json to an sns topic, with the event type as a message attribute
+// so subscriptions can filter on it
+type snsNotifier struct {
+	client   *sns.Client
+	topicARN string
+}
+
+// newSNSNotifier validates the topic arn
+func newSNSNotifier(cfg aws.Config, topicARN string) (*snsNotifier, error) {
+	if _, err := arn.Parse(topicARN); err != nil {
+		return nil, err
+	}
+
+	return &snsNotifier{client: sns.NewFromConfig(cfg), topicARN: topicARN}, nil
+}
+
+func (n *snsNotifier) name() string {
+	return "sns"
+}
+
+func (n *snsNotifier) notify(ctx context.Context, event notificationEvent) error {
+	message, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	subject := event.title()
+	if len(subject) > snsMaxSubject {
+		subject = subject[:snsMaxSubject]
+	}
+
+	_, err = n.client.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(n.topicARN),
+		Subject:  aws.String(subject),
+		Message:  aws.String(string(message)),
+		MessageAttributes: map[string]snstypes.MessageAttributeValue{
+			"event": {DataType: aws.String("String"), StringValue: aws.String(event.Type)},
+			"fqdn":  {DataType: aws.String("String"), StringValue: aws.String(event.FQDN)},
+		},
+	})
+
+	return err
+}
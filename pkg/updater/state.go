@@ -0,0 +1,381 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"sync"
+	"time"
+)
+
+// recordState is what is known about a single record
+type recordState struct {
+	// Provider names the provider hosting the record's zone
+	Provider       string    `json:"provider,omitempty"`
+	ZoneID         string    `json:"zone_id,omitempty"`
+	PublishedIP    string    `json:"published_ip,omitempty"`
+	LastChangeID   string    `json:"last_change_id,omitempty"`
+	LastChangeTime time.Time `json:"last_change_time"`
+	// DNSSECStatus is the signing status of the record's zone when last checked
+	DNSSECStatus string `json:"dnssec_status,omitempty"`
+	// DNSSECProblem describes what keeps the zone's signed answers from validating, if anything
+	DNSSECProblem string `json:"dnssec_problem,omitempty"`
+}
+
+// runtimeState is the daemon state that survives restarts
+type runtimeState struct {
+	DetectedIP string `json:"detected_ip,omitempty"`
+	// DetectedSince is when the ip source first returned the detected address
+	DetectedSince time.Time `json:"detected_since"`
+	// LastSuccess is when the last cycle completed without errors
+	LastSuccess         time.Time              `json:"last_success"`
+	Records             map[string]recordState `json:"records,omitempty"`
+	ConsecutiveFailures int                    `json:"consecutive_failures"`
+}
+
+// legacyState holds the record fields of state files written before multiple records were supported
+type legacyState struct {
+	recordState
+	runtimeState
+}
+
+// daemonState is the runtime state of an updater and where it is persisted
+type daemonState struct {
+	stateMu sync.Mutex
+	state   runtimeState
+	// stateStore is where state is persisted, state is kept in memory only when nil
+	stateStore StateStore
+	// stateWriteMu serializes writes so an older snapshot never replaces a newer one
+	stateWriteMu sync.Mutex
+	// stateRestored is set once records published by a previous run were restored
+	stateRestored bool
+	// recordVersions holds the version of the shared state of every record last read or written
+	recordVersions map[string]int64
+	// shareMu serializes writes of shared record state so an instance never conflicts with itself
+	shareMu sync.Mutex
+}
+
+// readState returns the state saved in store, a missing state is treated as a fresh start. the record
+// of state saved before multiple records were supported is named legacyName
+func readState(ctx context.Context, store StateStore, legacyName string) (runtimeState, error) {
+	data, err := store.Load(ctx)
+	if err != nil || data == nil {
+		return runtimeState{}, err
+	}
+
+	var loaded legacyState
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return runtimeState{}, err
+	}
+
+	// older files describe a single record, the first one configured
+	if len(loaded.Records) == 0 && loaded.recordState != (recordState{}) {
+		loaded.Records = map[string]recordState{legacyName: loaded.recordState}
+	}
+
+	return loaded.runtimeState, nil
+}
+
+// loadStateFrom restores state from store, the record of state saved before multiple records were
+// supported is named legacyName
+func (u *Updater) loadStateFrom(store StateStore, legacyName string) error {
+	loaded, err := readState(context.Background(), store, legacyName)
+	if err != nil {
+		return err
+	}
+
+	u.stateMu.Lock()
+	u.state = loaded
+	u.stateRestored = len(u.state.Records) > 0
+	names := make([]string, 0, len(u.state.Records))
+	for name := range u.state.Records {
+		names = append(names, name)
+	}
+	u.stateMu.Unlock()
+
+	// records another instance published since are seen as it published them
+	u.refreshRecordStates(context.Background(), store, names)
+
+	return nil
+}
+
+// refreshRecordStates adopts the state of the records named names that instances sharing store
+// changed more recently
+func (u *Updater) refreshRecordStates(ctx context.Context, store StateStore, names []string) {
+	shared, ok := store.(recordStore)
+	if !ok || len(names) == 0 {
+		return
+	}
+
+	loaded, err := shared.LoadRecords(ctx, names)
+	if err != nil {
+		u.logger.WarnContext(ctx, "unable to read shared record state", "store", store.String(), "error", err)
+		return
+	}
+	for name, remote := range loaded {
+		u.adoptRecordState(name, remote)
+	}
+}
+
+// adoptRecordState takes remote as the state of the record named name unless the state held is more
+// recent
+func (u *Updater) adoptRecordState(name string, remote sharedRecord) {
+	u.updateState(func(s *runtimeState) {
+		u.recordVersions[name] = remote.Version
+		if local, ok := s.Records[name]; ok && remote.State.LastChangeTime.Before(local.LastChangeTime) {
+			return
+		}
+		if s.Records == nil {
+			s.Records = map[string]recordState{}
+		}
+		s.Records[name] = remote.State
+	})
+}
+
+// shareRecordState writes the state of the record named name to a store shared with other instances.
+// when another instance changed it since it was read, its state is adopted if it is more recent and
+// overwritten otherwise
+func (u *Updater) shareRecordState(name string, r recordState) {
+	shared, ok := u.stateStore.(recordStore)
+	if !ok {
+		return
+	}
+
+	u.shareMu.Lock()
+	defer u.shareMu.Unlock()
+
+	ctx := context.Background()
+	for attempt := 0; attempt < 2; attempt++ {
+		u.stateMu.Lock()
+		version := u.recordVersions[name]
+		u.stateMu.Unlock()
+
+		next, err := shared.SaveRecord(ctx, name, sharedRecord{State: r, Version: version, UpdatedBy: stateInstance()})
+		if err == nil {
+			u.stateMu.Lock()
+			u.recordVersions[name] = next
+			u.stateMu.Unlock()
+			return
+		}
+		if !errors.Is(err, errRecordConflict) {
+			u.logger.Error("unable to share record state", "record", name, "store", u.stateStore.String(), "error", err)
+			return
+		}
+
+		loaded, err := shared.LoadRecords(ctx, []string{name})
+		if err != nil {
+			u.logger.Error("unable to read shared record state", "record", name, "store", u.stateStore.String(), "error", err)
+			return
+		}
+		remote := loaded[name]
+		if remote.State.LastChangeTime.After(r.LastChangeTime) {
+			u.logger.Info("record state was changed by another instance", "record", name, "updated_by", remote.UpdatedBy,
+				"published_ip", remote.State.PublishedIP)
+			u.adoptRecordState(name, remote)
+			return
+		}
+		u.stateMu.Lock()
+		u.recordVersions[name] = remote.Version
+		u.stateMu.Unlock()
+	}
+	u.logger.Warn("gave up sharing record state after repeated conflicts", "record", name, "store", u.stateStore.String())
+}
+
+// claimChange shares that this instance is about to point the record named name at ip, reporting
+// false when an instance sharing the store claimed the same change within the cycle timeout, so
+// instances running concurrently behind the same address submit the change once. the claim is made
+// when the store can't be reached, a duplicate change being preferable to a missed one
+func (u *Updater) claimChange(ctx context.Context, name, ip string) bool {
+	shared, ok := u.stateStore.(recordStore)
+	if !ok {
+		return true
+	}
+
+	u.shareMu.Lock()
+	defer u.shareMu.Unlock()
+
+	claim := u.getRecordState(name)
+	claim.PublishedIP, claim.LastChangeID, claim.LastChangeTime = ip, "", u.clock().UTC()
+	for attempt := 0; attempt < 2; attempt++ {
+		// the claim is read first, the version held may already be that of another instance's claim
+		loaded, err := shared.LoadRecords(ctx, []string{name})
+		if err != nil {
+			u.logger.WarnContext(ctx, "unable to read shared record state", "record", name, "store", u.stateStore.String(), "error", err)
+			return true
+		}
+		remote := loaded[name]
+		if remote.UpdatedBy != stateInstance() && remote.State.PublishedIP == ip && u.clock().Sub(remote.State.LastChangeTime) < u.cfg.CycleTimeout {
+			u.adoptRecordState(name, remote)
+			u.logger.InfoContext(ctx, "change already claimed by another instance, not submitting it", "record", name,
+				"ip", ip, "updated_by", remote.UpdatedBy)
+			return false
+		}
+
+		next, err := shared.SaveRecord(ctx, name, sharedRecord{State: claim, Version: remote.Version, UpdatedBy: stateInstance()})
+		if err == nil {
+			u.updateState(func(s *runtimeState) {
+				u.recordVersions[name] = next
+				if s.Records == nil {
+					s.Records = map[string]recordState{}
+				}
+				s.Records[name] = claim
+			})
+			return true
+		}
+		if !errors.Is(err, errRecordConflict) {
+			u.logger.WarnContext(ctx, "unable to claim change in shared state", "record", name, "store", u.stateStore.String(), "error", err)
+			return true
+		}
+	}
+
+	return true
+}
+
+// restoreRecordState puts back the state of the record named name after a claimed change failed, so
+// other instances don't take it as published
+func (u *Updater) restoreRecordState(name string, previous recordState) {
+	if _, ok := u.stateStore.(recordStore); !ok {
+		return
+	}
+
+	u.updateRecordState(name, func(r *recordState) {
+		*r = previous
+	})
+}
+
+// stateInstance names this instance in shared state, its hostname
+func stateInstance() string {
+	hostname, _ := os.Hostname()
+
+	return hostname
+}
+
+// updateState applies fn to the state and persists the result when anything changed
+func (u *Updater) updateState(fn func(s *runtimeState)) {
+	u.stateMu.Lock()
+	before := u.state
+	u.state.Records = maps.Clone(u.state.Records)
+	fn(&u.state)
+	snapshot := u.state
+	u.stateMu.Unlock()
+
+	if stateEqual(snapshot, before) || u.stateStore == nil {
+		return
+	}
+
+	if err := u.writeState(u.stateStore, snapshot); err != nil {
+		u.logger.Error("unable to persist state", "store", u.stateStore.String(), "error", err)
+	}
+}
+
+// stateEqual reports whether two states hold the same values
+func stateEqual(a, b runtimeState) bool {
+	return a.DetectedIP == b.DetectedIP && a.DetectedSince.Equal(b.DetectedSince) && a.LastSuccess.Equal(b.LastSuccess) &&
+		a.ConsecutiveFailures == b.ConsecutiveFailures && maps.Equal(a.Records, b.Records)
+}
+
+// getState returns a copy of the current state, its records must not be modified
+func (u *Updater) getState() runtimeState {
+	u.stateMu.Lock()
+	defer u.stateMu.Unlock()
+
+	return u.state
+}
+
+// getRecordState returns what is known about the record named fqdn
+func (u *Updater) getRecordState(fqdn string) recordState {
+	return u.getState().Records[fqdn]
+}
+
+// updateRecordState applies fn to the state of the record named fqdn
+func (u *Updater) updateRecordState(fqdn string, fn func(r *recordState)) {
+	var changed bool
+	var snapshot recordState
+	u.updateState(func(s *runtimeState) {
+		if s.Records == nil {
+			s.Records = map[string]recordState{}
+		}
+		before := s.Records[fqdn]
+		r := before
+		fn(&r)
+		s.Records[fqdn] = r
+		changed, snapshot = r != before, r
+	})
+
+	if changed {
+		u.shareRecordState(fqdn, snapshot)
+	}
+}
+
+// writeState saves s to store
+func (u *Updater) writeState(store StateStore, s runtimeState) error {
+	u.stateWriteMu.Lock()
+	defer u.stateWriteMu.Unlock()
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return store.Save(context.Background(), data)
+}
+
+// getPublishedIP returns the value of the record named fqdn last known to be in route53, or an empty
+// string before its first reconciliation
+func (u *Updater) getPublishedIP(fqdn string) string {
+	return u.getRecordState(fqdn).PublishedIP
+}
+
+// setPublishedIP records the value of the record named fqdn known to be in route53
+func (u *Updater) setPublishedIP(fqdn, ip string) {
+	u.updateRecordState(fqdn, func(r *recordState) {
+		r.PublishedIP = ip
+	})
+}
+
+// setZoneID records the provider and hosted zone the record named fqdn was last resolved to
+func (u *Updater) setZoneID(fqdn, provider, zoneID string) {
+	u.updateRecordState(fqdn, func(r *recordState) {
+		r.Provider = provider
+		r.ZoneID = zoneID
+	})
+}
+
+// setDetectedIP records the address most recently returned by the ip source
+func (u *Updater) setDetectedIP(ip string) {
+	u.updateState(func(s *runtimeState) {
+		if s.DetectedIP != ip || s.DetectedSince.IsZero() {
+			s.DetectedSince = u.clock().UTC()
+		}
+		s.DetectedIP = ip
+	})
+}
+
+// setLastSuccess records the completion time of a cycle that completed without errors
+func (u *Updater) setLastSuccess(t time.Time) {
+	u.updateState(func(s *runtimeState) {
+		s.LastSuccess = t.UTC()
+	})
+}
+
+// cachedZoneID returns the hosted zone the record named fqdn was last resolved to with provider, or
+// an empty string when it must be looked up
+func (u *Updater) cachedZoneID(fqdn, provider string) string {
+	r := u.getRecordState(fqdn)
+	if r.Provider != provider {
+		return ""
+	}
+
+	return r.ZoneID
+}
+
+// setLastChange records a submitted route53 change to the record named fqdn
+func (u *Updater) setLastChange(fqdn, ip, changeID string) {
+	u.updateRecordState(fqdn, func(r *recordState) {
+		r.PublishedIP = ip
+		r.LastChangeID = changeID
+		r.LastChangeTime = u.clock().UTC()
+	})
+}
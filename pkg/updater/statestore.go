@@ -0,0 +1,305 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stateStoreTimeout bounds a single read or write of a remote state store
+const stateStoreTimeout = 15 * time.Second
+
+// StateStore persists the encoded daemon state, the last detected and published addresses, the last
+// change of every record and the failure streak, between runs
+type StateStore interface {
+	// Load returns the state last saved, or nil when nothing was saved yet
+	Load(ctx context.Context) ([]byte, error)
+	// Save replaces the saved state with data
+	Save(ctx context.Context, data []byte) error
+	// String describes where the state is kept in logs
+	String() string
+}
+
+// errRecordConflict is returned when another instance changed the shared state of a record since
+// it was read
+var errRecordConflict = errors.New("record state was changed by another instance")
+
+// sharedRecord is the state of a record as every instance sharing a store sees it
+type sharedRecord struct {
+	State recordState
+	// Version increases with every write, a write only succeeds at the version it read
+	Version int64
+	// UpdatedBy names the instance that wrote the state
+	UpdatedBy string
+}
+
+// recordStore is implemented by state stores that also keep the state of every record in an entry of
+// its own, shared by every instance publishing the record, so a fleet has a consistent view of what
+// was last published and when
+type recordStore interface {
+	// LoadRecords returns the shared state of the records named names that have any
+	LoadRecords(ctx context.Context, names []string) (map[string]sharedRecord, error)
+	// SaveRecord replaces the shared state of the record named name when it is still at the version of
+	// record, returning the new version or errRecordConflict
+	SaveRecord(ctx context.Context, name string, record sharedRecord) (int64, error)
+}
+
+// openStateStore returns the store kept at location with the aws configuration and clock of the
+// updater
+func (u *Updater) openStateStore(location string) (StateStore, error) {
+	return openStateStore(location, u.cfg.AWS, u.clock)
+}
+
+// openStateStore returns the store kept at location, an s3://bucket/key url, a dynamodb://table/id
+// url or a local file path, remote stores are called with cfg and stamp writes with clock
+func openStateStore(location string, cfg aws.Config, clock func() time.Time) (StateStore, error) {
+	parsed, err := url.Parse(location)
+	if err != nil || (parsed.Scheme != "s3" && parsed.Scheme != "dynamodb") {
+		return &fileStateStore{path: location}, nil
+	}
+
+	key := strings.TrimPrefix(parsed.Path, "/")
+	if parsed.Host == "" || key == "" {
+		return nil, fmt.Errorf("%s: %s", "not a "+parsed.Scheme+"://name/key url", location)
+	}
+
+	if parsed.Scheme == "s3" {
+		return &s3StateStore{client: s3.NewFromConfig(cfg), bucket: parsed.Host, key: key}, nil
+	}
+
+	return &dynamoDBStateStore{client: dynamodb.NewFromConfig(cfg), table: parsed.Host, id: key, clock: clock}, nil
+}
+
+// fileStateStore keeps the state in a local file
+type fileStateStore struct {
+	path string
+}
+
+func (s *fileStateStore) Load(context.Context) ([]byte, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	return data, err
+}
+
+// Save atomically replaces the file so a crash mid-write never leaves a truncated file behind
+func (s *fileStateStore) Save(_ context.Context, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (s *fileStateStore) String() string {
+	return s.path
+}
+
+// s3StateStore keeps the state in an s3 object, for hosts without persistent disks
+type s3StateStore struct {
+	client *s3.Client
+	bucket string
+	key    string
+}
+
+func (s *s3StateStore) Load(ctx context.Context) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, stateStoreTimeout)
+	defer cancel()
+
+	resp, err := s.client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(s.key)})
+	var noSuchKey *s3types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, withCause(awsErrorCause(err), fmt.Errorf("%s %s: %w", "unable to download", s, err))
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
+
+func (s *s3StateStore) Save(ctx context.Context, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, stateStoreTimeout)
+	defer cancel()
+
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return withCause(awsErrorCause(err), fmt.Errorf("%s %s: %w", "unable to upload", s, err))
+	}
+
+	return nil
+}
+
+func (s *s3StateStore) String() string {
+	return "s3://" + s.bucket + "/" + s.key
+}
+
+// dynamoDBRecordPrefix starts the id of the item holding the shared state of a record, followed by
+// the name of the record
+const dynamoDBRecordPrefix = "record#"
+
+// dynamoDBStateStore keeps the state in an item of a dynamodb table whose partition key is a string
+// named id, the state is held as json in its state attribute. the state of every record is also kept
+// in an item of its own, written conditionally on its version attribute, so instances sharing the
+// table never overwrite each other's changes unseen
+type dynamoDBStateStore struct {
+	client *dynamodb.Client
+	table  string
+	id     string
+	// clock returns the time writes are stamped with
+	clock func() time.Time
+}
+
+func (s *dynamoDBStateStore) Load(ctx context.Context) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, stateStoreTimeout)
+	defer cancel()
+
+	resp, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            map[string]dynamodbtypes.AttributeValue{"id": &dynamodbtypes.AttributeValueMemberS{Value: s.id}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, withCause(awsErrorCause(err), fmt.Errorf("%s %s: %w", "unable to read", s, err))
+	}
+
+	value, ok := resp.Item["state"].(*dynamodbtypes.AttributeValueMemberS)
+	if !ok {
+		return nil, nil
+	}
+
+	return []byte(value.Value), nil
+}
+
+func (s *dynamoDBStateStore) Save(ctx context.Context, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, stateStoreTimeout)
+	defer cancel()
+
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item: map[string]dynamodbtypes.AttributeValue{
+			"id":         &dynamodbtypes.AttributeValueMemberS{Value: s.id},
+			"state":      &dynamodbtypes.AttributeValueMemberS{Value: string(data)},
+			"updated_at": &dynamodbtypes.AttributeValueMemberS{Value: s.clock().UTC().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		return withCause(awsErrorCause(err), fmt.Errorf("%s %s: %w", "unable to write", s, err))
+	}
+
+	return nil
+}
+
+func (s *dynamoDBStateStore) LoadRecords(ctx context.Context, names []string) (map[string]sharedRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, stateStoreTimeout)
+	defer cancel()
+
+	loaded := map[string]sharedRecord{}
+	for _, name := range names {
+		resp, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(s.table),
+			Key:            map[string]dynamodbtypes.AttributeValue{"id": &dynamodbtypes.AttributeValueMemberS{Value: dynamoDBRecordPrefix + name}},
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return nil, withCause(awsErrorCause(err), fmt.Errorf("%s %s %s: %w", "unable to read record", name, "from "+s.String(), err))
+		}
+		value, ok := resp.Item["state"].(*dynamodbtypes.AttributeValueMemberS)
+		if !ok {
+			continue
+		}
+
+		record := sharedRecord{}
+		if err := json.Unmarshal([]byte(value.Value), &record.State); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", "unable to parse the state of record", name, err)
+		}
+		if version, ok := resp.Item["version"].(*dynamodbtypes.AttributeValueMemberN); ok {
+			record.Version, _ = strconv.ParseInt(version.Value, 10, 64)
+		}
+		if updatedBy, ok := resp.Item["updated_by"].(*dynamodbtypes.AttributeValueMemberS); ok {
+			record.UpdatedBy = updatedBy.Value
+		}
+		loaded[name] = record
+	}
+
+	return loaded, nil
+}
+
+func (s *dynamoDBStateStore) SaveRecord(ctx context.Context, name string, record sharedRecord) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, stateStoreTimeout)
+	defer cancel()
+
+	data, err := json.Marshal(record.State)
+	if err != nil {
+		return 0, err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item: map[string]dynamodbtypes.AttributeValue{
+			"id":         &dynamodbtypes.AttributeValueMemberS{Value: dynamoDBRecordPrefix + name},
+			"state":      &dynamodbtypes.AttributeValueMemberS{Value: string(data)},
+			"version":    &dynamodbtypes.AttributeValueMemberN{Value: strconv.FormatInt(record.Version+1, 10)},
+			"updated_by": &dynamodbtypes.AttributeValueMemberS{Value: record.UpdatedBy},
+			"updated_at": &dynamodbtypes.AttributeValueMemberS{Value: s.clock().UTC().Format(time.RFC3339)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	}
+	if record.Version > 0 {
+		input.ConditionExpression = aws.String("version = :version")
+		input.ExpressionAttributeValues = map[string]dynamodbtypes.AttributeValue{
+			":version": &dynamodbtypes.AttributeValueMemberN{Value: strconv.FormatInt(record.Version, 10)},
+		}
+	}
+
+	_, err = s.client.PutItem(ctx, input)
+	var conditionErr *dynamodbtypes.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return 0, errRecordConflict
+	}
+	if err != nil {
+		return 0, withCause(awsErrorCause(err), fmt.Errorf("%s %s %s: %w", "unable to write record", name, "to "+s.String(), err))
+	}
+
+	return record.Version + 1, nil
+}
+
+func (s *dynamoDBStateStore) String() string {
+	return "dynamodb://" + s.table + "/" + s.id
+}
@@ -0,0 +1,100 @@
+package updater
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+// supported statsd wire formats
+const (
+	StatsdFlavorDogStatsd = "dogstatsd"
+	StatsdFlavorStatsd    = "statsd"
+)
+
+// statsdClient pushes metrics over udp, dogstatsd attaches labels as tags while plain statsd folds
+// them into the metric name
+type statsdClient struct {
+	conn      net.Conn
+	prefix    string
+	tags      []string
+	dogstatsd bool
+	logger    *slog.Logger
+}
+
+// StatsdConfig configures pushing metrics to a statsd server
+type StatsdConfig struct {
+	// Address is the udp address of the server, metrics aren't pushed when empty
+	Address string
+	// Prefix is prepended to every metric name, DefaultStatsdPrefix when empty
+	Prefix string
+	// Flavor is the wire format, dogstatsd or statsd, dogstatsd when empty
+	Flavor string
+	// Tags are appended to every dogstatsd metric
+	Tags []string
+}
+
+// newStatsdClient creates a client for the server of cfg, logging failed sends to logger
+func newStatsdClient(cfg StatsdConfig, logger *slog.Logger) (*statsdClient, error) {
+	prefix, flavor := cfg.Prefix, cfg.Flavor
+	if prefix == "" {
+		prefix = DefaultStatsdPrefix
+	}
+	if flavor == "" {
+		flavor = StatsdFlavorDogStatsd
+	}
+	if flavor != StatsdFlavorDogStatsd && flavor != StatsdFlavorStatsd {
+		return nil, fmt.Errorf("%s: %s", "statsd flavor must be one of dogstatsd or statsd", flavor)
+	}
+
+	conn, err := net.Dial("udp", cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &statsdClient{conn: conn, prefix: prefix, tags: cfg.Tags, dogstatsd: flavor == StatsdFlavorDogStatsd, logger: logger}, nil
+}
+
+// count increments a counter
+func (c *statsdClient) count(name string, value int64, labels ...string) {
+	c.send(name, fmt.Sprintf("%d|c", value), labels)
+}
+
+// gauge sets a gauge
+func (c *statsdClient) gauge(name string, value float64, labels ...string) {
+	c.send(name, fmt.Sprintf("%g|g", value), labels)
+}
+
+// timing records a duration in milliseconds
+func (c *statsdClient) timing(name string, d time.Duration, labels ...string) {
+	c.send(name, fmt.Sprintf("%g|ms", float64(d)/float64(time.Millisecond)), labels)
+}
+
+// send writes a single metric, labels are key and value pairs
+func (c *statsdClient) send(name, value string, labels []string) {
+	var line string
+	if c.dogstatsd {
+		tags := append([]string{}, c.tags...)
+		for i := 0; i+1 < len(labels); i += 2 {
+			tags = append(tags, labels[i]+":"+labels[i+1])
+		}
+
+		line = c.prefix + name + ":" + value
+		if len(tags) > 0 {
+			line += "|#" + strings.Join(tags, ",")
+		}
+	} else {
+		parts := []string{c.prefix + name}
+		for i := 1; i < len(labels); i += 2 {
+			parts = append(parts, strings.ReplaceAll(labels[i], ".", "_"))
+		}
+
+		line = strings.Join(parts, ".") + ":" + value
+	}
+
+	if _, err := c.conn.Write([]byte(line)); err != nil {
+		c.logger.Debug("unable to send statsd metric", "metric", name, "error", err)
+	}
+}
@@ -0,0 +1,155 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxRecentErrors bounds how many failures are kept for status reporting
+const maxRecentErrors = 10
+
+// RecordStatus describes what the daemon believes is published for a record
+type RecordStatus struct {
+	FQDN           string    `json:"fqdn"`
+	Provider       string    `json:"provider,omitempty"`
+	ZoneID         string    `json:"zone_id,omitempty"`
+	PublishedIP    string    `json:"published_ip,omitempty"`
+	LastChangeID   string    `json:"last_change_id,omitempty"`
+	LastChangeTime time.Time `json:"last_change_time"`
+	DNSSECStatus   string    `json:"dnssec_status,omitempty"`
+	DNSSECProblem  string    `json:"dnssec_problem,omitempty"`
+}
+
+// ErrorStatus is a failed cycle kept for status reporting
+type ErrorStatus struct {
+	Time  time.Time `json:"time"`
+	Job   string    `json:"job"`
+	Cause string    `json:"cause"`
+	Error string    `json:"error"`
+}
+
+// Status is what the daemon knows of the records and its own health, served by /status
+type Status struct {
+	Running             bool           `json:"running"`
+	Paused              bool           `json:"paused"`
+	Standby             bool           `json:"standby,omitempty"`
+	DetectedIP          string         `json:"detected_ip,omitempty"`
+	DetectedSince       *time.Time     `json:"detected_since,omitempty"`
+	Records             []RecordStatus `json:"records"`
+	LastSuccess         *time.Time     `json:"last_success,omitempty"`
+	NextRun             *time.Time     `json:"next_run,omitempty"`
+	ConsecutiveFailures int            `json:"consecutive_failures"`
+	RecentErrors        []ErrorStatus  `json:"recent_errors,omitempty"`
+}
+
+// recordRecentError keeps err for status reporting, discarding the oldest beyond maxRecentErrors
+func (u *Updater) recordRecentError(job string, err error) {
+	u.recentErrorsMu.Lock()
+	defer u.recentErrorsMu.Unlock()
+
+	u.recentErrors = append(u.recentErrors, ErrorStatus{
+		Time:  u.clock().UTC(),
+		Job:   job,
+		Cause: ErrorCause(err),
+		Error: strings.TrimSpace(err.Error()),
+	})
+	if len(u.recentErrors) > maxRecentErrors {
+		u.recentErrors = u.recentErrors[len(u.recentErrors)-maxRecentErrors:]
+	}
+}
+
+// stateStatus builds a report from persisted state alone, listing the records in names or every
+// record in the state when names is empty
+func stateStatus(s runtimeState, names []string) Status {
+	if len(names) == 0 {
+		for name := range s.Records {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	report := Status{
+		DetectedIP:          s.DetectedIP,
+		Records:             []RecordStatus{},
+		ConsecutiveFailures: s.ConsecutiveFailures,
+	}
+	if !s.DetectedSince.IsZero() {
+		since := s.DetectedSince
+		report.DetectedSince = &since
+	}
+	if !s.LastSuccess.IsZero() {
+		last := s.LastSuccess
+		report.LastSuccess = &last
+	}
+	for _, name := range names {
+		r := s.Records[name]
+		report.Records = append(report.Records, RecordStatus{
+			FQDN:           name,
+			Provider:       r.Provider,
+			ZoneID:         r.ZoneID,
+			PublishedIP:    r.PublishedIP,
+			LastChangeID:   r.LastChangeID,
+			LastChangeTime: r.LastChangeTime,
+			DNSSECStatus:   r.DNSSECStatus,
+			DNSSECProblem:  r.DNSSECProblem,
+		})
+	}
+
+	return report
+}
+
+// currentStatus builds a report from the running daemon
+func (u *Updater) currentStatus() Status {
+	names := u.recordNames()
+	for _, record := range u.dyndnsRecords {
+		names = append(names, record.key)
+	}
+	report := stateStatus(u.getState(), names)
+	report.Running = true
+	report.Paused = u.paused.Load()
+	report.Standby = !u.isLeader()
+
+	if last := u.lastSuccess.Load(); last != 0 {
+		t := time.Unix(0, last).UTC()
+		report.LastSuccess = &t
+	}
+
+	// the earliest scheduled run across jobs
+	if next := u.scheduler.NextRun(); !next.IsZero() {
+		next = next.UTC()
+		report.NextRun = &next
+	}
+
+	u.recentErrorsMu.Lock()
+	report.RecentErrors = append([]ErrorStatus{}, u.recentErrors...)
+	u.recentErrorsMu.Unlock()
+
+	return report
+}
+
+// handleStatus serves the current status as json
+func (u *Updater) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(u.currentStatus())
+}
+
+// ReadStatus reports the state saved at location by a daemon that may not be running, a state file
+// path or a remote store called with cfg. the record of state saved before multiple records were
+// supported is named legacyName
+func ReadStatus(ctx context.Context, location string, cfg aws.Config, legacyName string) (Status, error) {
+	store, err := openStateStore(location, cfg, time.Now)
+	if err != nil {
+		return Status{}, err
+	}
+	s, err := readState(ctx, store, legacyName)
+	if err != nil {
+		return Status{}, err
+	}
+
+	return stateStatus(s, nil), nil
+}
@@ -0,0 +1,92 @@
+package updater
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sdNotify sends a state notification to the systemd service manager, it is a no-op when the
+// process is not supervised by a Type=notify unit
+func (u *Updater) sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	// a leading @ denotes an abstract namespace socket
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer func(conn *net.UnixConn) {
+		err := conn.Close()
+		if err != nil {
+			u.logger.Warn("unable to close notify socket", "error", err)
+		}
+	}(conn)
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// notifyReady tells systemd the service is up, only the first call has any effect
+func (u *Updater) notifyReady() {
+	u.readyOnce.Do(func() {
+		if err := u.sdNotify("READY=1"); err != nil {
+			u.logger.Warn("unable to notify systemd readiness", "error", err)
+		}
+	})
+}
+
+// watchdogInterval returns half of the watchdog timeout systemd expects heartbeats within,
+// or zero when the watchdog is not enabled for this process
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// startWatchdog heartbeats the systemd watchdog until ctx is done for as long as cycles keep
+// completing, a cycle running past its deadline stops the heartbeat so systemd restarts the hung daemon
+func (u *Updater) startWatchdog(ctx context.Context) {
+	interval := watchdogInterval()
+	if interval == 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if u.cycleWedged(u.cfg.CycleTimeout + interval) {
+				u.logger.Error("update cycle is wedged, withholding watchdog heartbeat")
+				continue
+			}
+
+			if err := u.sdNotify("WATCHDOG=1"); err != nil {
+				u.logger.Warn("unable to notify systemd watchdog", "error", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,57 @@
+package updater
+
+import (
+	"context"
+	"github.com/rgravlin/route53ddns/pkg/ipsource"
+	"strings"
+)
+
+// JobTailnet publishes the address of the tailnet interface
+const JobTailnet = "tailnet"
+
+// TailnetConfig configures the records pointed at the address of a tailscale or wireguard interface
+type TailnetConfig struct {
+	// Records are pointed at the address of the interface, in the syntax of the records variable, the
+	// tailnet records are disabled when empty
+	Records []string
+	// Interface names the interface, the interface holding a tailscale address when empty
+	Interface string
+}
+
+// tailnetPublisher keeps records pointed at the address of a private tunnel interface alongside the
+// public records, so machines are reachable by name over the tailnet
+type tailnetPublisher struct {
+	*targetPublisher
+	source ipsource.Source
+	target *publishTarget
+}
+
+// newTailnetPublisher returns a publisher of cfg
+func (u *Updater) newTailnetPublisher(cfg TailnetConfig) (*tailnetPublisher, error) {
+	target, err := u.newPublishTarget(strings.Join(cfg.Records, ","), "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &tailnetPublisher{targetPublisher: u.newTargetPublisher("tailnet"), target: target,
+		source: ipsource.NewInterface(cfg.Interface, ipsource.TailscalePrefix)}, nil
+}
+
+// refresh reads the address of the interface and publishes it when it changed, the records are left
+// alone while the interface has no address
+func (t *tailnetPublisher) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), t.updater.cfg.CycleTimeout)
+	defer cancel()
+
+	ip, err := t.source.IP(ctx)
+	if err != nil {
+		t.updater.logger.WarnContext(ctx, "unable to read tailnet address", "source", t.source, "error", err)
+		return
+	}
+	if len(t.target.ips) != 1 || t.target.ips[0] != ip {
+		t.updater.logger.InfoContext(ctx, "tailnet address detected", "source", t.source, "ip", ip)
+	}
+	t.target.ips = []string{ip}
+	t.set(JobTailnet, t.target)
+	t.sync(ctx)
+}
@@ -0,0 +1,56 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// telegramAPIURL is the bot api base url
+const telegramAPIURL = "https://api.telegram.org"
+
+// telegramNotifier sends events to a telegram chat through a bot
+type telegramNotifier struct {
+	client *http.Client
+	token  string
+	chatID string
+}
+
+// telegramMessage is the body of a sendMessage call
+type telegramMessage struct {
+	ChatID string `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+// TelegramConfig is the bot sending notifications and the chat it sends them to
+type TelegramConfig struct {
+	BotToken string
+	ChatID   string
+}
+
+// newTelegramNotifier requires both the bot token and the chat id
+func newTelegramNotifier(client *http.Client, token, chatID string) (*telegramNotifier, error) {
+	if token == "" || chatID == "" {
+		return nil, errors.New("both a bot token and a chat id are required")
+	}
+
+	return &telegramNotifier{client: client, token: token, chatID: chatID}, nil
+}
+
+func (n *telegramNotifier) name() string {
+	return "telegram"
+}
+
+func (n *telegramNotifier) notify(ctx context.Context, event notificationEvent) error {
+	err := postJSON(ctx, n.client, telegramAPIURL+"/bot"+n.token+"/sendMessage",
+		telegramMessage{ChatID: n.chatID, Text: event.title() + "\n\n" + event.message()}, nil)
+
+	// the bot token is part of the url, keep it out of the logs
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		return uerr.Err
+	}
+
+	return err
+}
@@ -0,0 +1,93 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// messageTemplates render the title and body of a notification, nil templates keep the defaults
+type messageTemplates struct {
+	title *template.Template
+	body  *template.Template
+}
+
+// templateFuncs are available to every notification template
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"json":  webhookFuncs["json"],
+	"default": func(def, value string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	},
+}
+
+// loadNotificationTemplates parses the shared and per notifier templates of cfg for every configured
+// notifier, the templates of a notifier override the shared templates for it alone
+func loadNotificationTemplates(configured []notifier, cfg NotificationsConfig) (map[string]messageTemplates, error) {
+	loaded := map[string]messageTemplates{}
+	for _, n := range configured {
+		own := cfg.Templates[n.name()]
+
+		var templates messageTemplates
+		var err error
+		if templates.title, err = parseMessageTemplate(n.name()+"-title", own.Title, cfg.TitleTemplate); err != nil {
+			return nil, err
+		}
+		if templates.body, err = parseMessageTemplate(n.name()+"-body", own.Body, cfg.BodyTemplate); err != nil {
+			return nil, err
+		}
+
+		if templates.title != nil || templates.body != nil {
+			loaded[n.name()] = templates
+		}
+	}
+
+	return loaded, nil
+}
+
+// parseMessageTemplate parses the first of values that is set, or returns nil when none are
+func parseMessageTemplate(tmplName string, values ...string) (*template.Template, error) {
+	for _, value := range values {
+		if value != "" {
+			t, err := template.New(tmplName).Funcs(templateFuncs).Option("missingkey=error").Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", tmplName, err)
+			}
+			return t, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// applyTemplates renders the templates of notifier into a copy of event, falling back to the default
+// title and body when a template fails
+func (u *Updater) applyTemplates(ctx context.Context, notifier string, event notificationEvent) notificationEvent {
+	templates, ok := u.notificationTemplates[notifier]
+	if !ok {
+		return event
+	}
+
+	render := func(t *template.Template) string {
+		if t == nil {
+			return ""
+		}
+		var b bytes.Buffer
+		if err := t.Execute(&b, event); err != nil {
+			u.logger.WarnContext(ctx, "unable to render notification template", "notifier", notifier, "template", t.Name(), "error", err)
+			return ""
+		}
+		return strings.TrimSpace(b.String())
+	}
+
+	event.customTitle = render(templates.title)
+	event.customBody = render(templates.body)
+
+	return event
+}
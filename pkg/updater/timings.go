@@ -0,0 +1,59 @@
+package updater
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// cycle phases that are timed individually
+const (
+	PhaseIPFetch      = "ip_fetch"
+	PhaseZoneLookup   = "zone_lookup"
+	PhaseRecordList   = "record_list"
+	PhaseChangeSubmit = "change_submit"
+)
+
+// cycleTimings collects how long each phase of a cycle took
+type cycleTimings struct {
+	mu     sync.Mutex
+	phases []slog.Attr
+}
+
+// timingsKey is the context key holding the timings of the cycle in flight
+type timingsKey struct{}
+
+// withTimings returns a context collecting phase timings
+func withTimings(ctx context.Context) context.Context {
+	return context.WithValue(ctx, timingsKey{}, &cycleTimings{})
+}
+
+// timePhase records how long phase has taken since start, in the cycle timings and in metrics
+func (u *Updater) timePhase(ctx context.Context, phase string, start time.Time) {
+	d := time.Since(start)
+	u.observePhase(phase, d)
+
+	timings, ok := ctx.Value(timingsKey{}).(*cycleTimings)
+	if !ok {
+		return
+	}
+
+	timings.mu.Lock()
+	defer timings.mu.Unlock()
+
+	timings.phases = append(timings.phases, slog.Float64(phase, d.Seconds()))
+}
+
+// timingsAttr returns the phase timings collected in ctx as a log attribute group
+func timingsAttr(ctx context.Context) slog.Attr {
+	timings, ok := ctx.Value(timingsKey{}).(*cycleTimings)
+	if !ok {
+		return slog.Group("timings")
+	}
+
+	timings.mu.Lock()
+	defer timings.mu.Unlock()
+
+	return slog.Attr{Key: "timings", Value: slog.GroupValue(timings.phases...)}
+}
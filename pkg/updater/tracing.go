@@ -0,0 +1,103 @@
+package updater
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies spans created by this program
+const tracerName = "github.com/rgravlin/route53ddns"
+
+// tracingFlushTimeout bounds exporting the spans still queued when the program stops or a lambda
+// invocation returns
+const tracingFlushTimeout = 5 * time.Second
+
+// TracingConfig configures exporting a span per update cycle
+type TracingConfig struct {
+	// Endpoint is the url of the otlp/http collector spans are exported to, tracing is disabled when
+	// empty unless XRay is set
+	Endpoint string
+	// XRay creates trace ids x-ray accepts and traces every aws call, exporting to DefaultXRayEndpoint
+	// unless Endpoint is set
+	XRay bool
+}
+
+// setupTracing exports spans to the otlp/http collector at endpoint and returns the provider batching
+// them, spans are dropped by the global no-op provider until this is called
+func (u *Updater) setupTracing(ctx context.Context, endpoint string) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName("route53ddns")))
+	if err != nil {
+		return nil, err
+	}
+
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	}
+	if u.cfg.Tracing.XRay {
+		options = append(options, sdktrace.WithIDGenerator(xray.NewIDGenerator()))
+		otel.SetTextMapPropagator(xray.Propagator{})
+	}
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+
+	return provider, nil
+}
+
+// flushTracing exports the spans still queued, lambda functions are frozen before the batch would
+// otherwise be sent
+func (u *Updater) flushTracing() {
+	if u.tracerProvider == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+	defer cancel()
+	if err := u.tracerProvider.ForceFlush(ctx); err != nil {
+		u.logger.Warn("unable to export spans", "error", err)
+	}
+}
+
+// shutdownTracing exports the spans still queued and stops the provider
+func (u *Updater) shutdownTracing() {
+	if u.tracerProvider == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+	defer cancel()
+	if err := u.tracerProvider.Shutdown(ctx); err != nil {
+		u.logger.Warn("unable to export spans", "error", err)
+	}
+}
+
+// startSpan starts a span named name as a child of any span in ctx
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records err on span, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
@@ -0,0 +1,188 @@
+package updater
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rgravlin/route53ddns/pkg/ipsource"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// headers of a trigger request, the signature is the hex encoded hmac-sha256 of the timestamp, a dot
+// and the body, prefixed with sha256=
+const (
+	TriggerSignatureHeader = "X-Route53DDNS-Signature"
+	TriggerTimestampHeader = "X-Route53DDNS-Timestamp"
+)
+
+// triggerMaxSkew is how far the timestamp of a trigger may be from the clock, so a captured request
+// can't be replayed later
+const triggerMaxSkew = 5 * time.Minute
+
+// errNoTriggerTarget is returned when a trigger names a record that isn't managed
+var errNoTriggerTarget = errors.New("no record to trigger")
+
+// triggerRequest is the body of a trigger, a request without an ip runs a reconciliation and one
+// with an ip points fqdn at it, or every record when fqdn is empty
+type triggerRequest struct {
+	FQDN string `json:"fqdn,omitempty"`
+	IP   string `json:"ip,omitempty"`
+}
+
+// handleTrigger serves /hooks/trigger for external systems pushing an address or asking for an
+// update right away, requests must be signed with the trigger secret
+func (u *Updater) handleTrigger(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeAPI(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 64<<10))
+	if err != nil {
+		writeAPI(w, http.StatusBadRequest, apiError{Error: fmt.Sprintf("%s: %v", "unable to read body", err)})
+		return
+	}
+	if err := u.verifyTrigger(r.Header.Get(TriggerTimestampHeader), r.Header.Get(TriggerSignatureHeader), body); err != nil {
+		u.logger.WarnContext(r.Context(), "refused trigger", "client", r.RemoteAddr, "error", err)
+		writeAPI(w, http.StatusUnauthorized, apiError{Error: err.Error()})
+		return
+	}
+
+	var req triggerRequest
+	if len(strings.TrimSpace(string(body))) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeAPI(w, http.StatusBadRequest, apiError{Error: fmt.Sprintf("%s: %v", "not a valid request body", err)})
+			return
+		}
+	}
+
+	if req.IP == "" {
+		if req.FQDN != "" {
+			writeAPI(w, http.StatusBadRequest, apiError{Error: "a record needs an ip to point at"})
+			return
+		}
+		if err := u.scheduler.Trigger(JobReconcile); err != nil {
+			writeAPI(w, http.StatusServiceUnavailable, apiError{Error: err.Error()})
+			return
+		}
+		u.logger.InfoContext(r.Context(), "reconciliation requested", "source", "trigger")
+		writeAPI(w, http.StatusAccepted, map[string]string{"job": JobReconcile})
+		return
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(req.IP))
+	if ip == nil || ip.To4() == nil {
+		writeAPI(w, http.StatusBadRequest, apiError{Error: fmt.Sprintf("%v: %q", ipsource.ErrInvalidIP, req.IP)})
+		return
+	}
+	targets, err := u.triggerUpdate(r.Context(), ip.String(), strings.ToLower(strings.TrimSuffix(req.FQDN, ".")))
+	if errors.Is(err, errNoTriggerTarget) {
+		writeAPI(w, http.StatusNotFound, apiError{Error: "no record named " + req.FQDN})
+		return
+	}
+	if errors.Is(err, errNotLeader) || errors.Is(err, errChangeDeferred) {
+		writeAPI(w, http.StatusServiceUnavailable, apiError{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		writeAPI(w, http.StatusBadGateway, apiError{Error: err.Error()})
+		return
+	}
+	names := make([]string, 0, len(targets))
+	for _, record := range targets {
+		names = append(names, record.key)
+	}
+	writeAPI(w, http.StatusOK, map[string]any{"ip": ip.String(), "records": names})
+}
+
+// verifyTrigger checks that signature is the signature of timestamp and body and that the timestamp,
+// in unix seconds, is recent
+func (u *Updater) verifyTrigger(timestamp, signature string, body []byte) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s %s", "missing or invalid", TriggerTimestampHeader)
+	}
+	if skew := u.clock().Sub(time.Unix(seconds, 0)); skew > triggerMaxSkew || skew < -triggerMaxSkew {
+		return fmt.Errorf("%s %s", "stale", TriggerTimestampHeader)
+	}
+
+	given, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || !hmac.Equal(given, u.triggerSignature(timestamp, body)) {
+		return fmt.Errorf("%s %s", "missing or invalid", TriggerSignatureHeader)
+	}
+
+	return nil
+}
+
+// triggerSignature returns the hmac-sha256 of timestamp and body with the trigger secret
+func (u *Updater) triggerSignature(timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(u.cfg.TriggerSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return mac.Sum(nil)
+}
+
+// triggerRecords returns the records named fqdn, scheduled or pushed by dyndns clients, or every
+// scheduled record when fqdn is empty. callers hold cycleMu
+func (u *Updater) triggerRecords(fqdn string) []*dnsRecord {
+	if fqdn == "" {
+		return slices.Clone(u.records)
+	}
+
+	var matched []*dnsRecord
+	for _, record := range append(append([]*dnsRecord{}, u.records...), u.dyndnsRecords...) {
+		if strings.EqualFold(record.fqdn, fqdn) {
+			matched = append(matched, record)
+		}
+	}
+
+	return matched
+}
+
+// triggerUpdate points the records named fqdn at ip, serialized with the scheduled cycles, and returns
+// the records it updated. scheduled records are pointed back at the detected address by the next
+// reconciliation when it differs
+func (u *Updater) triggerUpdate(ctx context.Context, ip, fqdn string) ([]*dnsRecord, error) {
+	u.cycleMu.Lock()
+	defer u.cycleMu.Unlock()
+
+	// records are replaced between cycles, so the targets are resolved while holding the lock
+	targets := u.triggerRecords(fqdn)
+	if len(targets) == 0 {
+		return nil, errNoTriggerTarget
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), u.cfg.CycleTimeout)
+	defer cancel()
+	ctx = withRunID(ctx, uuid.NewString())
+
+	err := u.updateRecords(ctx, ip, targets)
+	if errors.Is(err, errNotLeader) {
+		u.logger.WarnContext(ctx, "triggered update refused, not the leader", "ip", ip)
+		return targets, err
+	}
+	if errors.Is(err, errChangeDeferred) {
+		u.logger.InfoContext(ctx, "triggered update deferred", "ip", ip)
+		return targets, err
+	}
+	if err != nil {
+		u.logger.ErrorContext(ctx, "triggered update failed", "ip", ip, "error_category", ErrorCause(err), "error", err)
+		return targets, err
+	}
+	u.logger.InfoContext(ctx, "triggered update", "ip", ip, "records", len(targets))
+
+	return targets, nil
+}
@@ -0,0 +1,127 @@
+package updater
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTriggerSecret is the secret the trigger tests sign requests with
+const testTriggerSecret = "s3cret"
+
+// signTrigger returns the signature header value of timestamp and body under secret
+func signTrigger(secret, timestamp, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + body))
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyTrigger(t *testing.T) {
+	u := New(WithConfig(Config{TriggerSecret: testTriggerSecret}), WithClock(func() time.Time { return testNow }))
+	body := `{"ip":"192.0.2.5"}`
+	// at returns the timestamp of the clock moved by offset
+	at := func(offset time.Duration) string {
+		return strconv.FormatInt(testNow.Add(offset).Unix(), 10)
+	}
+	now := at(0)
+
+	for _, tc := range []struct {
+		name      string
+		timestamp string
+		signature string
+		body      string
+		wantErr   string
+	}{
+		{name: "valid", timestamp: now, signature: signTrigger(testTriggerSecret, now, body), body: body},
+		{name: "empty body", timestamp: now, signature: signTrigger(testTriggerSecret, now, ""), body: ""},
+		{
+			name:      "within skew",
+			timestamp: at(-triggerMaxSkew),
+			signature: signTrigger(testTriggerSecret, at(-triggerMaxSkew), body),
+			body:      body,
+		},
+		{
+			name:      "too old",
+			timestamp: at(-triggerMaxSkew - time.Second),
+			signature: signTrigger(testTriggerSecret, at(-triggerMaxSkew-time.Second), body),
+			body:      body,
+			wantErr:   "stale",
+		},
+		{
+			name:      "in the future",
+			timestamp: at(triggerMaxSkew + time.Second),
+			signature: signTrigger(testTriggerSecret, at(triggerMaxSkew+time.Second), body),
+			body:      body,
+			wantErr:   "stale",
+		},
+		{name: "missing timestamp", signature: signTrigger(testTriggerSecret, "", body), body: body, wantErr: TriggerTimestampHeader},
+		{name: "missing signature", timestamp: now, body: body, wantErr: TriggerSignatureHeader},
+		{name: "not hex", timestamp: now, signature: "sha256=zz", body: body, wantErr: TriggerSignatureHeader},
+		{name: "other secret", timestamp: now, signature: signTrigger("other", now, body), body: body, wantErr: TriggerSignatureHeader},
+		{name: "tampered body", timestamp: now, signature: signTrigger(testTriggerSecret, now, body), body: `{"ip":"192.0.2.6"}`, wantErr: TriggerSignatureHeader},
+		{
+			name:      "replayed with a new timestamp",
+			timestamp: at(time.Second),
+			signature: signTrigger(testTriggerSecret, now, body),
+			body:      body,
+			wantErr:   TriggerSignatureHeader,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := u.verifyTrigger(tc.timestamp, tc.signature, []byte(tc.body))
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("verifyTrigger() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("verifyTrigger() error = %v, want one about %s", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandleTriggerRefusesUnsigned(t *testing.T) {
+	u, provider := newTestUpdater(t, "192.0.2.1", WithConfig(Config{
+		Records:       []string{testRecord},
+		TriggerSecret: testTriggerSecret,
+		ListenAddress: "127.0.0.1:0",
+	}))
+	body := `{"ip":"192.0.2.5"}`
+	now := strconv.FormatInt(testNow.Unix(), 10)
+
+	for _, tc := range []struct {
+		name       string
+		signature  string
+		wantStatus int
+		wantValue  string
+	}{
+		{name: "unsigned", wantStatus: http.StatusUnauthorized, wantValue: "192.0.2.1"},
+		{name: "signed", signature: signTrigger(testTriggerSecret, now, body), wantStatus: http.StatusOK, wantValue: "192.0.2.5"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/hooks/trigger", strings.NewReader(body))
+			r.Header.Set(TriggerTimestampHeader, now)
+			if tc.signature != "" {
+				r.Header.Set(TriggerSignatureHeader, tc.signature)
+			}
+			w := httptest.NewRecorder()
+			u.handleTrigger(w, r)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tc.wantStatus, w.Body.String())
+			}
+			if values := heldValues(t, provider); len(values) != 1 || values[0] != tc.wantValue {
+				t.Errorf("record values = %v, want [%s]", values, tc.wantValue)
+			}
+		})
+	}
+}
@@ -0,0 +1,737 @@
+// Package updater keeps route53 records pointed at the public address of the host, reconciling them
+// on a schedule and reporting every change through its logs, metrics and notifications.
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/rgravlin/route53ddns/pkg/dns"
+	"github.com/rgravlin/route53ddns/pkg/ipsource"
+	"github.com/robfig/cron/v3"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	// embed the zone database so named locations resolve on minimal images without tzdata
+	_ "time/tzdata"
+)
+
+const (
+	RecordType                 = "A"
+	TTL                        = 300
+	UpdateInterval             = 300 * time.Second
+	DefaultFailureThreshold    = 0
+	DefaultFailureAction       = FailureActionAlert
+	DefaultSMTPSecurity        = SMTPSecurityStartTLS
+	DefaultMQTTTopic           = "route53ddns"
+	DefaultGotifyPriority      = 5
+	DefaultHookTimeout         = 30 * time.Second
+	DefaultCycleTimeout        = 60 * time.Second
+	DefaultStatsdPrefix        = "route53ddns."
+	DefaultXRayEndpoint        = "http://localhost:4318"
+	DefaultDNSSECCheckInterval = time.Hour
+)
+
+// providers hosting the zones of records
+const (
+	ProviderRoute53    = "route53"
+	ProviderCloudflare = "cloudflare"
+	ProviderCloudDNS   = "clouddns"
+	ProviderRFC2136    = "rfc2136"
+	ProviderFake       = "fake"
+)
+
+// DomainRegex \x2E regex is equal to a literal period `.`
+var domainRegex = regexp.MustCompile(`^([^\x2E]*)\x2E(.*)$`)
+
+// Config configures an Updater, zero durations and locations take the daemon's defaults
+type Config struct {
+	// Records are the records kept up to date, each a name optionally prefixed with the provider
+	// hosting its zone and a colon, and for route53 optionally followed by @ and the aws profile of
+	// the account holding its zone
+	Records []string
+	// IPSource determines the address to publish
+	IPSource ipsource.Source
+	// AWS is the configuration route53 clients are created from
+	AWS aws.Config
+	// AWSOptions are the options the aws configuration of records with a profile is loaded with, e.g.
+	// the endpoints to call
+	AWSOptions []func(*config.LoadOptions) error
+	// Retry is the retry policy of route53 clients
+	Retry dns.RetryOptions
+	// Providers host the zones of records outside route53 by the name records are prefixed with
+	Providers map[string]dns.Provider
+	// ReconcileInterval is how often route53 is re-read to correct drift
+	ReconcileInterval time.Duration
+	// CheckInterval is how often the detected ip is compared to the cached record, zero disables it
+	CheckInterval time.Duration
+	// CycleTimeout bounds a single update cycle including every network call it makes
+	CycleTimeout time.Duration
+	// ReadyMaxAge is how recent the last successful cycle must be to report ready, three intervals
+	// by default
+	ReadyMaxAge time.Duration
+	// Location is the time zone schedules and quiet windows are evaluated in
+	Location *time.Location
+	// QuietWindows are daily HH:MM-HH:MM windows during which changes are detected and logged but
+	// never submitted
+	QuietWindows []string
+	// Scheduler names the scheduler jobs run on, gocron or ticker, gocron when empty
+	Scheduler string
+	// ReconcileSchedule is a cron expression reconciliations run at instead of every
+	// ReconcileInterval when set
+	ReconcileSchedule string
+	// DryRun logs planned changes without submitting them
+	DryRun bool
+	// VerifyPermissions checks that the credentials can update every record before running
+	VerifyPermissions bool
+	// StopIP is the address records are pointed at once the updater stops
+	StopIP string
+	// DeleteOnStop deletes the records once the updater stops, it can't be combined with StopIP
+	DeleteOnStop bool
+	// FailureThreshold is how many cycles may fail in a row before FailureAction is taken, zero
+	// disables the failure policy
+	FailureThreshold int
+	// FailureAction is alert, exit or both, exit stopping the updater with an error
+	FailureAction string
+	// StateFile is the file, s3:// or dynamodb:// url the daemon state is persisted to, state is kept
+	// in memory only when empty
+	StateFile string
+	// JournalFile is the jsonl file detected changes and submissions are appended to
+	JournalFile string
+	// AuditLog is the file every route53 change call is audited to
+	AuditLog string
+	// DNSSECCheckInterval is how often the signing status of the record zones is checked, zero
+	// disables it
+	DNSSECCheckInterval time.Duration
+	// CloudWatchNamespace is the namespace cloudwatch metrics are published to, none are when empty
+	CloudWatchNamespace string
+	// QuietSteadyState logs the messages of cycles finding the records correct at debug level
+	QuietSteadyState bool
+	// ListenAddress serves metrics, health, status and the optional apis, nothing is served when
+	// empty
+	ListenAddress string
+	// Pprof exposes profiling endpoints under /debug/pprof/ on the listen address
+	Pprof bool
+	// Hooks are shell commands run around every change
+	Hooks HooksConfig
+	// Monitors are external dead man's switches pinged around every cycle
+	Monitors MonitorsConfig
+	// Statsd pushes metrics to a statsd server
+	Statsd StatsdConfig
+	// Tracing exports a span per cycle
+	Tracing TracingConfig
+	// Notifications tell humans about changes and failures
+	Notifications NotificationsConfig
+	// DynDNSRecords are updated by clients speaking the dyndns2 protocol on /nic/update instead of
+	// by the scheduled cycles, in the syntax of Records
+	DynDNSRecords []string
+	// DynDNSUsers maps the usernames allowed to push dyndns2 updates to their passwords
+	DynDNSUsers map[string]string
+	// APIToken authenticates requests to the control api under /v1/, the api is disabled when empty
+	APIToken string
+	// TriggerSecret signs requests to /hooks/trigger pushing an address or asking for an update, the
+	// endpoint is disabled when empty
+	TriggerSecret string
+	// ACMEDNS serves an acme-dns compatible api under /acme-dns/ creating challenge records in route53
+	ACMEDNS ACMEDNSConfig
+	// Leader elects the replica updating the records among replicas sharing a lock
+	Leader LeaderConfig
+	// OwnerID marks the records changed with a TXT record of the same name, records marked by another
+	// owner are not changed. ownership is not checked when empty
+	OwnerID string
+	// Kubernetes publishes records for annotated services and ingresses
+	Kubernetes KubernetesConfig
+	// Docker publishes records for labeled containers
+	Docker DockerConfig
+	// Tailnet points records at the address of a tailscale or wireguard interface
+	Tailnet TailnetConfig
+	// Consul publishes records for the services of a consul catalog
+	Consul ConsulConfig
+	// MDNS announces the names and the detected address on the local network
+	MDNS MDNSConfig
+	// NSUpdate accepts rfc 2136 updates from dhcp servers and other nsupdate clients
+	NSUpdate NSUpdateConfig
+}
+
+// NSUpdateConfig configures the listener applying signed dynamic updates to route53 zones
+type NSUpdateConfig struct {
+	// Address is the udp and tcp address updates are accepted on, the listener is disabled when empty
+	Address string
+	// KeyName, Secret and Algorithm are the tsig key updates must be signed with, the secret base64
+	// encoded and the algorithm hmac-sha256 when empty
+	KeyName   string
+	Secret    string
+	Algorithm string
+	// Zones are the route53 zones updates may change
+	Zones []string
+}
+
+// validate checks the settings of cfg that aren't checked as the features they configure are set up
+func (cfg *Config) validate() error {
+	if cfg.StopIP != "" {
+		if ip := net.ParseIP(cfg.StopIP); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("%s: %s", "stop address is not a valid IPv4 address", cfg.StopIP)
+		}
+		if cfg.DeleteOnStop {
+			return errors.New("records can't both be pointed at a stop address and deleted on stop")
+		}
+	}
+	if !validFailureAction(cfg.FailureAction) {
+		return fmt.Errorf("%s: %s", "failure action must be one of alert, exit or both", cfg.FailureAction)
+	}
+	if cfg.CheckInterval < 0 || cfg.DNSSECCheckInterval < 0 {
+		return errors.New("check and dnssec check intervals must not be negative")
+	}
+	if cfg.Scheduler != "" && cfg.Scheduler != SchedulerGocron && cfg.Scheduler != SchedulerTicker {
+		return fmt.Errorf("%s: %s", "scheduler must be one of gocron or ticker", cfg.Scheduler)
+	}
+	if cfg.ReconcileSchedule != "" {
+		if _, err := cron.ParseStandard(cfg.ReconcileSchedule); err != nil {
+			return fmt.Errorf("%s: %w", "reconcile schedule is not a valid cron expression", err)
+		}
+	}
+	if cfg.Retry.Mode != "" && !dns.ValidRetryMode(cfg.Retry.Mode) {
+		return fmt.Errorf("%s: %s", "retry mode must be one of standard or adaptive", cfg.Retry.Mode)
+	}
+	if cfg.Retry.MaxAttempts < 0 || cfg.Retry.RequestTimeout < 0 {
+		return errors.New("retry attempts and request timeout must not be negative")
+	}
+	if len(cfg.DynDNSRecords) > 0 {
+		if len(cfg.DynDNSUsers) == 0 {
+			return errors.New("dyndns records require dyndns users")
+		}
+		if cfg.ListenAddress == "" {
+			return errors.New("dyndns updates require a listen address")
+		}
+	}
+	if cfg.APIToken != "" && cfg.ListenAddress == "" {
+		return errors.New("the control api requires a listen address")
+	}
+	if cfg.TriggerSecret != "" && cfg.ListenAddress == "" {
+		return errors.New("the trigger endpoint requires a listen address")
+	}
+	if cfg.ACMEDNS.Domain != "" && cfg.ListenAddress == "" {
+		return errors.New("the acme-dns api requires a listen address")
+	}
+	if cfg.OwnerID != "" && !ownerIDRegex.MatchString(cfg.OwnerID) {
+		return errors.New("owner id may only hold letters, digits, dots, dashes and underscores")
+	}
+	if cfg.Leader.Lock != "" && cfg.Leader.LeaseDuration < 3*time.Second {
+		return errors.New("leader lease duration must be at least 3s")
+	}
+	if _, err := parseRecords(strings.Join(cfg.Tailnet.Records, ",")); err != nil {
+		return fmt.Errorf("%s: %w", "unable to parse tailnet records", err)
+	}
+	if cfg.Tailnet.Interface != "" && len(cfg.Tailnet.Records) == 0 {
+		return errors.New("a tailnet interface requires tailnet records")
+	}
+
+	return nil
+}
+
+// Updater runs the update cycles of a Config, every updater keeps its own records, state and
+// reporting so several can run in one process
+type Updater struct {
+	cfg Config
+	// clock returns the current time, time.Now unless replaced
+	clock func() time.Time
+	// logger carries the run id of the cycle in flight on every record logged with its context
+	logger *slog.Logger
+	// notificationClient and monitorClient send notifications and monitor pings
+	notificationClient *http.Client
+	monitorClient      *http.Client
+	// route53 replaces the route53 client of records without a profile when set
+	route53 dns.Route53API
+	// dnsClient is the route53 client of records without a profile, created by setup
+	dnsClient dns.Route53API
+	// scheduler is the scheduler the jobs run on, created for the configured location unless given
+	scheduler Scheduler
+	// stopRun stops Run with the error it returns, nil while the updater isn't running
+	stopRun context.CancelCauseFunc
+
+	// records are updated every cycle, the first is the first record configured
+	records []*dnsRecord
+	// fqdn names the first record, which notifications and the lambda response describe
+	fqdn string
+	// dyndnsRecords are updated by clients speaking the dyndns2 protocol instead of by the scheduled
+	// cycles, the endpoint is disabled when empty
+	dyndnsRecords []*dnsRecord
+	// quietWindows are daily windows during which changes are detected and logged but never submitted
+	quietWindows []quietWindow
+	// paused stops changes from being published while cycles keep detecting and reporting
+	paused atomic.Bool
+	// lifecycle is the bus every lifecycle event is published on
+	lifecycle *eventBus
+	// steadyStateLevel is used for messages logged every cycle while the record is already correct
+	steadyStateLevel slog.Level
+
+	daemonState
+	jobStats
+	failureStreak
+	notificationHub
+
+	// elector is the election this replica takes part in, nil when every replica updates the records
+	elector *leaderElector
+	// acmeDNS serves the acme-dns api when configured
+	acmeDNS *acmeDNSServer
+	// monitors are notified of every cycle
+	monitors []cycleMonitor
+	// statsd pushes the cycle metrics when configured
+	statsd *statsdClient
+	// tracerProvider exports the spans of every cycle when tracing is configured
+	tracerProvider *sdktrace.TracerProvider
+	// auditLogger writes the audit log when configured
+	auditLogger *slog.Logger
+	// cloudWatchClient publishes the cycle metrics to cloudwatch when a namespace is configured
+	cloudWatchClient *cloudwatch.Client
+	// journalMu serializes appends to the journal file
+	journalMu sync.Mutex
+	// readyOnce tells systemd the service is up only once
+	readyOnce sync.Once
+	// dnssecDenied stops checking once the credentials turn out not to allow it
+	dnssecDenied atomic.Bool
+
+	// targetPublishers are every publisher created, resynced when this replica is elected leader
+	targetPublishers   []*targetPublisher
+	targetPublishersMu sync.Mutex
+	// recentErrors are the last errors of failed cycles, reported by the status endpoint
+	recentErrors   []ErrorStatus
+	recentErrorsMu sync.Mutex
+}
+
+// Option configures an Updater
+type Option func(u *Updater)
+
+// WithConfig sets what the updater keeps up to date and how often
+func WithConfig(cfg Config) Option {
+	return func(u *Updater) {
+		u.cfg = cfg
+	}
+}
+
+// WithClock replaces the clock quiet windows, state and journal timestamps are read from
+func WithClock(now func() time.Time) Option {
+	return func(u *Updater) {
+		u.clock = now
+	}
+}
+
+// WithHTTPClient replaces the client notifications and monitor pings are sent with
+func WithHTTPClient(client *http.Client) Option {
+	return func(u *Updater) {
+		u.notificationClient = client
+		u.monitorClient = client
+	}
+}
+
+// WithRoute53Client replaces the route53 client of records without a profile, e.g. with a
+// dns.FakeRoute53 to run without aws credentials
+func WithRoute53Client(client dns.Route53API) Option {
+	return func(u *Updater) {
+		u.route53 = client
+	}
+}
+
+// WithLogger replaces the default logger the updater logs to, records logged during a cycle carry
+// its run id
+func WithLogger(logger *slog.Logger) Option {
+	return func(u *Updater) {
+		u.logger = logger
+	}
+}
+
+// WithStateStore persists the daemon state to store, replacing the store of the state file
+func WithStateStore(store StateStore) Option {
+	return func(u *Updater) {
+		u.stateStore = store
+	}
+}
+
+// WithScheduler replaces the scheduler the jobs run on, the configured location is not applied to it
+func WithScheduler(s Scheduler) Option {
+	return func(u *Updater) {
+		u.scheduler = s
+	}
+}
+
+// New returns an updater configured by opts, creating the scheduler its jobs run on unless one is
+// given
+func New(opts ...Option) *Updater {
+	u := &Updater{}
+	for _, opt := range opts {
+		opt(u)
+	}
+
+	cfg := &u.cfg
+	if cfg.ReconcileInterval <= 0 {
+		cfg.ReconcileInterval = UpdateInterval
+	}
+	if cfg.CycleTimeout <= 0 {
+		cfg.CycleTimeout = DefaultCycleTimeout
+	}
+	if cfg.ReadyMaxAge <= 0 {
+		// a missed reconciliation or two is tolerated before reporting not ready
+		freshness := cfg.ReconcileInterval
+		if cfg.CheckInterval > 0 && cfg.CheckInterval < freshness {
+			freshness = cfg.CheckInterval
+		}
+		cfg.ReadyMaxAge = 3 * freshness
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.UTC
+	}
+	if cfg.FailureAction == "" {
+		cfg.FailureAction = DefaultFailureAction
+	}
+	if cfg.Hooks.Timeout <= 0 {
+		cfg.Hooks.Timeout = DefaultHookTimeout
+	}
+
+	if u.clock == nil {
+		u.clock = time.Now
+	}
+	if u.logger == nil {
+		u.logger = slog.Default()
+	}
+	u.logger = slog.New(runIDHandler{u.logger.Handler()})
+	if u.notificationClient == nil {
+		u.notificationClient = &http.Client{Timeout: notificationTimeout}
+	}
+	if u.monitorClient == nil {
+		u.monitorClient = &http.Client{Timeout: monitorTimeout}
+	}
+	u.recordVersions = map[string]int64{}
+	u.notificationLimits = map[string]*notificationLimit{}
+	u.lifecycle = &eventBus{logger: u.logger, handlers: []eventHandler{u.journalLifecycle, u.metricsLifecycle, u.notifyLifecycle, u.hooksLifecycle}}
+	u.steadyStateLevel = slog.LevelInfo
+	if cfg.QuietSteadyState {
+		u.steadyStateLevel = slog.LevelDebug
+	}
+
+	// every aws call is logged, audited and measured, and traced when x-ray tracing is enabled
+	u.instrumentAWSConfig(&cfg.AWS)
+
+	// schedulers skip a tick while the previous cycle is still running so two cycles never race
+	// changes against the same record
+	if u.scheduler == nil {
+		var err error
+		if u.scheduler, err = newScheduler(cfg.Scheduler, cfg.Location, u.logger); err != nil {
+			u.logger.Warn("falling back to the default scheduler", "scheduler", cfg.Scheduler, "error", err)
+			u.scheduler = newGocronScheduler(cfg.Location)
+		}
+	}
+
+	return u
+}
+
+// setup checks the configuration, parses the records and gives each the provider hosting its zone,
+// then restores the state left behind by a previous run and sets up reporting
+func (u *Updater) setup(ctx context.Context) error {
+	if err := u.cfg.validate(); err != nil {
+		return withCause(CauseConfig, err)
+	}
+	windows, err := parseQuietWindows(strings.Join(u.cfg.QuietWindows, ","))
+	if err != nil {
+		return withCause(CauseConfig, fmt.Errorf("%s: %w", "unable to parse quiet windows", err))
+	}
+	u.quietWindows = windows
+
+	parsed, err := newRecords(u.cfg.Records)
+	if err != nil {
+		return withCause(CauseConfig, fmt.Errorf("%s: %w", "unable to parse records", err))
+	}
+	u.records = parsed
+	u.fqdn = u.records[0].fqdn
+
+	u.dnsClient = u.route53
+	if u.dnsClient == nil {
+		u.dnsClient = u.newRoute53Client(u.cfg.AWS)
+	}
+	if err := u.setupRecordClients(ctx, u.records, u.cfg.AWS, u.dnsClient); err != nil {
+		return withCause(CauseConfig, fmt.Errorf("%s: %w", "unable to configure record credentials", err))
+	}
+	if err := u.setupDynDNSRecords(ctx, u.cfg.DynDNSRecords); err != nil {
+		return withCause(CauseConfig, fmt.Errorf("%s: %w", "unable to configure dyndns records", err))
+	}
+	u.acmeDNS = nil
+	if u.cfg.ACMEDNS.Domain != "" {
+		if u.acmeDNS, err = u.newACMEDNSServer(u.cfg.ACMEDNS); err != nil {
+			return withCause(CauseConfig, fmt.Errorf("%s: %w", "unable to configure the acme-dns api", err))
+		}
+	}
+
+	// statsd is set up before state is restored so the restored streak is reported
+	u.statsd = nil
+	if u.cfg.Statsd.Address != "" {
+		if u.statsd, err = newStatsdClient(u.cfg.Statsd, u.logger); err != nil {
+			return withCause(CauseConfig, fmt.Errorf("%s: %w", "unable to initialize statsd", err))
+		}
+	}
+
+	// x-ray traces go through a local collector by default
+	endpoint := u.cfg.Tracing.Endpoint
+	if endpoint == "" && u.cfg.Tracing.XRay {
+		endpoint = DefaultXRayEndpoint
+	}
+	if endpoint != "" {
+		if u.tracerProvider, err = u.setupTracing(ctx, endpoint); err != nil {
+			return withCause(CauseConfig, fmt.Errorf("%s: %w", "unable to initialize tracing", err))
+		}
+	}
+
+	// restore runtime state left behind by a previous run
+	store := u.stateStore
+	if store == nil && u.cfg.StateFile != "" {
+		if store, err = u.openStateStore(u.cfg.StateFile); err != nil {
+			return withCause(CauseConfig, fmt.Errorf("%s: %w", "unable to open state store", err))
+		}
+	}
+	if store != nil {
+		if err := u.loadStateFrom(store, u.records[0].key); err != nil {
+			return withCause(CauseConfig, fmt.Errorf("%s %s: %w", "unable to load state from", store.String(), err))
+		}
+		u.stateStore = store
+		u.failureMu.Lock()
+		u.consecutiveFailures = u.getState().ConsecutiveFailures
+		u.observeFailureStreak(u.consecutiveFailures)
+		u.failureMu.Unlock()
+	}
+
+	if u.monitors, err = u.newMonitors(u.cfg.Monitors); err != nil {
+		return withCause(CauseConfig, fmt.Errorf("%s: %w", "unable to configure monitors", err))
+	}
+	if err := u.configureNotifications(u.cfg.Notifications, u.cfg.AWS); err != nil {
+		return withCause(CauseConfig, fmt.Errorf("%s: %w", "unable to configure notifications", err))
+	}
+	if u.cfg.AuditLog != "" {
+		if err := u.setupAuditLog(u.cfg.AuditLog); err != nil {
+			return withCause(CauseConfig, fmt.Errorf("%s: %w", "unable to open audit log", err))
+		}
+	}
+	u.cloudWatchClient = nil
+	if u.cfg.CloudWatchNamespace != "" {
+		u.cloudWatchClient = cloudwatch.NewFromConfig(u.cfg.AWS)
+	}
+
+	// fail fast when the credentials can't update every record
+	if u.cfg.VerifyPermissions {
+		if err := u.verifyPermissions(ctx, u.records); err != nil {
+			return fmt.Errorf("%s: %w", "permission verification failed", err)
+		}
+	}
+
+	return nil
+}
+
+// Run keeps the records up to date until ctx is done, then points them at the stop address or
+// deletes them when configured to. it returns an error when the updater can't be set up or the
+// failure policy stopped it
+func (u *Updater) Run(ctx context.Context) error {
+	if u.cfg.IPSource == nil {
+		return withCause(CauseConfig, errors.New("no ip source configured"))
+	}
+	if err := u.setup(ctx); err != nil {
+		return err
+	}
+	defer u.shutdownTracing()
+
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	u.stopRun = stop
+	defer func() {
+		u.stopRun = nil
+	}()
+
+	// serve metrics and other endpoints when a listen address is configured
+	if u.cfg.ListenAddress != "" {
+		server, err := u.startHTTPServer(u.cfg.ListenAddress, u.cfg.Pprof)
+		if err != nil {
+			return withCause(CauseConfig, fmt.Errorf("%s: %w", "unable to serve http endpoints", err))
+		}
+		defer server.Close()
+	}
+	u.startWatchdog(ctx)
+
+	// leadership is settled before the first run so a standby never updates the records
+	u.elector = nil
+	if u.cfg.Leader.Lock != "" {
+		lock, err := u.openLeaderLock(u.cfg.Leader.Lock)
+		if err != nil {
+			return withCause(CauseConfig, fmt.Errorf("%s: %w", "unable to configure the leader lock", err))
+		}
+		u.elector = u.newLeaderElector(lock, u.cfg.Leader.Identity, u.cfg.Leader.LeaseDuration)
+		u.elector.campaign(ctx)
+		if !u.isLeader() {
+			u.logger.Info("standing by, another replica holds the leader lock", "lock", lock.String())
+		}
+		go u.elector.run(ctx)
+		defer u.elector.release()
+	}
+	u.schedule()
+
+	if u.cfg.NSUpdate.Address != "" {
+		nsupdate, err := newNSUpdateListener(u.cfg.NSUpdate.Address, u.cfg.NSUpdate.KeyName, u.cfg.NSUpdate.Algorithm,
+			u.cfg.NSUpdate.Secret, u.cfg.NSUpdate.Zones, u.logger)
+		if err != nil {
+			return withCause(CauseConfig, fmt.Errorf("%s: %w", "unable to configure the dynamic update listener", err))
+		}
+		nsupdate.start(dns.NewRoute53(u.dnsClient))
+		defer nsupdate.stop()
+	}
+
+	if u.acmeDNS != nil {
+		u.acmeDNS.setProvider(dns.NewRoute53(u.dnsClient))
+	}
+
+	if len(u.cfg.Kubernetes.Watch) > 0 || u.cfg.Kubernetes.Operator {
+		client, err := u.cfg.Kubernetes.client()
+		if err != nil {
+			return withCause(CauseConfig, fmt.Errorf("%s: %w", "unable to configure the kubernetes client", err))
+		}
+		if len(u.cfg.Kubernetes.Watch) > 0 {
+			watcher, err := u.newKubernetesWatcher(client, u.cfg.Kubernetes.Namespace, u.cfg.Kubernetes.Watch)
+			if err != nil {
+				return withCause(CauseConfig, err)
+			}
+			watcher.start(ctx)
+		}
+		if u.cfg.Kubernetes.Operator {
+			u.newDDNSOperator(client, u.cfg.Kubernetes.Namespace).start(ctx)
+		}
+	}
+
+	if u.cfg.Docker.Watch {
+		client, err := u.cfg.Docker.client()
+		if err != nil {
+			return withCause(CauseConfig, fmt.Errorf("%s: %w", "unable to configure the docker client", err))
+		}
+		u.newDockerWatcher(client).start(ctx)
+	}
+
+	if len(u.cfg.Tailnet.Records) > 0 {
+		tailnet, err := u.newTailnetPublisher(u.cfg.Tailnet)
+		if err != nil {
+			return withCause(CauseConfig, fmt.Errorf("%s: %w", "unable to configure tailnet records", err))
+		}
+		if err := u.scheduler.Every(JobTailnet, u.cfg.ReconcileInterval, false, tailnet.refresh); err != nil {
+			u.logger.Error("failure setting up job", "job", JobTailnet, "error", err)
+		}
+	}
+
+	if u.cfg.Consul.Tag != "" {
+		interval := u.cfg.Consul.Interval
+		if interval <= 0 {
+			interval = DefaultConsulInterval
+		}
+		if err := u.scheduler.Every(JobConsul, interval, false, u.newConsulSync(u.cfg.Consul).refresh); err != nil {
+			u.logger.Error("failure setting up job", "job", JobConsul, "error", err)
+		}
+	}
+
+	if u.cfg.MDNS.Enabled {
+		names := u.cfg.MDNS.Names
+		if len(names) == 0 {
+			for _, record := range u.records {
+				if !containsString(names, record.fqdn) {
+					names = append(names, record.fqdn)
+				}
+			}
+		}
+		responder, err := u.newMDNSResponder(names, u.cfg.MDNS.Interface)
+		if err != nil {
+			// the public records don't depend on it, so the daemon runs on without
+			u.logger.Error("unable to start mdns responder", "interface", u.cfg.MDNS.Interface, "error", err)
+		} else {
+			responder.start(ctx)
+		}
+	}
+
+	u.notify(context.Background(), notificationEvent{Type: EventDaemonStarted, FQDN: u.fqdn, NewIP: u.getPublishedIP(u.fqdn)})
+	u.scheduler.Start()
+	<-ctx.Done()
+	u.scheduler.Stop()
+
+	// the failure policy stops the updater without touching the records, which may be fine while
+	// route53 can't be reached
+	if err := context.Cause(ctx); errors.Is(err, errFailureThreshold) {
+		u.flushNotifications(notificationTimeout)
+		return err
+	}
+
+	// the records are still updated on shutdown, so this gets a deadline of its own
+	if u.cfg.StopIP != "" && u.isLeader() {
+		ctx, cancel := context.WithTimeout(context.Background(), u.cfg.CycleTimeout)
+		if err := u.updateRecords(ctx, u.cfg.StopIP, u.records); err != nil {
+			u.logger.Error("unable to point records at the stop address", "ip", u.cfg.StopIP, "error_category", ErrorCause(err), "error", err)
+		}
+		cancel()
+	} else if u.cfg.DeleteOnStop && u.isLeader() {
+		ctx, cancel := context.WithTimeout(context.Background(), u.cfg.CycleTimeout)
+		if err := u.deregisterRecords(ctx); err != nil {
+			u.logger.Error("unable to delete records", "error_category", ErrorCause(err), "error", err)
+		}
+		cancel()
+	}
+	u.notify(context.Background(), notificationEvent{Type: EventDaemonStopped, FQDN: u.fqdn, NewIP: u.getPublishedIP(u.fqdn)})
+	u.flushNotifications(notificationTimeout)
+
+	return nil
+}
+
+// Reload applies the records and notifications of cfg to the running updater, any other setting
+// takes effect once the updater is created again
+func (u *Updater) Reload(ctx context.Context, cfg Config) error {
+	var errs []error
+	if !slices.Equal(cfg.Records, u.cfg.Records) {
+		if err := u.reloadRecords(ctx, cfg.Records); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", "unable to reload records, keeping previous records", err))
+		} else {
+			u.cfg.Records = cfg.Records
+			u.logger.Info("records reloaded", "records", u.recordNames())
+		}
+	}
+
+	if err := u.configureNotifications(cfg.Notifications, u.cfg.AWS); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", "unable to reconfigure notifications", err))
+	} else {
+		u.cfg.Notifications = cfg.Notifications
+	}
+
+	return errors.Join(errs...)
+}
+
+// schedule adds the update cycles and background checks to the scheduler
+func (u *Updater) schedule() {
+	var err error
+	if u.cfg.ReconcileSchedule != "" {
+		err = u.scheduler.Cron(JobReconcile, u.cfg.ReconcileSchedule, u.runJob(JobReconcile, u.reconcileJob()))
+	} else {
+		err = u.scheduler.Every(JobReconcile, u.cfg.ReconcileInterval, false, u.runJob(JobReconcile, u.reconcileJob()))
+	}
+	if err != nil {
+		u.logger.Error("failure setting up job", "job", JobReconcile, "error", err)
+	}
+
+	// the lightweight check starts one interval in so it does not duplicate the initial reconciliation
+	if u.cfg.CheckInterval > 0 {
+		if err := u.scheduler.Every(JobCheck, u.cfg.CheckInterval, true, u.runJob(JobCheck, u.checkIPAndUpdate)); err != nil {
+			u.logger.Error("failure setting up job", "job", JobCheck, "error", err)
+		}
+	}
+
+	if u.cfg.DNSSECCheckInterval > 0 {
+		if err := u.scheduler.Every("dnssec", u.cfg.DNSSECCheckInterval, false, u.checkDNSSEC); err != nil {
+			u.logger.Error("failure setting up job", "job", "dnssec", "error", err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"strings"
+	"time"
+)
+
+// verifyTimeout bounds verifying the permissions of a single record at startup
+const verifyTimeout = 30 * time.Second
+
+// verifyPermissions checks the credentials of every record can find its hosted zone, read its records
+// and change it, so a missing permission fails at startup rather than on the first address change
+func (u *Updater) verifyPermissions(ctx context.Context, records []*dnsRecord) error {
+	for _, record := range records {
+		// permissions are only known ahead of a change for route53 records
+		if record.client == nil {
+			continue
+		}
+		if err := u.verifyRecordPermissions(ctx, record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifyRecordPermissions probes the read permissions of record with cheap calls and simulates the
+// change permission, which can't be probed without changing the record
+func (u *Updater) verifyRecordPermissions(ctx context.Context, record *dnsRecord) error {
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+
+	zoneID, err := findZoneID(ctx, record.provider, record.fqdn)
+	if isAccessDenied(err) {
+		return withCause(CauseAWSAuth, fmt.Errorf("%s for %s", "missing route53:ListHostedZonesByName", record.fqdn))
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = record.client.ListResourceRecordSets(ctx, &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(zoneID),
+		StartRecordName: aws.String(record.fqdn),
+		StartRecordType: RecordType,
+		MaxItems:        aws.Int32(1),
+	})
+	if isAccessDenied(err) {
+		return withCause(CauseAWSAuth, fmt.Errorf("%s %s", "missing route53:ListResourceRecordSets on zone", zoneID))
+	}
+	if err != nil {
+		return withCause(awsErrorCause(err), err)
+	}
+
+	allowed, err := simulateChange(ctx, record.cfg, record.fqdn, zoneID)
+	if err != nil {
+		// simulating needs permissions of its own that a least privilege policy doesn't grant
+		u.logger.WarnContext(ctx, "unable to verify change permission", "record", record.fqdn, "zone_id", zoneID, "error", err)
+		return nil
+	}
+	if !allowed {
+		return withCause(CauseAWSAuth, fmt.Errorf("%s %s", "missing route53:ChangeResourceRecordSets on zone", zoneID))
+	}
+	u.logger.InfoContext(ctx, "verified permissions", "record", record.fqdn, "zone_id", zoneID)
+
+	return nil
+}
+
+// simulateChange reports whether the principal of cfg may upsert the record named fqdn in zoneID,
+// including the record name, type and action conditions of the iam-policy command
+func simulateChange(ctx context.Context, cfg aws.Config, fqdn, zoneID string) (bool, error) {
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return false, err
+	}
+	principal, err := principalARN(aws.ToString(identity.Arn))
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := iam.NewFromConfig(cfg).SimulatePrincipalPolicy(ctx, &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(principal),
+		ActionNames:     []string{"route53:ChangeResourceRecordSets"},
+		ResourceArns:    []string{"arn:aws:route53:::hostedzone/" + zoneID},
+		ContextEntries: []iamtypes.ContextEntry{
+			contextEntry("route53:ChangeResourceRecordSetsNormalizedRecordNames", strings.ToLower(strings.TrimSuffix(fqdn, "."))),
+			contextEntry("route53:ChangeResourceRecordSetsRecordTypes", string(RecordType)),
+			contextEntry("route53:ChangeResourceRecordSetsActions", "UPSERT"),
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for _, result := range resp.EvaluationResults {
+		if result.EvalDecision != iamtypes.PolicyEvaluationDecisionTypeAllowed {
+			return false, nil
+		}
+	}
+
+	return len(resp.EvaluationResults) > 0, nil
+}
+
+// contextEntry returns a string list condition key for a policy simulation
+func contextEntry(key, value string) iamtypes.ContextEntry {
+	return iamtypes.ContextEntry{
+		ContextKeyName:   aws.String(key),
+		ContextKeyType:   iamtypes.ContextKeyTypeEnumStringList,
+		ContextKeyValues: []string{value},
+	}
+}
+
+// principalARN returns the iam arn whose policies apply to a caller, assumed role sessions are
+// simulated as the role they were assumed from
+func principalARN(callerARN string) (string, error) {
+	parsed, err := arn.Parse(callerARN)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Service != "sts" {
+		return callerARN, nil
+	}
+
+	role, _, ok := strings.Cut(strings.TrimPrefix(parsed.Resource, "assumed-role/"), "/")
+	if !ok || !strings.HasPrefix(parsed.Resource, "assumed-role/") {
+		return "", fmt.Errorf("%s: %s", "caller can't be simulated", callerARN)
+	}
+
+	return arn.ARN{Partition: parsed.Partition, Service: "iam", AccountID: parsed.AccountID, Resource: "role/" + role}.String(), nil
+}
+
+// isAccessDenied reports whether err is aws refusing the call to the credentials
+func isAccessDenied(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	return apiErr.ErrorCode() == "AccessDenied" || apiErr.ErrorCode() == "AccessDeniedException"
+}
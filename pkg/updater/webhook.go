@@ -0,0 +1,61 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"text/template"
+)
+
+// webhookNotifier posts events as json to a generic webhook
+type webhookNotifier struct {
+	client  *http.Client
+	url     string
+	payload *template.Template
+}
+
+// webhookFuncs are available to payload templates, json quotes a value so templates stay valid json
+var webhookFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// newWebhookNotifier validates target and parses the optional payload template, the event itself is
+// posted when no template is given
+func newWebhookNotifier(client *http.Client, target, payload string) (*webhookNotifier, error) {
+	if _, err := url.ParseRequestURI(target); err != nil {
+		return nil, err
+	}
+
+	var err error
+	n := &webhookNotifier{client: client, url: target}
+	if payload != "" {
+		n.payload, err = template.New("webhook").Funcs(webhookFuncs).Option("missingkey=error").Parse(payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return n, nil
+}
+
+func (n *webhookNotifier) name() string {
+	return "webhook"
+}
+
+func (n *webhookNotifier) notify(ctx context.Context, event notificationEvent) error {
+	if n.payload == nil {
+		return postJSON(ctx, n.client, n.url, event, nil)
+	}
+
+	var body bytes.Buffer
+	if err := n.payload.Execute(&body, event); err != nil {
+		return err
+	}
+
+	return postNotification(ctx, n.client, n.url, "application/json", body.Bytes(), nil)
+}